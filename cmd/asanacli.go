@@ -22,6 +22,7 @@ import (
 	_ "github.com/goasana/asanacli/cmd/commands/bale"
 	_ "github.com/goasana/asanacli/cmd/commands/dlv"
 	_ "github.com/goasana/asanacli/cmd/commands/dockerize"
+	_ "github.com/goasana/asanacli/cmd/commands/docs"
 	_ "github.com/goasana/asanacli/cmd/commands/generate"
 	_ "github.com/goasana/asanacli/cmd/commands/hprose"
 	_ "github.com/goasana/asanacli/cmd/commands/migrate"
@@ -34,14 +35,16 @@ import (
 	"github.com/goasana/asanacli/utils"
 )
 
+// IfGenerateDocs tells whether the command generates from the annotations of
+// the controllers. The args are the ones left by the flags of generate, the
+// first one being its subcommand.
 func IfGenerateDocs(name string, args []string) bool {
-	if name != "generate" {
+	if name != "generate" || len(args) == 0 {
 		return false
 	}
-	for _, a := range args {
-		if a == "docs" {
-			return true
-		}
+	switch args[0] {
+	case "docs", "test", "client":
+		return true
 	}
 	return false
 }
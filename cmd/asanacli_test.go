@@ -0,0 +1,25 @@
+package cmd
+
+import "testing"
+
+func TestIfGenerateDocs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"generate", []string{"docs"}, true},
+		{"generate", []string{"test"}, true},
+		{"generate", []string{"client", "out"}, true},
+		{"generate", []string{"model", "docs"}, false},
+		{"generate", []string{"scaffold", "test", "-fields=title:string"}, false},
+		{"generate", []string{"controller", "client"}, false},
+		{"generate", nil, false},
+		{"run", []string{"docs"}, false},
+	}
+	for _, tt := range tests {
+		if got := IfGenerateDocs(tt.name, tt.args); got != tt.want {
+			t.Errorf("IfGenerateDocs(%q, %q) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
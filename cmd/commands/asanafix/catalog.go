@@ -0,0 +1,195 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package asanafix
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	asanaPath      = "github.com/goasana/asana"
+	cachePath      = asanaPath + "/cache"
+	httplibPath    = asanaPath + "/httplib"
+	logsPath       = asanaPath + "/logs"
+	ormPath        = asanaPath + "/orm"
+	sessionPath    = asanaPath + "/session"
+	swaggerPath    = asanaPath + "/swagger"
+	toolboxPath    = asanaPath + "/toolbox"
+	validationPath = asanaPath + "/validation"
+)
+
+// release is a version of Asana, with the rules upgrading the code written
+// for the previous ones
+type release struct {
+	version string
+	rules   []rule
+}
+
+// releases is the catalog of the rules, sorted by version
+var releases = []release{
+	{
+		version: "1.0.0",
+		rules: []rule{
+			rename{asanaPath, "AppName", "BConfig.AppName"},
+			rename{asanaPath, "RunMode", "BConfig.RunMode"},
+			rename{asanaPath, "RecoverPanic", "BConfig.RecoverPanic"},
+			rename{asanaPath, "RouterCaseSensitive", "BConfig.RouterCaseSensitive"},
+			rename{asanaPath, "AsanaServerName", "BConfig.ServerName"},
+			rename{asanaPath, "EnableGzip", "BConfig.EnableGzip"},
+			rename{asanaPath, "ErrorsShow", "BConfig.EnableErrorsShow"},
+			rename{asanaPath, "CopyRequestBody", "BConfig.CopyRequestBody"},
+			rename{asanaPath, "MaxMemory", "BConfig.MaxMemory"},
+			rename{asanaPath, "Graceful", "BConfig.Listen.Graceful"},
+			rename{asanaPath, "HttpAddr", "BConfig.Listen.HTTPAddr"},
+			rename{asanaPath, "HttpPort", "BConfig.Listen.HTTPPort"},
+			rename{asanaPath, "ListenTCP4", "BConfig.Listen.ListenTCP4"},
+			rename{asanaPath, "EnableHttpListen", "BConfig.Listen.EnableHTTP"},
+			rename{asanaPath, "EnableHttpTLS", "BConfig.Listen.EnableHTTPS"},
+			rename{asanaPath, "HttpsAddr", "BConfig.Listen.HTTPSAddr"},
+			rename{asanaPath, "HttpsPort", "BConfig.Listen.HTTPSPort"},
+			rename{asanaPath, "HttpCertFile", "BConfig.Listen.HTTPSCertFile"},
+			rename{asanaPath, "HttpKeyFile", "BConfig.Listen.HTTPSKeyFile"},
+			rename{asanaPath, "EnableAdmin", "BConfig.Listen.EnableAdmin"},
+			rename{asanaPath, "AdminHttpAddr", "BConfig.Listen.AdminAddr"},
+			rename{asanaPath, "AdminHttpPort", "BConfig.Listen.AdminPort"},
+			rename{asanaPath, "UseFcgi", "BConfig.Listen.EnableFcgi"},
+			rename{asanaPath, "HttpServerTimeOut", "BConfig.Listen.ServerTimeOut"},
+			rename{asanaPath, "AutoRender", "BConfig.WebConfig.AutoRender"},
+			rename{asanaPath, "ViewsPath", "BConfig.WebConfig.ViewsPath"},
+			rename{asanaPath, "StaticDir", "BConfig.WebConfig.StaticDir"},
+			rename{asanaPath, "StaticExtensionsToGzip", "BConfig.WebConfig.StaticExtensionsToGzip"},
+			rename{asanaPath, "DirectoryIndex", "BConfig.WebConfig.DirectoryIndex"},
+			rename{asanaPath, "FlashName", "BConfig.WebConfig.FlashName"},
+			rename{asanaPath, "FlashSeperator", "BConfig.WebConfig.FlashSeparator"},
+			rename{asanaPath, "EnableDocs", "BConfig.WebConfig.EnableDocs"},
+			rename{asanaPath, "XSRFKEY", "BConfig.WebConfig.XSRFKey"},
+			rename{asanaPath, "EnableXSRF", "BConfig.WebConfig.EnableXSRF"},
+			rename{asanaPath, "XSRFExpire", "BConfig.WebConfig.XSRFExpire"},
+			rename{asanaPath, "TemplateLeft", "BConfig.WebConfig.TemplateLeft"},
+			rename{asanaPath, "TemplateRight", "BConfig.WebConfig.TemplateRight"},
+			rename{asanaPath, "SessionOn", "BConfig.WebConfig.Session.SessionOn"},
+			rename{asanaPath, "SessionProvider", "BConfig.WebConfig.Session.SessionProvider"},
+			rename{asanaPath, "SessionName", "BConfig.WebConfig.Session.SessionName"},
+			rename{asanaPath, "SessionGCMaxLifetime", "BConfig.WebConfig.Session.SessionGCMaxLifetime"},
+			rename{asanaPath, "SessionSavePath", "BConfig.WebConfig.Session.SessionProviderConfig"},
+			rename{asanaPath, "SessionCookieLifeTime", "BConfig.WebConfig.Session.SessionCookieLifeTime"},
+			rename{asanaPath, "SessionAutoSetCookie", "BConfig.WebConfig.Session.SessionAutoSetCookie"},
+			rename{asanaPath, "SessionDomain", "BConfig.WebConfig.Session.SessionDomain"},
+			rename{asanaPath, "UrlFor", "URLFor"},
+			rename{asanaPath, "GlobalDocApi", "GlobalDocAPI"},
+			rename{asanaPath, "Errorhandler", "ErrorHandler"},
+			rename{asanaPath, "Html2str", "HTML2str"},
+			rename{asanaPath, "AssetsCss", "AssetsCSS"},
+
+			rename{"", "UrlFor", "URLFor"},
+			rename{"", "ServeJson", "ServeJSON"},
+			rename{"", "ServeXml", "ServeXML"},
+			rename{"", "ServeJsonp", "ServeJSONP"},
+			rename{"", "XsrfToken", "XSRFToken"},
+			rename{"", "CheckXsrfCookie", "CheckXSRFCookie"},
+			rename{"", "XsrfFormHtml", "XSRFFormHTML"},
+			rename{"", "TplNames", "TplName"},
+			rename{"", "Output.Jsonp", "Output.JSONP"},
+			rename{"", "Output.Json", "Output.JSON"},
+			rename{"", "Output.Xml", "Output.XML"},
+			rename{"", "Input.Uri", "Input.URI"},
+			rename{"", "Input.Url", "Input.URL"},
+			rename{"", "Input.AcceptsHtml", "Input.AcceptsHTML"},
+			rename{"", "Input.AcceptsXml", "Input.AcceptsXML"},
+			rename{"", "Input.AcceptsJson", "Input.AcceptsJSON"},
+			rename{"", "Input.Request", "Input.Context.Request"},
+			signatureChange{name: "Input.CopyBody", arity: 0, args: []string{"asana.BConfig.MaxMemory"}, imports: []string{asanaPath}},
+			indexToCall{name: "Input.Params", get: "Param"},
+			indexToCall{name: "Input.Data", get: "Data", set: "SetData"},
+
+			rename{sessionPath, "SessionStore", "Store"},
+			rename{swaggerPath, "ApiRef", "APIRef"},
+			rename{swaggerPath, "ApiDeclaration", "APIDeclaration"},
+			rename{swaggerPath, "Api", "API"},
+			rename{swaggerPath, "Infomation", "Information"},
+			requiring{swaggerPath, rename{"", "Apis", "APIs"}},
+			rename{toolboxPath, "UrlMap", "URLMap"},
+			rename{logsPath, "LoggerInterface", "Logger"},
+			rename{validationPath, "ValidationError", "Error"},
+
+			rename{httplibPath, "AsanaHttpSettings", "AsanaHTTPSettings"},
+			rename{httplibPath, "AsanaHttpRequest", "AsanaHTTPRequest"},
+			rename{"", "TlsClientConfig", "TLSClientConfig"},
+			rename{"", "JsonBody", "JSONBody"},
+			rename{"", "ToJson", "ToJSON"},
+			rename{"", "ToXml", "ToXML"},
+			rename{"", "SendOut", "DoRequest"},
+
+			rename{ormPath, "DR_Sqlite", "DRSqlite"},
+			rename{ormPath, "DR_Postgres", "DRPostgres"},
+			rename{ormPath, "DR_MySQL", "DRMySQL"},
+			rename{ormPath, "DR_Oracle", "DROracle"},
+			rename{ormPath, "Col_Add", "ColAdd"},
+			rename{ormPath, "Col_Minus", "ColMinus"},
+			rename{ormPath, "Col_Multiply", "ColMultiply"},
+			rename{ormPath, "Col_Except", "ColExcept"},
+			rename{ormPath, "Debug_Queries", "DebugQueries"},
+			rename{ormPath, "COMMA_SPACE", "CommaSpace"},
+			rename{"", "GenerateOperatorSql", "GenerateOperatorSQL"},
+			rename{"", "OperatorSql", "OperatorSQL"},
+
+			// The timeouts of the cache are durations, the seconds given as
+			// constants to the caches are converted
+			signatureChange{
+				name: "Put", requires: cachePath, recv: "Cache", ctors: []string{"NewCache", "NewMemoryCache"},
+				arity: 3, args: []string{"$0", "$1", "$2 * time.Second"}, imports: []string{"time"}, consts: []int{2},
+			},
+		},
+	},
+}
+
+// latest returns the latest version of the catalog
+func latest() string {
+	return releases[len(releases)-1].version
+}
+
+// rulesBetween returns the rules of the releases after from, up to to
+func rulesBetween(from, to string) []rule {
+	var rules []rule
+	for _, r := range releases {
+		if compareVersions(r.version, from) > 0 && compareVersions(r.version, to) <= 0 {
+			rules = append(rules, r.rules...)
+		}
+	}
+	return rules
+}
+
+// compareVersions compares two versions such as v1.2.3
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(strings.SplitN(as[i], "-", 2)[0])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(strings.SplitN(bs[i], "-", 2)[0])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package asanafix
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/goasana/asanacli/logger/colors"
+)
+
+// contextLines is the number of unchanged lines around the changes
+const contextLines = 3
+
+// edit is a line of a diff: ' ' if kept, '-' if removed and '+' if added
+type edit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script from a to b, with the
+// algorithm of Myers
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	d := 0
+search:
+	for ; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prev := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prev = k + 1
+		}
+		px := v[offset+prev]
+		py := px - prev
+		for x > px && y > py {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == px {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+	for ; x > 0; x-- {
+		edits = append(edits, edit{' ', a[x-1]})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// unifiedDiff returns the colored unified diff of a file
+func unifiedDiff(name string, src, fixed []byte) string {
+	edits := diffLines(splitLines(src), splitLines(fixed))
+
+	var buf bytes.Buffer
+	buf.WriteString(colors.Bold("--- a/"+name) + "\n" + colors.Bold("+++ b/"+name) + "\n")
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		// A hunk goes on while the changes are close enough
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j, kept := i, 0; j < len(edits) && kept <= 2*contextLines; j++ {
+			if edits[j].op == ' ' {
+				kept++
+			} else {
+				end, kept = j, 0
+			}
+		}
+		end += contextLines
+		if end >= len(edits) {
+			end = len(edits) - 1
+		}
+
+		oldLine, newLine := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				oldLine++
+			}
+			if e.op != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		var hunk bytes.Buffer
+		for _, e := range edits[start : end+1] {
+			switch e.op {
+			case '-':
+				oldCount++
+				hunk.WriteString(colors.Red("-"+e.line) + "\n")
+			case '+':
+				newCount++
+				hunk.WriteString(colors.Green("+"+e.line) + "\n")
+			default:
+				oldCount++
+				newCount++
+				hunk.WriteString(" " + e.line + "\n")
+			}
+		}
+		buf.WriteString(colors.Cyan(fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldLine, oldCount, newLine, newCount)) + "\n")
+		buf.Write(hunk.Bytes())
+		i = end + 1
+	}
+	return buf.String()
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -1,32 +1,54 @@
 package asanafix
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"go/format"
 	"go/parser"
+	"go/printer"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/goasana/asanacli/cmd/commands"
 	"github.com/goasana/asanacli/cmd/commands/version"
 	asanaLogger "github.com/goasana/asanacli/logger"
 	"github.com/goasana/asanacli/logger/colors"
+	"github.com/goasana/asanacli/utils"
 )
 
 var CmdFix = &commands.Command{
-	UsageLine: "fix",
+	UsageLine: "fix [-from=version] [-to=version] [-dry-run] [-list]",
 	Short:     "Fixes your application by making it compatible with newer versions of Asana",
-	Long: `As of {{"Asana 1.0"|bold}}, there are some backward compatibility issues.
+	Long: `Fix upgrades the Go files of your application to a newer version of Asana, by rewriting
+  the code which is broken by the changes of each release: moved packages, renamed selectors,
+  changed signatures and maps replaced by methods.
 
-  The command 'fix' will try to solve those issues by upgrading your code base
-  to be compatible  with Asana version 1.0.0+.
+  {{"To preview the changes of the upgrade to Asana 1.0.0:"|bold}}
+    $ asanacli fix -from=0.9.0 -to=1.0.0 -dry-run
+
+  The version upgraded from defaults to the version of Asana required by go.mod, all the rules
+  are applied without it. The version upgraded to defaults to the latest release of the catalog.
+  Each change is reported by file, -list prints the rules of the catalog.
 `,
 }
 
+var (
+	from   string
+	to     string
+	dryRun bool
+	list   bool
+)
+
 func init() {
+	CmdFix.Flag.StringVar(&from, "from", "", "Set the version of Asana to upgrade from. Defaults to the version required by go.mod.")
+	CmdFix.Flag.StringVar(&to, "to", "", "Set the version of Asana to upgrade to. Defaults to the latest one.")
+	CmdFix.Flag.BoolVar(&dryRun, "dry-run", false, "Print the diffs of the changes instead of writing them.")
+	CmdFix.Flag.BoolVar(&list, "list", false, "List the rules of each release.")
 	CmdFix.Run = runFix
 	CmdFix.PreRun = func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() }
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdFix)
@@ -35,198 +57,144 @@ func init() {
 func runFix(cmd *commands.Command, args []string) int {
 	output := cmd.Out()
 
-	asanaLogger.Log.Info("Upgrading the application...")
+	if list {
+		for _, r := range releases {
+			_, _ = fmt.Fprintln(output, colors.Bold(r.version))
+			for _, rl := range r.rules {
+				_, _ = fmt.Fprintf(output, "\t%s\n", rl)
+			}
+		}
+		return 0
+	}
 
 	dir, err := os.Getwd()
 	if err != nil {
 		asanaLogger.Log.Fatalf("Error while getting the current working directory: %s", err)
 	}
 
-	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	if to == "" {
+		to = latest()
+	}
+	if from == "" {
+		from = requiredVersion(filepath.Join(dir, "go.mod"))
+	}
+	if from == "" {
+		asanaLogger.Log.Infof("Upgrading the application to Asana %s...", to)
+		from = "0"
+	} else {
+		asanaLogger.Log.Infof("Upgrading the application from Asana %s to %s...", from, to)
+	}
+	rules := rulesBetween(from, to)
+	if len(rules) == 0 {
+		asanaLogger.Log.Success("Nothing to upgrade")
+		return 0
+	}
+
+	fixed := 0
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
-			if strings.HasPrefix(info.Name(), ".") {
+			if path != dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "vendor") {
 				return filepath.SkipDir
 			}
 			return nil
 		}
+		if !strings.HasSuffix(info.Name(), ".go") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		src, res, changes, err := fixFile(path, rules)
 		if err != nil {
-			return err
+			asanaLogger.Log.Errorf("Could not fix %s: %s", rel, err)
+			return nil
 		}
-		if strings.HasSuffix(info.Name(), ".exe") {
+		if len(changes) == 0 {
 			return nil
 		}
-		err = fixFile(path)
-		_, _ = fmt.Fprintf(output, colors.GreenBold("\tfix\t")+"%s\n", path)
-		if err != nil {
-			asanaLogger.Log.Errorf("Could not fix file: %s", err)
+		fixed++
+
+		_, _ = fmt.Fprintf(output, colors.GreenBold("\tfix\t")+"%s\n", rel)
+		for _, c := range changes {
+			_, _ = fmt.Fprintf(output, "\t\t%s\n", c)
 		}
-		return err
+		if dryRun {
+			_, _ = fmt.Fprint(output, unifiedDiff(filepath.ToSlash(rel), src, res))
+			return nil
+		}
+		return ioutil.WriteFile(path, res, info.Mode())
 	})
-	asanaLogger.Log.Success("Upgrade Done!")
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not upgrade the application: %s", err)
+	}
+
+	if dryRun {
+		asanaLogger.Log.Successf("%d file(s) to fix, nothing written", fixed)
+		return 0
+	}
+	asanaLogger.Log.Successf("Upgrade Done! %d file(s) fixed", fixed)
 	return 0
 }
 
-var rules = []string{
-	"asana.AppName", "asana.BConfig.AppName",
-	"asana.RunMode", "asana.BConfig.RunMode",
-	"asana.RecoverPanic", "asana.BConfig.RecoverPanic",
-	"asana.RouterCaseSensitive", "asana.BConfig.RouterCaseSensitive",
-	"asana.AsanaServerName", "asana.BConfig.ServerName",
-	"asana.EnableGzip", "asana.BConfig.EnableGzip",
-	"asana.ErrorsShow", "asana.BConfig.EnableErrorsShow",
-	"asana.CopyRequestBody", "asana.BConfig.CopyRequestBody",
-	"asana.MaxMemory", "asana.BConfig.MaxMemory",
-	"asana.Graceful", "asana.BConfig.Listen.Graceful",
-	"asana.HttpAddr", "asana.BConfig.Listen.HTTPAddr",
-	"asana.HttpPort", "asana.BConfig.Listen.HTTPPort",
-	"asana.ListenTCP4", "asana.BConfig.Listen.ListenTCP4",
-	"asana.EnableHttpListen", "asana.BConfig.Listen.EnableHTTP",
-	"asana.EnableHttpTLS", "asana.BConfig.Listen.EnableHTTPS",
-	"asana.HttpsAddr", "asana.BConfig.Listen.HTTPSAddr",
-	"asana.HttpsPort", "asana.BConfig.Listen.HTTPSPort",
-	"asana.HttpCertFile", "asana.BConfig.Listen.HTTPSCertFile",
-	"asana.HttpKeyFile", "asana.BConfig.Listen.HTTPSKeyFile",
-	"asana.EnableAdmin", "asana.BConfig.Listen.EnableAdmin",
-	"asana.AdminHttpAddr", "asana.BConfig.Listen.AdminAddr",
-	"asana.AdminHttpPort", "asana.BConfig.Listen.AdminPort",
-	"asana.UseFcgi", "asana.BConfig.Listen.EnableFcgi",
-	"asana.HttpServerTimeOut", "asana.BConfig.Listen.ServerTimeOut",
-	"asana.AutoRender", "asana.BConfig.WebConfig.AutoRender",
-	"asana.ViewsPath", "asana.BConfig.WebConfig.ViewsPath",
-	"asana.StaticDir", "asana.BConfig.WebConfig.StaticDir",
-	"asana.StaticExtensionsToGzip", "asana.BConfig.WebConfig.StaticExtensionsToGzip",
-	"asana.DirectoryIndex", "asana.BConfig.WebConfig.DirectoryIndex",
-	"asana.FlashName", "asana.BConfig.WebConfig.FlashName",
-	"asana.FlashSeperator", "asana.BConfig.WebConfig.FlashSeparator",
-	"asana.EnableDocs", "asana.BConfig.WebConfig.EnableDocs",
-	"asana.XSRFKEY", "asana.BConfig.WebConfig.XSRFKey",
-	"asana.EnableXSRF", "asana.BConfig.WebConfig.EnableXSRF",
-	"asana.XSRFExpire", "asana.BConfig.WebConfig.XSRFExpire",
-	"asana.TemplateLeft", "asana.BConfig.WebConfig.TemplateLeft",
-	"asana.TemplateRight", "asana.BConfig.WebConfig.TemplateRight",
-	"asana.SessionOn", "asana.BConfig.WebConfig.Session.SessionOn",
-	"asana.SessionProvider", "asana.BConfig.WebConfig.Session.SessionProvider",
-	"asana.SessionName", "asana.BConfig.WebConfig.Session.SessionName",
-	"asana.SessionGCMaxLifetime", "asana.BConfig.WebConfig.Session.SessionGCMaxLifetime",
-	"asana.SessionSavePath", "asana.BConfig.WebConfig.Session.SessionProviderConfig",
-	"asana.SessionCookieLifeTime", "asana.BConfig.WebConfig.Session.SessionCookieLifeTime",
-	"asana.SessionAutoSetCookie", "asana.BConfig.WebConfig.Session.SessionAutoSetCookie",
-	"asana.SessionDomain", "asana.BConfig.WebConfig.Session.SessionDomain",
-	"Ctx.Input.CopyBody(", "Ctx.Input.CopyBody(asana.BConfig.MaxMemory",
-	".UrlFor(", ".URLFor(",
-	".ServeJson(", ".ServeJSON(",
-	".ServeXml(", ".ServeXML(",
-	".ServeJsonp(", ".ServeJSONP(",
-	".XsrfToken(", ".XSRFToken(",
-	".CheckXsrfCookie(", ".CheckXSRFCookie(",
-	".XsrfFormHtml(", ".XSRFFormHTML(",
-	"asana.UrlFor(", "asana.URLFor(",
-	"asana.GlobalDocApi", "asana.GlobalDocAPI",
-	"asana.Errorhandler", "asana.ErrorHandler",
-	"Output.Jsonp(", "Output.JSONP(",
-	"Output.Json(", "Output.JSON(",
-	"Output.Xml(", "Output.XML(",
-	"Input.Uri()", "Input.URI()",
-	"Input.Url()", "Input.URL()",
-	"Input.AcceptsHtml()", "Input.AcceptsHTML()",
-	"Input.AcceptsXml()", "Input.AcceptsXML()",
-	"Input.AcceptsJson()", "Input.AcceptsJSON()",
-	"Ctx.XsrfToken()", "Ctx.XSRFToken()",
-	"Ctx.CheckXsrfCookie()", "Ctx.CheckXSRFCookie()",
-	"session.SessionStore", "session.Store",
-	".TplNames", ".TplName",
-	"swagger.ApiRef", "swagger.APIRef",
-	"swagger.ApiDeclaration", "swagger.APIDeclaration",
-	"swagger.Api", "swagger.API",
-	"swagger.ApiRef", "swagger.APIRef",
-	"swagger.Infomation", "swagger.Information",
-	"toolbox.UrlMap", "toolbox.URLMap",
-	"logs.LoggerInterface", "logs.Logger",
-	"Input.Request", "Input.Context.Request",
-	"Input.Params)", "Input.Params())",
-	"httplib.AsanaHttpSettings", "httplib.AsanaHTTPSettings",
-	"httplib.AsanaHttpRequest", "httplib.AsanaHTTPRequest",
-	".TlsClientConfig", ".TLSClientConfig",
-	".JsonBody", ".JSONBody",
-	".ToJson", ".ToJSON",
-	".ToXml", ".ToXML",
-	"asana.Html2str", "asana.HTML2str",
-	"asana.AssetsCss", "asana.AssetsCSS",
-	"orm.DR_Sqlite", "orm.DRSqlite",
-	"orm.DR_Postgres", "orm.DRPostgres",
-	"orm.DR_MySQL", "orm.DRMySQL",
-	"orm.DR_Oracle", "orm.DROracle",
-	"orm.Col_Add", "orm.ColAdd",
-	"orm.Col_Minus", "orm.ColMinus",
-	"orm.Col_Multiply", "orm.ColMultiply",
-	"orm.Col_Except", "orm.ColExcept",
-	"GenerateOperatorSql", "GenerateOperatorSQL",
-	"OperatorSql", "OperatorSQL",
-	"orm.Debug_Queries", "orm.DebugQueries",
-	"orm.COMMA_SPACE", "orm.CommaSpace",
-	".SendOut()", ".DoRequest()",
-	"validation.ValidationError", "validation.Error",
+// fixFile applies the rules to a Go file. It returns its source, the fixed
+// one and the changes made.
+func fixFile(path string, rules []rule) ([]byte, []byte, []change, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	res, changes, err := fixSource(path, src, rules)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return src, res, changes, nil
 }
 
-func fixFile(file string) error {
-	rp := strings.NewReplacer(rules...)
-	content, err := ioutil.ReadFile(file)
+// fixSource applies the rules to the source of a Go file. It returns the
+// fixed source and the changes made.
+func fixSource(path string, src []byte, rules []rule) ([]byte, []change, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
 	if err != nil {
-		return err
-	}
-	fixed := rp.Replace(string(content))
-
-	// Forword the RequestBody from the replace
-	// "Input.Request", "Input.Context.Request",
-	fixed = strings.Replace(fixed, "Input.Context.RequestBody", "Input.RequestBody", -1)
-
-	// Regexp replace
-	pareg := regexp.MustCompile(`(Input.Params\[")(.*)("])`)
-	fixed = pareg.ReplaceAllString(fixed, "Input.Param(\"$2\")")
-	pareg = regexp.MustCompile(`(Input.Data\[\")(.*)(\"\])(\s)(=)(\s)(.*)`)
-	fixed = pareg.ReplaceAllString(fixed, "Input.SetData(\"$2\", $7)")
-	pareg = regexp.MustCompile(`(Input.Data\[\")(.*)(\"\])`)
-	fixed = pareg.ReplaceAllString(fixed, "Input.Data(\"$2\")")
-	// Fix the cache object Put method
-	pareg = regexp.MustCompile(`(\.Put\(\")(.*)(\",)(\s)(.*)(,\s*)([^\*.]*)(\))`)
-	if pareg.MatchString(fixed) && strings.HasSuffix(file, ".go") {
-		fixed = pareg.ReplaceAllString(fixed, ".Put(\"$2\", $5, $7*time.Second)")
-		fset := token.NewFileSet() // positions are relative to fset
-		f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
-		if err != nil {
-			panic(err)
-		}
-		// Print the imports from the file's AST.
-		hasTimepkg := false
-		for _, s := range f.Imports {
-			if s.Path.Value == `"time"` {
-				hasTimepkg = true
-				break
-			}
-		}
-		if !hasTimepkg {
-			fixed = strings.Replace(fixed, "import (", "import (\n\t\"time\"", 1)
-		}
+		return nil, nil, err
 	}
-	// Replace the v.Apis in docs.go
-	if strings.Contains(file, "docs.go") {
-		fixed = strings.Replace(fixed, "v.Apis", "v.APIs", -1)
-	}
-	// Replace the config file
-	if strings.HasSuffix(file, ".yaml") {
-		fixed = strings.Replace(fixed, "HttpCertFile", "HTTPSCertFile", -1)
-		fixed = strings.Replace(fixed, "HttpKeyFile", "HTTPSKeyFile", -1)
-		fixed = strings.Replace(fixed, "EnableHttpListen", "HTTPEnable", -1)
-		fixed = strings.Replace(fixed, "EnableHttpTLS", "EnableHTTPS", -1)
-		fixed = strings.Replace(fixed, "EnableHttpTLS", "EnableHTTPS", -1)
-		fixed = strings.Replace(fixed, "AsanaServerName", "ServerName", -1)
-		fixed = strings.Replace(fixed, "AdminHttpAddr", "AdminAddr", -1)
-		fixed = strings.Replace(fixed, "AdminHttpPort", "AdminPort", -1)
-		fixed = strings.Replace(fixed, "HttpServerTimeOut", "ServerTimeOut", -1)
-	}
-	err = os.Truncate(file, 0)
+
+	fx := &file{fset: fset, ast: f}
+	for _, r := range rules {
+		r.apply(fx)
+	}
+	if len(fx.changes) == 0 {
+		return src, nil, nil
+	}
+	sort.SliceStable(fx.changes, func(i, j int) bool { return fx.changes[i].line < fx.changes[j].line })
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		return nil, nil, err
+	}
+	// Sorts the imports and formats the new nodes
+	res, err := format.Source(buf.Bytes())
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	return res, fx.changes, nil
+}
+
+// requiredVersion returns the version of Asana required by go.mod, empty
+// if none
+func requiredVersion(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return ""
+	}
+	defer utils.CloseFile(f)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "require "))
+		if len(fields) >= 2 && fields[0] == asanaPath {
+			return strings.TrimPrefix(fields[1], "v")
+		}
 	}
-	return ioutil.WriteFile(file, []byte(fixed), 0666)
+	return ""
 }
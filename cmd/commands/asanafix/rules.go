@@ -0,0 +1,582 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package asanafix
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// rule rewrites the code broken by a change of Asana
+type rule interface {
+	apply(f *file)
+	String() string
+}
+
+// rename renames a selector, e.g. asana.AppName to asana.BConfig.AppName.
+// The names are dotted selectors, matched at the end of the expressions
+// on the package, or on any value when pkg is empty.
+type rename struct {
+	pkg  string // import path
+	from string
+	to   string
+}
+
+func (r rename) apply(f *file) {
+	from, to := strings.Split(r.from, "."), strings.Split(r.to, ".")
+	f.rewrite(func(n ast.Node) ast.Node {
+		base, ok := f.match(n, r.pkg, from)
+		if !ok {
+			return n
+		}
+		f.report(n.Pos(), "%s", r)
+		return selector(base, to, n.(*ast.SelectorExpr).Sel.Pos())
+	})
+}
+
+func (r rename) String() string {
+	if r.pkg != "" {
+		name := path.Base(r.pkg)
+		return fmt.Sprintf("%s.%s -> %s.%s", name, r.from, name, r.to)
+	}
+	return fmt.Sprintf(".%s -> .%s", r.from, r.to)
+}
+
+// importMove moves a package, and its subpackages, to another import path.
+// The imports are named after the old package when its name changes.
+type importMove struct {
+	from string
+	to   string
+}
+
+func (r importMove) apply(f *file) {
+	for _, spec := range f.ast.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		if p != r.from && !strings.HasPrefix(p, r.from+"/") {
+			continue
+		}
+		moved := r.to + strings.TrimPrefix(p, r.from)
+		if spec.Name == nil && path.Base(moved) != path.Base(p) {
+			spec.Name = ast.NewIdent(path.Base(p))
+		}
+		spec.Path.Value = strconv.Quote(moved)
+		f.report(spec.Pos(), "import %q -> %q", p, moved)
+	}
+}
+
+func (r importMove) String() string {
+	return fmt.Sprintf("import %q -> %q", r.from, r.to)
+}
+
+// requiring applies a rule only to the files importing a package, for the
+// rules on values of its types
+type requiring struct {
+	pkg  string // import path
+	rule rule
+}
+
+func (r requiring) apply(f *file) {
+	if f.pkgName(r.pkg) != "" {
+		r.rule.apply(f)
+	}
+}
+
+func (r requiring) String() string {
+	return fmt.Sprintf("%s, importing %q", r.rule, r.pkg)
+}
+
+// signatureChange rewrites the arguments of the calls of a function or a
+// method. The new arguments are Go expressions where $0, $1... are the
+// old ones, only the calls with arity arguments are rewritten.
+type signatureChange struct {
+	pkg      string // import path, the calls are methods when empty
+	name     string // dotted selector, as in rename
+	requires string // import path of the files to fix, for methods
+	recv     string // type of the receiver in requires, for methods
+	ctors    []string
+	arity    int
+	args     []string
+	imports  []string // import paths of the packages used by args
+	consts   []int    // arguments which must be untyped integer constants
+}
+
+func (r signatureChange) apply(f *file) {
+	if r.requires != "" && f.pkgName(r.requires) == "" {
+		return
+	}
+	name := strings.Split(r.name, ".")
+	f.rewrite(func(n ast.Node) ast.Node {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != r.arity || call.Ellipsis.IsValid() {
+			return n
+		}
+		recv, ok := f.match(call.Fun, r.pkg, name)
+		if !ok {
+			return n
+		}
+		if r.recv != "" && !f.hasType(recv, r.requires, r.recv, r.ctors) {
+			return n
+		}
+		// The calls already fixed no longer pass untyped constants
+		for _, i := range r.consts {
+			if !f.isUntypedInt(call.Args[i]) {
+				return n
+			}
+		}
+		args := make([]ast.Expr, len(r.args))
+		for i, arg := range r.args {
+			args[i] = f.expr(arg, call.Args, r.imports, call.Rparen)
+		}
+		f.report(n.Pos(), "%s", r)
+		call.Args = args
+		return call
+	})
+}
+
+func (r signatureChange) String() string {
+	old := make([]string, r.arity)
+	for i := range old {
+		old[i] = "$" + strconv.Itoa(i)
+	}
+	name := r.name
+	switch {
+	case r.pkg != "":
+		name = path.Base(r.pkg) + "." + name
+	case r.recv != "":
+		name = path.Base(r.requires) + "." + r.recv + "." + name
+	default:
+		name = "." + name
+	}
+	return fmt.Sprintf("%s(%s) -> %s(%s)", name, strings.Join(old, ", "), name, strings.Join(r.args, ", "))
+}
+
+// indexToCall replaces the indexing of a map by the calls of its getter,
+// and the assignments to it by the calls of its setter if any, e.g.
+// Input.Data["k"] = v to Input.SetData("k", v).
+type indexToCall struct {
+	name string // dotted selector, as in rename
+	get  string
+	set  string
+}
+
+func (r indexToCall) apply(f *file) {
+	name := strings.Split(r.name, ".")
+	call := func(index *ast.IndexExpr, method string, args ...ast.Expr) *ast.CallExpr {
+		sel := index.X.(*ast.SelectorExpr)
+		return &ast.CallExpr{
+			Fun:    selector(sel.X, []string{method}, sel.Sel.Pos()),
+			Lparen: index.Lbrack,
+			Args:   args,
+			Rparen: index.Rbrack,
+		}
+	}
+	// The assignments first, their index expressions are not getters
+	if r.set != "" {
+		f.rewrite(func(n ast.Node) ast.Node {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+				return n
+			}
+			index, ok := assign.Lhs[0].(*ast.IndexExpr)
+			if !ok {
+				return n
+			}
+			if _, ok := f.match(index.X, "", name); !ok {
+				return n
+			}
+			f.report(n.Pos(), "%s[$0] = $1 -> %s", r.name, r.setter())
+			return &ast.ExprStmt{X: call(index, r.set, index.Index, assign.Rhs[0])}
+		})
+	}
+	f.rewrite(func(n ast.Node) ast.Node {
+		index, ok := n.(*ast.IndexExpr)
+		if !ok {
+			return n
+		}
+		if _, ok := f.match(index.X, "", name); !ok {
+			return n
+		}
+		f.report(n.Pos(), "%s[$0] -> %s", r.name, r.getter())
+		return call(index, r.get, index.Index)
+	})
+}
+
+func (r indexToCall) getter() string {
+	return strings.TrimSuffix(r.name, path.Ext(r.name)) + "." + r.get + "($0)"
+}
+
+func (r indexToCall) setter() string {
+	return strings.TrimSuffix(r.name, path.Ext(r.name)) + "." + r.set + "($0, $1)"
+}
+
+func (r indexToCall) String() string {
+	s := fmt.Sprintf("%s[$0] -> %s", r.name, r.getter())
+	if r.set != "" {
+		s += fmt.Sprintf(", %s[$0] = $1 -> %s", r.name, r.setter())
+	}
+	return s
+}
+
+// file is a Go file being fixed
+type file struct {
+	fset    *token.FileSet
+	ast     *ast.File
+	changes []change
+}
+
+// change is a change made to a file
+type change struct {
+	line int
+	desc string
+}
+
+func (c change) String() string {
+	return fmt.Sprintf("line %d: %s", c.line, c.desc)
+}
+
+func (f *file) report(pos token.Pos, format string, args ...interface{}) {
+	f.changes = append(f.changes, change{f.fset.Position(pos).Line, fmt.Sprintf(format, args...)})
+}
+
+// pkgName returns the name of an imported package in the file, empty if
+// it is not imported
+func (f *file) pkgName(importPath string) string {
+	for _, spec := range f.ast.Imports {
+		if p, _ := strconv.Unquote(spec.Path.Value); p != importPath {
+			continue
+		}
+		if spec.Name == nil {
+			return path.Base(importPath)
+		}
+		if spec.Name.Name == "_" || spec.Name.Name == "." {
+			return ""
+		}
+		return spec.Name.Name
+	}
+	return ""
+}
+
+// isPkg returns true if e is the name of an imported package
+func (f *file) isPkg(e ast.Expr, importPath string) bool {
+	id, ok := e.(*ast.Ident)
+	if !ok || id.Obj != nil {
+		return false
+	}
+	if importPath != "" {
+		return id.Name == f.pkgName(importPath)
+	}
+	for _, spec := range f.ast.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		if id.Name == f.pkgName(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasType returns true if the value e is declared in the file with the
+// type typeName of the package, or assigned from one of its functions
+// ctors returning this type first. The fields of the structs of the file
+// are resolved by name.
+func (f *file) hasType(e ast.Expr, importPath, typeName string, ctors []string) bool {
+	isType := func(t ast.Expr) bool {
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		sel, ok := t.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == typeName && f.isPkg(sel.X, importPath)
+	}
+	// isCtor returns true if the i-th value assigned from values is the
+	// result of a constructor
+	isCtor := func(values []ast.Expr, i int) bool {
+		var v ast.Expr
+		switch {
+		case len(values) == 1 && i == 0:
+			v = values[0]
+		case len(values) > 1 && i >= 0 && i < len(values):
+			v = values[i]
+		default:
+			return false
+		}
+		call, ok := v.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !f.isPkg(sel.X, importPath) {
+			return false
+		}
+		for _, ctor := range ctors {
+			if sel.Sel.Name == ctor {
+				return true
+			}
+		}
+		return false
+	}
+	index := func(names []*ast.Ident, name string) int {
+		for i, id := range names {
+			if id.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return f.hasType(e.X, importPath, typeName, ctors)
+	case *ast.Ident:
+		if e.Obj == nil || e.Obj.Kind != ast.Var {
+			return false
+		}
+		switch d := e.Obj.Decl.(type) {
+		case *ast.Field:
+			return isType(d.Type)
+		case *ast.ValueSpec:
+			if d.Type != nil {
+				return isType(d.Type)
+			}
+			return isCtor(d.Values, index(d.Names, e.Name))
+		case *ast.AssignStmt:
+			for i, lhs := range d.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == e.Name {
+					return isCtor(d.Rhs, i)
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		found := false
+		ast.Inspect(f.ast, func(n ast.Node) bool {
+			if st, ok := n.(*ast.StructType); ok {
+				for _, field := range st.Fields.List {
+					if index(field.Names, e.Sel.Name) >= 0 && isType(field.Type) {
+						found = true
+					}
+				}
+			}
+			return !found
+		})
+		return found
+	}
+	return false
+}
+
+// isUntypedInt returns true if e is an untyped integer constant: literals,
+// constants declared without a type and the operations on them
+func (f *file) isUntypedInt(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return e.Kind == token.INT
+	case *ast.ParenExpr:
+		return f.isUntypedInt(e.X)
+	case *ast.UnaryExpr:
+		return (e.Op == token.ADD || e.Op == token.SUB || e.Op == token.XOR) && f.isUntypedInt(e.X)
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+			token.AND, token.OR, token.XOR, token.AND_NOT, token.SHL, token.SHR:
+			return f.isUntypedInt(e.X) && f.isUntypedInt(e.Y)
+		}
+	case *ast.Ident:
+		if e.Obj == nil || e.Obj.Kind != ast.Con {
+			return false
+		}
+		spec, ok := e.Obj.Decl.(*ast.ValueSpec)
+		if !ok || spec.Type != nil {
+			return false
+		}
+		for i, id := range spec.Names {
+			// iota is an untyped integer, the values of the specs
+			// repeating the previous expression are not resolved
+			if id.Name == e.Name && i < len(spec.Values) {
+				if v, ok := spec.Values[i].(*ast.Ident); ok && v.Name == "iota" {
+					return true
+				}
+				return f.isUntypedInt(spec.Values[i])
+			}
+		}
+	}
+	return false
+}
+
+// match matches the selectors of names at the end of e. It returns the
+// rest of the expression, which is the package when pkg is set and a
+// value otherwise.
+func (f *file) match(n ast.Node, pkg string, names []string) (ast.Expr, bool) {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return nil, false
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		sel, ok := e.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != names[i] {
+			return nil, false
+		}
+		e = sel.X
+	}
+	if pkg != "" {
+		return e, f.isPkg(e, pkg)
+	}
+	return e, !f.isPkg(e, "")
+}
+
+// expr parses an argument of a signature change at pos, replacing $0,
+// $1... by args and the packages of imports by their names in the file
+func (f *file) expr(src string, args []ast.Expr, imports []string, pos token.Pos) ast.Expr {
+	for i := range args {
+		src = strings.Replace(src, "$"+strconv.Itoa(i), "__arg"+strconv.Itoa(i), -1)
+	}
+	e, err := parser.ParseExpr(src)
+	if err != nil {
+		panic(fmt.Sprintf("invalid rule argument %q: %s", src, err))
+	}
+	// The positions of the parsed expression are not in the file
+	setPos(e, pos)
+	names := make(map[string]string)
+	for _, p := range imports {
+		names[path.Base(p)] = f.addImport(p)
+	}
+	return rewrite(e, func(n ast.Node) ast.Node {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return n
+		}
+		if name, ok := names[id.Name]; ok {
+			return &ast.Ident{NamePos: pos, Name: name}
+		}
+		if !strings.HasPrefix(id.Name, "__arg") {
+			return n
+		}
+		i, _ := strconv.Atoi(strings.TrimPrefix(id.Name, "__arg"))
+		if _, ok := args[i].(*ast.BinaryExpr); ok && src != id.Name {
+			return &ast.ParenExpr{X: args[i]}
+		}
+		return args[i]
+	}).(ast.Expr)
+}
+
+// addImport imports a package in the file if needed, and returns its name
+func (f *file) addImport(importPath string) string {
+	if name := f.pkgName(importPath); name != "" {
+		return name
+	}
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(importPath)}}
+	f.ast.Imports = append(f.ast.Imports, spec)
+	for _, d := range f.ast.Decls {
+		d, ok := d.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT || len(d.Specs) == 1 && d.Specs[0].(*ast.ImportSpec).Path.Value == `"C"` {
+			continue
+		}
+		if !d.Lparen.IsValid() {
+			d.Lparen, d.Rparen = d.Pos(), d.End()
+		}
+		// After the last import of the same kind, standard or not, for the
+		// import to be sorted in its group
+		i := -1
+		for j, s := range d.Specs {
+			if p, _ := strconv.Unquote(s.(*ast.ImportSpec).Path.Value); isStd(p) == isStd(importPath) {
+				i = j
+			}
+		}
+		switch {
+		case i >= 0:
+			spec.Path.ValuePos = d.Specs[i].End()
+		case isStd(importPath):
+			// A group of its own before the others, the line of the
+			// package clause separates it by an empty line
+			spec.Path.ValuePos = f.ast.Package
+		default:
+			i = len(d.Specs) - 1
+			spec.Path.ValuePos = d.Specs[i].End()
+		}
+		i++
+		d.Specs = append(d.Specs[:i], append([]ast.Spec{spec}, d.Specs[i:]...)...)
+		return path.Base(importPath)
+	}
+	spec.Path.ValuePos = f.ast.Name.End()
+	decl := &ast.GenDecl{TokPos: f.ast.Name.End(), Tok: token.IMPORT, Specs: []ast.Spec{spec}}
+	f.ast.Decls = append([]ast.Decl{decl}, f.ast.Decls...)
+	return path.Base(importPath)
+}
+
+// rewrite rewrites the file with fn
+func (f *file) rewrite(fn func(ast.Node) ast.Node) {
+	rewrite(f.ast, fn)
+}
+
+// isStd returns true if the import path is of the standard library
+func isStd(importPath string) bool {
+	return !strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".")
+}
+
+// selector returns the selector of names on x, at pos
+func selector(x ast.Expr, names []string, pos token.Pos) ast.Expr {
+	for _, name := range names {
+		x = &ast.SelectorExpr{X: x, Sel: &ast.Ident{NamePos: pos, Name: name}}
+	}
+	return x
+}
+
+var posType = reflect.TypeOf(token.NoPos)
+
+// setPos sets all the positions of the tree of n to pos
+func setPos(n ast.Node, pos token.Pos) {
+	rewrite(n, func(n ast.Node) ast.Node {
+		s := reflect.ValueOf(n).Elem()
+		for i := 0; i < s.NumField(); i++ {
+			if f := s.Field(i); f.Type() == posType {
+				f.Set(reflect.ValueOf(pos))
+			}
+		}
+		return n
+	})
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// rewrite walks the tree of n depth-first, replacing each node by the one
+// returned by fn, and returns the new root
+func rewrite(n ast.Node, fn func(ast.Node) ast.Node) ast.Node {
+	v := reflect.ValueOf(n)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		for s, i := v.Elem(), 0; i < s.NumField(); i++ {
+			rewriteField(s.Field(i), fn)
+		}
+	}
+	return fn(n)
+}
+
+func rewriteField(v reflect.Value, fn func(ast.Node) ast.Node) {
+	switch v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			rewriteField(v.Index(i), fn)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() || !v.Type().Implements(nodeType) {
+			return
+		}
+		n := rewrite(v.Interface().(ast.Node), fn)
+		if r := reflect.ValueOf(n); v.CanSet() && r.Type().AssignableTo(v.Type()) {
+			v.Set(r)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package asanafix
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+var putRule = releases[0].rules[len(releases[0].rules)-1]
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []rule
+		src   string
+		want  string // the source when unchanged
+	}{
+		{
+			name:  "rename on the package",
+			rules: []rule{rename{asanaPath, "AppName", "BConfig.AppName"}},
+			src: `package main
+
+import "github.com/goasana/asana"
+
+func main() { println(asana.AppName) }
+`,
+			want: `package main
+
+import "github.com/goasana/asana"
+
+func main() { println(asana.BConfig.AppName) }
+`,
+		},
+		{
+			name:  "rename on a named import",
+			rules: []rule{rename{asanaPath, "AppName", "BConfig.AppName"}},
+			src: `package main
+
+import web "github.com/goasana/asana"
+
+func main() { println(web.AppName) }
+`,
+			want: `package main
+
+import web "github.com/goasana/asana"
+
+func main() { println(web.BConfig.AppName) }
+`,
+		},
+		{
+			name:  "rename on another package",
+			rules: []rule{rename{asanaPath, "AppName", "BConfig.AppName"}},
+			src: `package main
+
+import "example.com/asana"
+
+func main() { println(asana.AppName) }
+`,
+		},
+		{
+			name:  "rename on a value",
+			rules: []rule{rename{"", "ServeJson", "ServeJSON"}},
+			src: `package controllers
+
+func (c *MainController) Get() { c.ServeJson() }
+`,
+			want: `package controllers
+
+func (c *MainController) Get() { c.ServeJSON() }
+`,
+		},
+		{
+			name:  "rename of a dotted selector",
+			rules: []rule{rename{"", "Input.Request", "Input.Context.Request"}},
+			src: `package controllers
+
+func (c *MainController) Get() { _ = c.Ctx.Input.Request; _ = c.Input }
+`,
+			want: `package controllers
+
+func (c *MainController) Get() { _ = c.Ctx.Input.Context.Request; _ = c.Input }
+`,
+		},
+		{
+			name:  "rename on a value skips the packages",
+			rules: []rule{rename{"", "ServeJson", "ServeJSON"}},
+			src: `package controllers
+
+import "example.com/util"
+
+func get() { util.ServeJson() }
+`,
+		},
+		{
+			name:  "import move of a subpackage",
+			rules: []rule{importMove{"github.com/goasana/asana/orm", "github.com/goasana/asana/client/orm"}},
+			src: `package models
+
+import (
+	"github.com/goasana/asana/orm"
+	"github.com/goasana/asana/orm/migration"
+)
+
+var _ = orm.NewOrm
+var _ = migration.Register
+`,
+			want: `package models
+
+import (
+	"github.com/goasana/asana/client/orm"
+	"github.com/goasana/asana/client/orm/migration"
+)
+
+var _ = orm.NewOrm
+var _ = migration.Register
+`,
+		},
+		{
+			name:  "import move renaming the package",
+			rules: []rule{importMove{"github.com/goasana/asana/orm", "github.com/goasana/orm/v2"}},
+			src: `package models
+
+import "github.com/goasana/asana/orm"
+
+var _ = orm.NewOrm
+`,
+			want: `package models
+
+import orm "github.com/goasana/orm/v2"
+
+var _ = orm.NewOrm
+`,
+		},
+		{
+			name:  "import move of a package with the prefix",
+			rules: []rule{importMove{"github.com/goasana/asana/orm", "github.com/goasana/asana/client/orm"}},
+			src: `package models
+
+import "github.com/goasana/asana/ormext"
+
+var _ = ormext.New
+`,
+		},
+		{
+			name:  "rename in the files importing a package",
+			rules: []rule{requiring{swaggerPath, rename{"", "Apis", "APIs"}}},
+			src: `package docs
+
+import "github.com/goasana/asana/swagger"
+
+var rootapi swagger.ResourceListing
+
+func paths() {
+	for _, v := range rootapi.Apis {
+		println(v.Path)
+	}
+}
+`,
+			want: `package docs
+
+import "github.com/goasana/asana/swagger"
+
+var rootapi swagger.ResourceListing
+
+func paths() {
+	for _, v := range rootapi.APIs {
+		println(v.Path)
+	}
+}
+`,
+		},
+		{
+			name:  "rename in the files not importing the package",
+			rules: []rule{requiring{swaggerPath, rename{"", "Apis", "APIs"}}},
+			src: `package main
+
+func paths(v struct{ Apis []string }) []string { return v.Apis }
+`,
+		},
+		{
+			name:  "map index to getter and setter",
+			rules: []rule{indexToCall{name: "Input.Data", get: "Data", set: "SetData"}},
+			src: `package controllers
+
+func (c *MainController) Get() {
+	c.Ctx.Input.Data["user"] = "me"
+	println(c.Ctx.Input.Data["user"])
+}
+`,
+			want: `package controllers
+
+func (c *MainController) Get() {
+	c.Ctx.Input.SetData("user", "me")
+	println(c.Ctx.Input.Data("user"))
+}
+`,
+		},
+		{
+			name:  "new argument with an import",
+			rules: []rule{signatureChange{name: "Input.CopyBody", arity: 0, args: []string{"asana.BConfig.MaxMemory"}, imports: []string{asanaPath}}},
+			src: `package controllers
+
+func (c *MainController) Post() { c.Ctx.Input.CopyBody() }
+`,
+			want: `package controllers
+
+import "github.com/goasana/asana"
+
+func (c *MainController) Post() { c.Ctx.Input.CopyBody(asana.BConfig.MaxMemory) }
+`,
+		},
+		{
+			name:  "cache put from a constructor",
+			rules: []rule{putRule},
+			src: `package main
+
+import (
+	"fmt"
+
+	"github.com/goasana/asana/cache"
+)
+
+func main() {
+	bm, err := cache.NewCache("memory", "{}")
+	fmt.Println(err, bm.Put("k", 1, 60))
+}
+`,
+			want: `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goasana/asana/cache"
+)
+
+func main() {
+	bm, err := cache.NewCache("memory", "{}")
+	fmt.Println(err, bm.Put("k", 1, 60*time.Second))
+}
+`,
+		},
+		{
+			name:  "cache put importing time in its own group",
+			rules: []rule{putRule},
+			src: `package main
+
+import (
+	"github.com/goasana/asana/cache"
+)
+
+var bm = cache.NewMemoryCache()
+
+const day = 24 * 3600
+
+func main() { _ = bm.Put("k", 1, 2*day) }
+`,
+			want: `package main
+
+import (
+	"time"
+
+	"github.com/goasana/asana/cache"
+)
+
+var bm = cache.NewMemoryCache()
+
+const day = 24 * 3600
+
+func main() { _ = bm.Put("k", 1, (2*day)*time.Second) }
+`,
+		},
+		{
+			name:  "cache put on a parameter and a field",
+			rules: []rule{putRule},
+			src: `package models
+
+import "github.com/goasana/asana/cache"
+
+type store struct {
+	bm cache.Cache
+}
+
+func (s *store) save(c cache.Cache) {
+	_ = c.Put("a", 1, 10)
+	_ = s.bm.Put("b", 2, (10 + 5))
+}
+`,
+			want: `package models
+
+import (
+	"time"
+
+	"github.com/goasana/asana/cache"
+)
+
+type store struct {
+	bm cache.Cache
+}
+
+func (s *store) save(c cache.Cache) {
+	_ = c.Put("a", 1, 10*time.Second)
+	_ = s.bm.Put("b", 2, (10+5)*time.Second)
+}
+`,
+		},
+		{
+			name:  "cache put of durations",
+			rules: []rule{putRule},
+			src: `package main
+
+import (
+	"time"
+
+	"github.com/goasana/asana/cache"
+)
+
+const ttl time.Duration = 60
+
+func save(bm cache.Cache, timeout time.Duration) {
+	_ = bm.Put("a", 1, 10*time.Second)
+	_ = bm.Put("b", 2, timeout)
+	_ = bm.Put("c", 3, ttl)
+	_ = bm.Put("d", 4, 1.5)
+}
+`,
+		},
+		{
+			name:  "put of other types",
+			rules: []rule{putRule},
+			src: `package main
+
+import "github.com/goasana/asana/cache"
+
+var _ cache.Cache
+
+type table map[string]int
+
+func (t table) Put(k string, v, n int) error { return nil }
+
+func main() {
+	var t table
+	_ = t.Put("a", 1, 2)
+	s, _ := newStore()
+	_ = s.Put("b", 2, 3)
+}
+`,
+		},
+		{
+			name:  "cache put without the cache package",
+			rules: []rule{putRule},
+			src: `package main
+
+func save(bm Cache) { _ = bm.Put("a", 1, 10) }
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			res, changes, err := fixSource("test.go", []byte(tt.src), tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(res) != want {
+				t.Errorf("got:\n%s\nwant:\n%s", res, want)
+			}
+			if changed := tt.want != ""; changed != (len(changes) > 0) {
+				t.Errorf("changes reported: %v", changes)
+			}
+		})
+	}
+}
+
+func TestIsUntypedInt(t *testing.T) {
+	src := `package main
+
+import "time"
+
+const (
+	a = 1
+	b = a * 60
+	c = iota
+	d time.Duration = 1
+	e = 1.5
+	f = "1"
+)
+
+var v = 1
+
+func main() {
+	_ = []interface{}{
+		1, -1, 0x10, (2 + 3) * 4, 1 << 3, a, b, c, 7 / 2,
+		d, e, f, v, 1.5, "1", a == b, time.Second, len("a"), 1 << v,
+	}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fx := &file{fset: fset, ast: f}
+	var elts []ast.Expr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.CompositeLit); ok {
+			elts = lit.Elts
+		}
+		return true
+	})
+	// the first ones are untyped integer constants
+	want := 9
+	for i, e := range elts {
+		if got := fx.isUntypedInt(e); got != (i < want) {
+			t.Errorf("isUntypedInt(%s) = %v", src[fset.Position(e.Pos()).Offset:fset.Position(e.End()).Offset], got)
+		}
+	}
+	if len(elts) != 19 {
+		t.Fatalf("%d expressions", len(elts))
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0", 0},
+		{"0.9.0", "1.0.0", -1},
+		{"1.10.0", "1.9.1", 1},
+		{"1.0.0-rc1", "1.0.0", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if rules := rulesBetween("1.0.0", latest()); len(rules) != 0 {
+		t.Errorf("%d rules to upgrade from the latest version", len(rules))
+	}
+	if rules := rulesBetween("0", latest()); len(rules) == 0 {
+		t.Error("no rules to upgrade from 0")
+	}
+}
@@ -19,20 +19,18 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/goasana/asanacli/cmd/commands"
 	"github.com/goasana/asanacli/cmd/commands/version"
 	"github.com/goasana/asanacli/config"
 	asanaLogger "github.com/goasana/asanacli/logger"
-	"github.com/goasana/asanacli/utils"
 )
 
 var CmdBale = &commands.Command{
-	UsageLine: "bale",
+	UsageLine: "bale [-embed] [-compress=gzip,br]",
 	Short:     "Transforms non-Go files to Go source files",
 	Long: `Bale command compress all the static files in to a single binary file.
 
@@ -41,44 +39,45 @@ var CmdBale = &commands.Command{
 
   It will auto-generate an unpack function to the main package then run it during the runtime.
   This is mainly used for zealots who are requiring 100% Go code.
+
+  {{"To embed the files with go:embed and serve them from memory:"|bold}}
+    $ asanacli bale -embed -compress=gzip,br
+
+  The bale package then holds the files of bale.dirs in bale.FS, an fs.FS, and bale.FileSystem(),
+  an http.FileSystem. bale.Handler("/static/") serves static/ with the pre-compressed variants,
+  and bale.HashedName gives the names with a content hash, served as immutable.
+  The mode and the compressions can also be set with bale.mode: embed and bale.compress
+  in the Asanafile. Brotli needs the brotli program.
+
+  Only the files which changed since the last bale are written again, from the hashes recorded
+  in bale/manifest.json. The run command bales bale.dirs again when their files change.
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    runBale,
 }
 
+var (
+	embedMode bool
+	compress  string
+)
+
 func init() {
+	CmdBale.Flag.BoolVar(&embedMode, "embed", false, "Generate a go:embed package instead of unpacking the files at startup.")
+	CmdBale.Flag.StringVar(&compress, "compress", "", "Set the pre-compressed variants of the embed mode, separated by commas: gzip, br.")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdBale)
 }
 
 func runBale(_ *commands.Command, _ []string) int {
-	_ = os.RemoveAll("bale")
-	_ = os.Mkdir("bale", os.ModePerm)
-
-	// Pack and compress data
-	for _, p := range config.Conf.Bale.Dirs {
-		if !utils.IsExist(p) {
-			asanaLogger.Log.Warnf("Skipped directory: %s", p)
-			continue
-		}
-		asanaLogger.Log.Infof("Packaging directory: %s", p)
-		_ = filepath.Walk(p, walkFn)
+	compressions := config.Conf.Bale.Compress
+	if compress != "" {
+		compressions = strings.Split(compress, ",")
 	}
-
-	// Generate auto-uncompress function.
-	buf := new(bytes.Buffer)
-	buf.WriteString(fmt.Sprintf(BaleHeader, config.Conf.Bale.Import,
-		strings.Join(resFiles, "\",\n\t\t\""),
-		strings.Join(resFiles, ",\n\t\tbale.R")))
-
-	fw, err := os.Create("bale.go")
+	changed, err := update(embedMode || config.Conf.Bale.Mode == "embed", compressions)
 	if err != nil {
-		asanaLogger.Log.Fatalf("Failed to create file: %s", err)
+		asanaLogger.Log.Fatalf("Failed to bale: %s", err)
 	}
-	defer fw.Close()
-
-	_, err = fw.Write(buf.Bytes())
-	if err != nil {
-		asanaLogger.Log.Fatalf("Failed to write data: %s", err)
+	if !changed {
+		asanaLogger.Log.Info("The baled resources are up to date")
 	}
 
 	asanaLogger.Log.Success("Baled resources successfully!")
@@ -140,51 +139,62 @@ func saveFile(filePath string, b []byte) (int, error) {
 `
 )
 
-var resFiles = make([]string, 0, 10)
-
-func walkFn(resPath string, info os.FileInfo, _ error) error {
-	if info.IsDir() || filterSuffix(resPath) {
-		return nil
-	}
-
-	// Open resource files
-	fr, err := os.Open(resPath)
-	if err != nil {
-		asanaLogger.Log.Fatalf("Failed to read file: %s", err)
-	}
-
-	// Convert path
-	resPath = strings.Replace(resPath, "_", "_0_", -1)
-	resPath = strings.Replace(resPath, ".", "_1_", -1)
-	resPath = strings.Replace(resPath, "-", "_2_", -1)
-	resPath = strings.Replace(resPath, " ", "_3_", -1)
-	sep := "/"
-	if runtime.GOOS == "windows" {
-		sep = "\\"
-	}
-	resPath = strings.Replace(resPath, sep, "_4_", -1)
+// unpackBaler writes a Go source file per file in bale/, and bale.go in
+// the main package which unpacks them at startup
+type unpackBaler struct{}
+
+// resName converts a path to the name of its resource function
+func resName(name string) string {
+	name = strings.Replace(name, "_", "_0_", -1)
+	name = strings.Replace(name, ".", "_1_", -1)
+	name = strings.Replace(name, "-", "_2_", -1)
+	name = strings.Replace(name, " ", "_3_", -1)
+	return strings.Replace(name, "/", "_4_", -1)
+}
 
-	// Create corresponding Go source files
-	_ = os.MkdirAll(path.Dir(resPath), os.ModePerm)
-	fw, err := os.Create("bale/" + resPath + ".go")
-	if err != nil {
-		asanaLogger.Log.Fatalf("Failed to create file: %s", err)
-	}
-	defer fw.Close()
+func (b unpackBaler) write(name string, data []byte) (source, error) {
+	var buf bytes.Buffer
 
 	// Write header
-	_, _ = fmt.Fprintf(fw, Header, resPath)
+	_, _ = fmt.Fprintf(&buf, Header, resName(name))
 
 	// Copy and compress data
-	gz := gzip.NewWriter(&ByteWriter{Writer: fw})
-	_, _ = io.Copy(gz, fr)
+	gz := gzip.NewWriter(&ByteWriter{Writer: &buf})
+	_, _ = gz.Write(data)
 	_ = gz.Close()
 
 	// Write footer.
-	_, _ = fmt.Fprint(fw, Footer)
+	_, _ = fmt.Fprint(&buf, Footer)
+
+	_, err := writeIfChanged(b.output(name), buf.Bytes())
+	return source{}, err
+}
+
+func (b unpackBaler) output(name string) string {
+	return filepath.Join("bale", resName(name)+".go")
+}
 
-	resFiles = append(resFiles, resPath)
-	return nil
+func (b unpackBaler) remove(name string) {
+	_ = os.Remove(b.output(name))
+}
+
+// finish writes the auto-uncompress function in bale.go
+func (b unpackBaler) finish(files map[string]source) (bool, error) {
+	resFiles := make([]string, 0, len(files))
+	for name := range files {
+		resFiles = append(resFiles, resName(name))
+	}
+	sort.Strings(resFiles)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf(BaleHeader, config.Conf.Bale.Import,
+		strings.Join(resFiles, "\",\n\t\t\""),
+		strings.Join(resFiles, ",\n\t\tbale.R")))
+	written, err := writeIfChanged(unpackFile, buf.Bytes())
+	if err != nil {
+		return false, fmt.Errorf("failed to write bale.go: %s", err)
+	}
+	return written, nil
 }
 
 func filterSuffix(name string) bool {
@@ -0,0 +1,354 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bale
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+)
+
+// The embed mode writes the bale package: the files of the baled
+// directories under bale/assets, their compressed variants under bale/gzip
+// and bale/br, and bale/bale.go which embeds them.
+const (
+	assetsDir = "assets"
+	gzipDir   = "gzip"
+	brotliDir = "br"
+)
+
+// incompressible are the extensions of the files which are not compressed
+var incompressible = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true,
+	".woff": true, ".woff2": true, ".mp3": true, ".mp4": true, ".webm": true, ".ogg": true,
+	".zip": true, ".gz": true, ".br": true, ".zst": true, ".xz": true, ".7z": true, ".pdf": true,
+}
+
+// asset is a baled file
+type asset struct {
+	Name string // slash separated path, relative to the application
+	Hash string // content hash, for cache busting
+	Gzip bool   // has a gzip variant
+	Br   bool   // has a brotli variant
+}
+
+// embedBaler writes the bale package in embed mode
+type embedBaler struct {
+	gzip   bool // writes the gzip variants
+	brotli bool // writes the brotli variants
+}
+
+func newEmbedBaler(compress []string) (embedBaler, error) {
+	var e embedBaler
+	for _, c := range compress {
+		switch strings.TrimSpace(c) {
+		case "gzip":
+			e.gzip = true
+		case "br", "brotli":
+			e.brotli = true
+		case "":
+		default:
+			return e, fmt.Errorf("unknown compression '%s', must be gzip or br", c)
+		}
+	}
+	if _, err := exec.LookPath("brotli"); e.brotli && err != nil {
+		asanaLogger.Log.Warn("brotli not found in PATH, skipping the brotli variants")
+		e.brotli = false
+	}
+	return e, nil
+}
+
+// compressions returns the variants written, as recorded in the manifest
+func (e embedBaler) compressions() []string {
+	var c []string
+	if e.gzip {
+		c = append(c, "gzip")
+	}
+	if e.brotli {
+		c = append(c, "br")
+	}
+	return c
+}
+
+// write copies a file into the bale package, with its compressed variants
+func (e embedBaler) write(name string, data []byte) (source, error) {
+	var s source
+	if err := writeBaleFile(assetsDir, name, data); err != nil {
+		return s, err
+	}
+	compressible := !incompressible[strings.ToLower(path.Ext(name))]
+	if e.gzip && compressible {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		_, _ = gz.Write(data)
+		_ = gz.Close()
+		if s.Gzip = buf.Len() < len(data); s.Gzip {
+			if err := writeBaleFile(gzipDir, name, buf.Bytes()); err != nil {
+				return s, err
+			}
+		}
+	}
+	if e.brotli && compressible {
+		cmd := exec.Command("brotli", "-c", "-q", "11")
+		cmd.Stdin = bytes.NewReader(data)
+		out, err := cmd.Output()
+		if err != nil {
+			return s, fmt.Errorf("brotli failed: %s", err)
+		}
+		if s.Br = len(out) < len(data); s.Br {
+			if err := writeBaleFile(brotliDir, name, out); err != nil {
+				return s, err
+			}
+		}
+	}
+	// The variants of the previous content may not be smaller anymore
+	if !s.Gzip {
+		removeBaleFile(gzipDir, name)
+	}
+	if !s.Br {
+		removeBaleFile(brotliDir, name)
+	}
+	return s, nil
+}
+
+func (e embedBaler) output(name string) string {
+	return filepath.Join("bale", assetsDir, filepath.FromSlash(name))
+}
+
+func (e embedBaler) remove(name string) {
+	for _, dir := range []string{assetsDir, gzipDir, brotliDir} {
+		removeBaleFile(dir, name)
+	}
+}
+
+// finish writes bale/bale.go
+func (e embedBaler) finish(files map[string]source) (bool, error) {
+	assets := make([]asset, 0, len(files))
+	for name, s := range files {
+		assets = append(assets, asset{Name: name, Hash: s.Hash[:10], Gzip: s.Gzip, Br: s.Br})
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Name < assets[j].Name })
+	if len(assets) == 0 {
+		// go:embed needs a file to match
+		asanaLogger.Log.Warn("No file to bale")
+		if err := writeBaleFile(assetsDir, ".keep", nil); err != nil {
+			return false, err
+		}
+	} else {
+		removeBaleFile(assetsDir, ".keep")
+	}
+
+	written, err := writeEmbedFile(assets)
+	if err != nil {
+		return false, fmt.Errorf("failed to write bale/bale.go: %s", err)
+	}
+	return written, nil
+}
+
+func writeBaleFile(dir, name string, data []byte) error {
+	fpath := filepath.Join("bale", dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, data, 0644)
+}
+
+// removeBaleFile removes a file of the bale package, and its parent
+// directories once empty
+func removeBaleFile(dir, name string) {
+	root := filepath.Join("bale", dir)
+	fpath := filepath.Join(root, filepath.FromSlash(name))
+	if os.Remove(fpath) != nil {
+		return
+	}
+	for d := filepath.Dir(fpath); d != root; d = filepath.Dir(d) {
+		if os.Remove(d) != nil {
+			return
+		}
+	}
+}
+
+func writeEmbedFile(assets []asset) (bool, error) {
+	data := struct {
+		Assets []asset
+		Gzip   bool
+		Br     bool
+	}{Assets: assets}
+	for _, a := range assets {
+		data.Gzip = data.Gzip || a.Gzip
+		data.Br = data.Br || a.Br
+	}
+
+	var buf bytes.Buffer
+	if err := template.Must(template.New("bale").Parse(embedTemplate)).Execute(&buf, data); err != nil {
+		return false, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return false, err
+	}
+	return writeIfChanged(filepath.Join("bale", "bale.go"), src)
+}
+
+const embedTemplate = `// Code generated by asanacli bale. DO NOT EDIT.
+
+// Package bale embeds the static files of the application, to serve them
+// from memory.
+package bale
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed all:assets
+var assets embed.FS
+{{if .Gzip}}
+//go:embed all:gzip
+var gzipAssets embed.FS
+
+var gzipFS = sub(gzipAssets, "gzip")
+{{end}}{{if .Br}}
+//go:embed all:br
+var brAssets embed.FS
+
+var brFS = sub(brAssets, "br")
+{{end}}
+// FS holds the baled files by their path in the application,
+// e.g. static/js/app.js
+var FS fs.FS = sub(assets, "assets")
+
+// Hashes holds the content hashes of the files, for cache busting
+var Hashes = map[string]string{
+{{- range .Assets}}
+	{{printf "%q" .Name}}: {{printf "%q" .Hash}},
+{{- end}}
+}
+
+// variants holds the files with pre-compressed variants
+var variants = map[string]uint8{
+{{- range .Assets}}{{if or .Gzip .Br}}
+	{{printf "%q" .Name}}: {{if .Gzip}}1{{else}}0{{end}}{{if .Br}} | 2{{end}},
+{{- end}}{{end}}
+}
+
+func sub(fsys fs.FS, dir string) fs.FS {
+	f, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// FileSystem returns FS as an http.FileSystem
+func FileSystem() http.FileSystem {
+	return http.FS(FS)
+}
+
+// HashedName returns the name of a file with its content hash, e.g.
+// static/js/app.1a2b3c4d5e.js, which Handler serves as immutable
+func HashedName(name string) string {
+	hash, ok := Hashes[name]
+	if !ok {
+		return name
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// unhash returns the name of a file from its hashed name
+func unhash(name string) (string, bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	i := strings.LastIndex(base, ".")
+	if i < 0 {
+		return name, false
+	}
+	orig := base[:i] + ext
+	if hash, ok := Hashes[orig]; ok && hash == base[i+1:] {
+		return orig, true
+	}
+	return name, false
+}
+
+// Handler serves the baled files under prefix, e.g. Handler("/static/")
+// for the files of static/. The pre-compressed variants are served to the
+// clients which accept them.
+func Handler(prefix string) http.Handler {
+	dir := strings.Trim(prefix, "/")
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Join(dir, path.Clean("/"+r.URL.Path)[1:])
+		name, immutable := unhash(name)
+		if _, ok := Hashes[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if immutable {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		fsys, encoding := FS, ""
+		if v := variants[name]; v != 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+{{- if or .Br .Gzip}}
+			accept := r.Header.Get("Accept-Encoding")
+{{- end}}
+{{- if .Br}}
+			if v&2 != 0 && strings.Contains(accept, "br") {
+				fsys, encoding = brFS, "br"
+			}
+{{- end}}
+{{- if .Gzip}}
+			if encoding == "" && v&1 != 0 && strings.Contains(accept, "gzip") {
+				fsys, encoding = gzipFS, "gzip"
+			}
+{{- end}}
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		etag := Hashes[name]
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			etag += "-" + encoding
+		}
+		w.Header().Set("ETag", strconv.Quote(etag))
+		if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+		info, _ := f.Stat()
+		http.ServeContent(w, r, name, info.ModTime(), f.(io.ReadSeeker))
+	}))
+}
+`
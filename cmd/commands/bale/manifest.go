@@ -0,0 +1,228 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bale
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goasana/asanacli/config"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+// manifestFile records the files of the last bale, so that only the files
+// which changed since are written again
+var manifestFile = filepath.Join("bale", "manifest.json")
+
+// unpackFile is the file of the unpack mode restoring the resources, in the
+// package main of the application
+const unpackFile = "bale.go"
+
+type manifest struct {
+	Mode     string            `json:"mode"`
+	Compress []string          `json:"compress,omitempty"`
+	Files    map[string]source `json:"files"`
+}
+
+// source is a baled file, keyed by its slash separated path
+type source struct {
+	Hash string `json:"hash"` // sha256 of the content
+	Gzip bool   `json:"gzip,omitempty"`
+	Br   bool   `json:"br,omitempty"`
+}
+
+// baler writes the files of a bale mode
+type baler interface {
+	// write writes the outputs of a new or changed file
+	write(name string, data []byte) (source, error)
+	// output returns the path of the main output of a file
+	output(name string) string
+	// remove removes the outputs of a deleted file
+	remove(name string)
+	// finish writes the file which references the baled files, and
+	// reports whether it changed
+	finish(files map[string]source) (bool, error)
+}
+
+// Update bales the directories of the Asanafile, writing only the files
+// which changed since the last bale. It reports whether anything changed.
+// The mode and the variants not set by the Asanafile are the ones of the
+// last bale, e.g. of asana bale -embed.
+func Update() (bool, error) {
+	mode, compress := config.Conf.Bale.Mode, config.Conf.Bale.Compress
+	if old := readManifest(); old != nil {
+		if mode == "" {
+			mode = old.Mode
+		}
+		if len(compress) == 0 && mode == old.Mode {
+			compress = old.Compress
+		}
+	}
+	return update(mode == "embed", compress)
+}
+
+func update(embed bool, compress []string) (bool, error) {
+	var b baler = unpackBaler{}
+	m := manifest{Mode: "unpack", Files: map[string]source{}}
+	if embed {
+		e, err := newEmbedBaler(compress)
+		if err != nil {
+			return false, err
+		}
+		b, m.Mode, m.Compress = e, "embed", e.compressions()
+	}
+
+	// Everything is baled again when the mode or the variants change
+	old := readManifest()
+	if old == nil || old.Mode != m.Mode || strings.Join(old.Compress, ",") != strings.Join(m.Compress, ",") {
+		_ = os.RemoveAll("bale")
+		// bale.go of the unpack mode calls the removed package
+		if embed && (old != nil && old.Mode == "unpack" || old == nil && isUnpackFile()) {
+			if err := os.Remove(unpackFile); err != nil && !os.IsNotExist(err) {
+				return false, err
+			}
+		}
+		old = &manifest{Files: map[string]source{}}
+	}
+
+	names, err := sources(embed)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.FromSlash(name))
+		if err != nil {
+			return changed, err
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		if s, ok := old.Files[name]; ok && s.Hash == hash && utils.IsExist(b.output(name)) {
+			m.Files[name] = s
+			continue
+		}
+		s, err := b.write(name, data)
+		if err != nil {
+			return changed, fmt.Errorf("failed to bale '%s': %s", name, err)
+		}
+		s.Hash = hash
+		m.Files[name] = s
+		changed = true
+	}
+	for name := range old.Files {
+		if _, ok := m.Files[name]; !ok {
+			b.remove(name)
+			changed = true
+		}
+	}
+
+	written, err := b.finish(m.Files)
+	if err != nil {
+		return changed, err
+	}
+	if err := writeManifest(m); err != nil {
+		return changed, err
+	}
+	return changed || written, nil
+}
+
+// isUnpackFile reports whether bale.go was written by the unpack mode, for
+// the bales older than the manifest
+func isUnpackFile() bool {
+	data, err := ioutil.ReadFile(unpackFile)
+	return err == nil && bytes.Contains(data, []byte("bale.R"))
+}
+
+// sources returns the files of the baled directories, sorted. The files of
+// the embed mode must be in the application.
+func sources(embed bool) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range config.Conf.Bale.Dirs {
+		if !utils.IsExist(dir) {
+			asanaLogger.Log.Warnf("Skipped directory: %s", dir)
+			continue
+		}
+		if rel := filepath.ToSlash(filepath.Clean(dir)); embed && (filepath.IsAbs(dir) || rel == ".." || strings.HasPrefix(rel, "../")) {
+			asanaLogger.Log.Warnf("Skipped directory: %s, it must be in the application", dir)
+			continue
+		}
+		asanaLogger.Log.Infof("Packaging directory: %s", dir)
+		err := filepath.Walk(dir, func(fpath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filterSuffix(fpath) {
+				return nil
+			}
+			if name := filepath.ToSlash(filepath.Clean(fpath)); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to bale '%s': %s", dir, err)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readManifest() *manifest {
+	data, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		return nil
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		asanaLogger.Log.Warnf("Invalid %s, baling everything again: %s", manifestFile, err)
+		return nil
+	}
+	if m.Files == nil {
+		m.Files = map[string]source{}
+	}
+	return &m
+}
+
+func writeManifest(m manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writeIfChanged(manifestFile, append(data, '\n'))
+	return err
+}
+
+// writeIfChanged writes a file unless it already has this content, and
+// reports whether it was written
+func writeIfChanged(fpath string, data []byte) (bool, error) {
+	if old, err := ioutil.ReadFile(fpath); err == nil && bytes.Equal(old, data) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(fpath, data, 0644)
+}
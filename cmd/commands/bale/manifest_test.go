@@ -0,0 +1,90 @@
+package bale
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goasana/asanacli/config"
+)
+
+// inApp runs the test in a new application with a static directory
+func inApp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := config.Conf.Bale
+	t.Cleanup(func() {
+		config.Conf.Bale = conf
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(dir)
+	})
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("static", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	js := strings.Repeat("console.log('app')\n", 100)
+	if err := ioutil.WriteFile(filepath.Join("static", "app.js"), []byte(js), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.Bale.Mode = ""
+	config.Conf.Bale.Compress = nil
+	config.Conf.Bale.Dirs = []string{"static"}
+}
+
+func TestUpdateKeepsEmbedBale(t *testing.T) {
+	inApp(t)
+
+	// asana bale -embed -compress=gzip
+	if _, err := update(true, []string{"gzip"}); err != nil {
+		t.Fatal(err)
+	}
+	// asana run with an Asanafile not setting the mode
+	changed, err := Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("the bale changed without any change of the files")
+	}
+	m := readManifest()
+	if m == nil || m.Mode != "embed" || !reflect.DeepEqual(m.Compress, []string{"gzip"}) {
+		t.Fatalf("the embed bale was not kept: %+v", m)
+	}
+	for _, fpath := range []string{
+		filepath.Join("bale", assetsDir, "static", "app.js"),
+		filepath.Join("bale", gzipDir, "static", "app.js"),
+	} {
+		if _, err := os.Stat(fpath); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(unpackFile); !os.IsNotExist(err) {
+		t.Errorf("%s of the unpack mode was written", unpackFile)
+	}
+}
+
+func TestUpdateFollowsConfig(t *testing.T) {
+	inApp(t)
+
+	if _, err := update(true, []string{"gzip"}); err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.Bale.Mode = "unpack"
+	if _, err := Update(); err != nil {
+		t.Fatal(err)
+	}
+	if m := readManifest(); m == nil || m.Mode != "unpack" || len(m.Compress) != 0 {
+		t.Fatalf("the mode of the Asanafile was not applied: %+v", m)
+	}
+}
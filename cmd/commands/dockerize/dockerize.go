@@ -15,10 +15,11 @@
 package dockerize
 
 import (
+	"bufio"
 	"flag"
 	"os"
-	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -28,58 +29,154 @@ import (
 	"github.com/goasana/asanacli/utils"
 )
 
-const dockerBuildTemplate = `FROM {{.BaseImage}}
+const dockerBuildTemplate = `# syntax=docker/dockerfile:1
+# Generated by asanacli dockerize, build it with BuildKit:
+#   docker build -t {{.Entrypoint}} .
 
-# Godep for vendoring
-RUN go get github.com/tools/godep
+FROM {{.BaseImage}} AS build
+WORKDIR /src
+{{if .Vendor}}
+# The dependencies are vendored
+{{else}}
+# Download the dependencies first, they are cached until go.mod changes
+COPY go.mod {{if .GoSum}}go.sum {{end}}./
+RUN --mount=type=cache,target=/go/pkg/mod \
+    go mod download
+{{end}}
+COPY . .
+RUN --mount=type=cache,target=/go/pkg/mod \
+    --mount=type=cache,target=/root/.cache/go-build \
+    CGO_ENABLED=0 go build -trimpath -ldflags "-s -w" -o /out/{{.Entrypoint}} .
+{{if .HealthCheck}}
+# A static probe, as the runtime image may have neither a shell nor curl
+RUN mkdir -p /healthcheck && cat > /healthcheck/main.go <<'EOF'
+package main
 
-# Recompile the standard library without CGO
-RUN CGO_ENABLED=0 go install -a std
-
-ENV APP_DIR $GOPATH{{.Appdir}}
-RUN mkdir -p $APP_DIR
-
-# Set the entrypoint
-ENTRYPOINT (cd $APP_DIR && ./{{.Entrypoint}})
-ADD . $APP_DIR
+import (
+	"net/http"
+	"os"
+	"time"
+)
 
-# Compile the binary and statically link
-RUN cd $APP_DIR && CGO_ENABLED=0 godep go build -ldflags '-d -w -s'
+func main() {
+	client := http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(os.Args[1])
+	if err != nil || resp.StatusCode >= 400 {
+		os.Exit(1)
+	}
+}
+EOF
+RUN cd /healthcheck && CGO_ENABLED=0 go build -o /out/healthcheck main.go
+{{end}}
+FROM {{.RuntimeImage}}
+{{- if eq .Runtime "alpine"}}
+RUN apk add --no-cache ca-certificates tzdata && \
+    adduser -D -H -u 65532 nonroot
+{{- else if eq .Runtime "scratch"}}
+COPY --from=build /etc/ssl/certs/ca-certificates.crt /etc/ssl/certs/
+COPY --from=build /usr/share/zoneinfo /usr/share/zoneinfo
+{{- end}}
+WORKDIR /app
+COPY --from=build /out/ /app/
+{{- range .Dirs}}
+COPY {{.}}/ /app/{{.}}/
+{{- end}}
 
+USER 65532:65532
+{{- if .Expose}}
 EXPOSE {{.Expose}}
+{{- end}}
+{{- if .HealthCheck}}
+HEALTHCHECK --interval=30s --timeout=5s --start-period=10s --retries=3 \
+    CMD ["/app/healthcheck", "{{.HealthCheck}}"]
+{{- end}}
+ENTRYPOINT ["/app/{{.Entrypoint}}"]
 `
 
+const dockerIgnoreTemplate = `# Generated by asanacli dockerize
+.git
+.gitignore
+.dockerignore
+Dockerfile
+docker-compose.yml
+k8s/
+*.tar.gz
+*.zip
+*.tmp
+.DS_Store
+/{{.Entrypoint}}
+`
+
+// runtimeImages are the default images of the runtimes
+var runtimeImages = map[string]string{
+	"distroless": "gcr.io/distroless/static-debian12:nonroot",
+	"scratch":    "scratch",
+	"alpine":     "alpine:3",
+}
+
 // Dockerfile holds the information about the Docker container.
 type Dockerfile struct {
-	BaseImage  string
-	Appdir     string
-	Entrypoint string
-	Expose     string
+	BaseImage    string
+	Runtime      string
+	RuntimeImage string
+	Entrypoint   string
+	Expose       string
+	GoSum        bool
+	Vendor       bool
+	Dirs         []string // directories copied next to the binary
+	HealthCheck  string   // URL probed by the healthcheck
 }
 
 var CmdDockerize = &commands.Command{
 	CustomFlags: true,
 	UsageLine:   "dockerize",
 	Short:       "Generates a Dockerfile for your Asana application",
-	Long: `Dockerize generates a Dockerfile for your Asana Web Application.
-  The Dockerfile will compile, get the dependencies with {{"godep"|bold}}, and set the entrypoint.
+	Long: `Dockerize generates a multi-stage Dockerfile and a .dockerignore for your Asana Web Application.
+  The application is built with Go modules, caching the dependencies and the build between
+  builds, and runs as a non-root user in a small runtime image, with conf/, views/ and static/.
 
   {{"Example:"|bold}}
     $ asanacli dockerize -expose="3000,80,25"
+
+  {{"To run on alpine, building with a given Go image:"|bold}}
+    $ asanacli dockerize -image=golang:1.22 -runtime=alpine
+
+  The build image defaults to the Go version of go.mod. The runtime is distroless, scratch or
+  alpine, its image can be set with -runtime-image. The healthcheck requests -healthcheck on the
+  first exposed port, -healthcheck="" disables it. An existing .dockerignore is kept.
+
+  {{"To also generate a compose stack and Kubernetes manifests:"|bold}}
+    $ asanacli dockerize -compose -k8s -expose=8080
+
+  The compose stack runs the database of the Asanafile driver (mysql or postgres) and passes
+  its connection string to the application in the SQLCONN environment variable, and as the
+  sqlconn of the conf/app.yaml mounted in its container. The k8s/
+  manifests mount conf/app.yaml from a ConfigMap and probe the -healthcheck path. Their root
+  filesystem is read-only, /tmp and /app/logs are writable volumes.
   `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    dockerizeApp,
 }
 
 var (
-	expose    string
-	baseImage string
+	expose       string
+	baseImage    string
+	flavour      string
+	runtimeImage string
+	healthCheck  string
+	compose      bool
+	k8s          bool
 )
 
 func init() {
 	fs := flag.NewFlagSet("dockerize", flag.ContinueOnError)
-	fs.StringVar(&baseImage, "image", "library/golang", "Set the base image of the Docker container.")
+	fs.StringVar(&baseImage, "image", "", "Set the image of the build stage. Defaults to golang with the Go version of go.mod.")
+	fs.StringVar(&flavour, "runtime", "distroless", "Set the runtime image flavour: distroless, scratch or alpine.")
+	fs.StringVar(&runtimeImage, "runtime-image", "", "Set the image of the runtime stage. Defaults to the image of the runtime flavour.")
+	fs.StringVar(&healthCheck, "healthcheck", "/", "Set the path requested by the healthcheck. Empty to disable it.")
 	fs.StringVar(&expose, "expose", "8080", "Port(s) to expose in the Docker container.")
+	fs.BoolVar(&compose, "compose", false, "Generate a docker-compose.yml with the application and its database.")
+	fs.BoolVar(&k8s, "k8s", false, "Generate the Kubernetes Deployment, Service and ConfigMap in k8s/.")
 	CmdDockerize.Flag = *fs
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdDockerize)
 }
@@ -91,43 +188,111 @@ func dockerizeApp(cmd *commands.Command, args []string) int {
 
 	asanaLogger.Log.Info("Generating Dockerfile...")
 
-	gopath := os.Getenv("GOPATH")
 	dir, err := filepath.Abs(".")
 	if err != nil {
 		asanaLogger.Log.Error(err.Error())
 	}
+	if !utils.IsExist(filepath.Join(dir, "go.mod")) {
+		asanaLogger.Log.Fatal("go.mod not found, the application must be a Go module. Run: go mod init")
+	}
 
-	appdir := strings.Replace(dir, gopath, "", 1)
+	if _, ok := runtimeImages[flavour]; !ok {
+		asanaLogger.Log.Fatalf("Unknown runtime '%s', must be distroless, scratch or alpine", flavour)
+	}
+	if runtimeImage == "" {
+		runtimeImage = runtimeImages[flavour]
+	}
+	if baseImage == "" {
+		baseImage = "golang:" + goVersion(filepath.Join(dir, "go.mod"))
+	}
 
 	// In case of multiple ports to expose inside the container,
 	// replace all the commas with whitespaces.
 	// See the verb EXPOSE in the Docker documentation.
-	if strings.Contains(expose, ",") {
-		expose = strings.Replace(expose, ",", " ", -1)
-	}
+	ports := strings.Fields(strings.Replace(expose, ",", " ", -1))
 
-	_, entrypoint := path.Split(appdir)
 	dockerfile := Dockerfile{
-		BaseImage:  baseImage,
-		Appdir:     appdir,
-		Entrypoint: entrypoint,
-		Expose:     expose,
+		BaseImage:    baseImage,
+		Runtime:      flavour,
+		RuntimeImage: runtimeImage,
+		Entrypoint:   filepath.Base(dir),
+		Expose:       strings.Join(ports, " "),
+		GoSum:        utils.IsExist(filepath.Join(dir, "go.sum")),
+		Vendor:       utils.IsExist(filepath.Join(dir, "vendor", "modules.txt")),
+	}
+	for _, d := range []string{"conf", "views", "static"} {
+		if fi, err := os.Stat(filepath.Join(dir, d)); err == nil && fi.IsDir() {
+			dockerfile.Dirs = append(dockerfile.Dirs, d)
+		}
+	}
+	if healthCheck != "" && len(ports) > 0 {
+		port := strings.SplitN(ports[0], "/", 2)[0]
+		dockerfile.HealthCheck = "http://127.0.0.1:" + port + "/" + strings.TrimPrefix(healthCheck, "/")
 	}
 
 	generateDockerfile(dockerfile)
+
+	if compose || k8s {
+		s := newStack(dir, dockerfile.Entrypoint, ports, healthCheck)
+		if compose {
+			generateCompose(s)
+		}
+		if k8s {
+			generateK8s(s)
+		}
+	}
 	return 0
 }
 
+// goVersion returns the Go version of go.mod, its toolchain if any
+func goVersion(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "1"
+	}
+	defer utils.CloseFile(f)
+	v := "1"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			v = fields[1]
+		case "toolchain":
+			return strings.TrimPrefix(fields[1], "go")
+		}
+	}
+	return v
+}
+
 func generateDockerfile(df Dockerfile) {
-	t := template.Must(template.New("dockerBuildTemplate").Parse(dockerBuildTemplate)).Funcs(utils.AsanaFuncMap())
+	writeTemplate("Dockerfile", dockerBuildTemplate, df, true)
+	writeTemplate(".dockerignore", dockerIgnoreTemplate, df, false)
+	asanaLogger.Log.Success("Dockerfile generated.")
+}
 
-	f, err := os.Create("Dockerfile")
+// writeTemplate writes a file from a template, unless it exists and
+// overwrite is false
+func writeTemplate(name, tmpl string, data interface{}, overwrite bool) {
+	if !overwrite && utils.IsExist(name) {
+		asanaLogger.Log.Infof("%s exists, skipping", name)
+		return
+	}
+	funcs := utils.AsanaFuncMap()
+	funcs["quote"] = strconv.Quote
+	funcs["indent"] = indent
+	t := template.Must(template.New(name).Funcs(funcs).Parse(tmpl))
+
+	f, err := os.Create(name)
 	if err != nil {
-		asanaLogger.Log.Fatalf("Error writing Dockerfile: %v", err.Error())
+		asanaLogger.Log.Fatalf("Error writing %s: %v", name, err.Error())
 	}
 	defer utils.CloseFile(f)
 
-	_ = t.Execute(f, df)
-
-	asanaLogger.Log.Success("Dockerfile generated.")
+	if err := t.Execute(f, data); err != nil {
+		asanaLogger.Log.Fatalf("Error writing %s: %v", name, err.Error())
+	}
 }
@@ -0,0 +1,374 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package dockerize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asanacli/config"
+	asanaLogger "github.com/goasana/asanacli/logger"
+)
+
+const composeTemplate = `# Generated by asanacli dockerize, run it with:
+#   docker compose up --build
+services:
+  app:
+    build: .
+    image: {{.Name}}:latest
+{{- if .Ports}}
+    ports:
+{{- range .Ports}}
+      - {{quote (print .Number ":" .Number (.Suffix))}}
+{{- end}}
+{{- end}}
+{{- if .DB}}
+    environment:
+      DB_DRIVER: {{quote .DB.Driver}}
+      SQLCONN: {{quote .DB.Conn}}
+    configs:
+      - source: app-conf
+        target: /app/conf/app.yaml
+    depends_on:
+      db:
+        condition: service_healthy
+{{- end}}
+    restart: unless-stopped
+{{- if .DB}}
+
+  db:
+    image: {{.DB.Image}}
+    environment:
+{{- range .DB.Env}}
+      {{.}}
+{{- end}}
+    healthcheck:
+      test: {{.DB.HealthCheck}}
+      interval: 5s
+      timeout: 5s
+      retries: 10
+    volumes:
+      - db-data:{{.DB.Data}}
+    restart: unless-stopped
+
+volumes:
+  db-data:
+
+# conf/app.yaml with the sqlconn of the database service
+configs:
+  app-conf:
+    content: |
+{{ .ComposeConf | indent 6 }}
+{{- end}}
+`
+
+const k8sDeploymentTemplate = `# Generated by asanacli dockerize
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  labels:
+    app: {{.Name}}
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      securityContext:
+        runAsNonRoot: true
+        runAsUser: 65532
+        runAsGroup: 65532
+      containers:
+        - name: {{.Name}}
+          image: {{.Name}}:latest
+          imagePullPolicy: IfNotPresent
+{{- if .Ports}}
+          ports:
+{{- range .Ports}}
+            - name: {{.Name}}
+              containerPort: {{.Number}}
+              protocol: {{.Protocol}}
+{{- end}}
+{{- end}}
+{{- if .Probe}}
+          readinessProbe:
+{{ .Probe | indent 12 }}
+            initialDelaySeconds: 5
+            periodSeconds: 10
+          livenessProbe:
+{{ .Probe | indent 12 }}
+            initialDelaySeconds: 15
+            periodSeconds: 20
+{{- end}}
+          resources:
+            requests:
+              cpu: 100m
+              memory: 64Mi
+            limits:
+              memory: 256Mi
+          securityContext:
+            allowPrivilegeEscalation: false
+            readOnlyRootFilesystem: true
+            capabilities:
+              drop: ["ALL"]
+          # the root filesystem is read-only, the files are written in these volumes
+          volumeMounts:
+            - name: tmp
+              mountPath: /tmp
+            - name: logs
+              mountPath: /app/logs
+{{- if .AppConf}}
+            - name: conf
+              mountPath: /app/conf/app.yaml
+              subPath: app.yaml
+              readOnly: true
+{{- end}}
+      volumes:
+        - name: tmp
+          emptyDir: {}
+        - name: logs
+          emptyDir: {}
+{{- if .AppConf}}
+        - name: conf
+          configMap:
+            name: {{.Name}}-conf
+{{- end}}
+`
+
+const k8sServiceTemplate = `# Generated by asanacli dockerize
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Name}}
+  labels:
+    app: {{.Name}}
+spec:
+  type: ClusterIP
+  selector:
+    app: {{.Name}}
+  ports:
+{{- range .Ports}}
+    - name: {{.Name}}
+      port: {{.Number}}
+      targetPort: {{.Name}}
+      protocol: {{.Protocol}}
+{{- end}}
+`
+
+const k8sConfigMapTemplate = `# Generated by asanacli dockerize from conf/app.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.Name}}-conf
+  labels:
+    app: {{.Name}}
+data:
+  app.yaml: |
+{{ .AppConf | indent 4 }}
+`
+
+// stack describes the services of the compose file and the k8s manifests
+type stack struct {
+	Name    string // name of the images, services and k8s objects
+	Ports   []port
+	DB      *database
+	Probe   string // probe of the containers
+	AppConf string // content of conf/app.yaml
+	// content of conf/app.yaml in the compose stack
+	ComposeConf string
+}
+
+// port is an exposed port, e.g. 8080 or 53/udp
+type port struct {
+	Name     string
+	Number   string
+	Protocol string
+}
+
+// Suffix returns the protocol suffix of the port in compose files
+func (p port) Suffix() string {
+	if p.Protocol == "UDP" {
+		return "/udp"
+	}
+	return ""
+}
+
+// database is the database service of the compose file
+type database struct {
+	Driver      string
+	Image       string
+	Env         []string
+	HealthCheck string
+	Data        string
+	Conn        string
+}
+
+func parsePorts(ports []string) []port {
+	var ps []port
+	for i, p := range ports {
+		parts := strings.SplitN(p, "/", 2)
+		pt := port{Number: parts[0], Protocol: "TCP", Name: "http"}
+		if len(parts) == 2 && strings.EqualFold(parts[1], "udp") {
+			pt.Protocol = "UDP"
+		}
+		if i > 0 {
+			pt.Name = "port-" + pt.Number
+		}
+		ps = append(ps, pt)
+	}
+	return ps
+}
+
+// newDatabase returns the database service matching the driver of the
+// Asanafile, nil if there is none for it
+func newDatabase(appName string) *database {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, appName)
+	password := "${DB_PASSWORD:-" + name + "}"
+
+	switch driver := config.Conf.Database.Driver; driver {
+	case "mysql":
+		return &database{
+			Driver: driver,
+			Image:  "mysql:8",
+			Env: []string{
+				"MYSQL_DATABASE: " + name,
+				"MYSQL_USER: " + name,
+				"MYSQL_PASSWORD: " + strconv.Quote(password),
+				"MYSQL_RANDOM_ROOT_PASSWORD: \"yes\"",
+			},
+			HealthCheck: `["CMD", "mysqladmin", "ping", "-h", "127.0.0.1"]`,
+			Data:        "/var/lib/mysql",
+			Conn:        name + ":" + password + "@tcp(db:3306)/" + name + "?charset=utf8mb4&parseTime=true",
+		}
+	case "postgres":
+		return &database{
+			Driver: driver,
+			Image:  "postgres:16",
+			Env: []string{
+				"POSTGRES_DB: " + name,
+				"POSTGRES_USER: " + name,
+				"POSTGRES_PASSWORD: " + strconv.Quote(password),
+			},
+			HealthCheck: `["CMD", "pg_isready", "-U", "` + name + `"]`,
+			Data:        "/var/lib/postgresql/data",
+			Conn:        "postgres://" + name + ":" + password + "@db:5432/" + name + "?sslmode=disable",
+		}
+	default:
+		asanaLogger.Log.Warnf("No database service for the driver '%s', only mysql and postgres are supported", driver)
+		return nil
+	}
+}
+
+// newStack returns the stack of the application
+func newStack(dir, name string, ports []string, healthCheck string) stack {
+	// the names of the k8s objects are DNS labels
+	label := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name), "-")
+	s := stack{Name: label, Ports: parsePorts(ports), DB: newDatabase(name)}
+	if len(s.Ports) > 0 && s.Ports[0].Protocol == "TCP" {
+		if healthCheck != "" {
+			s.Probe = "httpGet:\n  path: /" + strings.TrimPrefix(healthCheck, "/") + "\n  port: " + s.Ports[0].Name
+		} else {
+			s.Probe = "tcpSocket:\n  port: " + s.Ports[0].Name
+		}
+	}
+	if conf, err := ioutil.ReadFile(filepath.Join(dir, "conf", "app.yaml")); err == nil {
+		s.AppConf = strings.TrimRight(string(conf), "\n")
+	} else if !os.IsNotExist(err) {
+		asanaLogger.Log.Fatalf("Could not read conf/app.yaml: %s", err)
+	}
+	if s.DB != nil {
+		s.ComposeConf = composeConf(s.AppConf, s.DB.Conn)
+	}
+	return s
+}
+
+// composeConf returns conf/app.yaml with the sqlconn of the database
+// service, read by the generated applications. The other $ are escaped
+// from the interpolation of compose.
+func composeConf(appConf, conn string) string {
+	sqlconn := "sqlconn: " + strconv.Quote(conn)
+	var lines []string
+	found := false
+	if appConf != "" {
+		for _, l := range strings.Split(strings.Replace(appConf, "$", "$$", -1), "\n") {
+			if strings.HasPrefix(l, "sqlconn:") {
+				l, found = sqlconn, true
+			}
+			lines = append(lines, l)
+		}
+	}
+	if !found {
+		lines = append(lines, sqlconn)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func generateCompose(s stack) {
+	writeTemplate("docker-compose.yml", composeTemplate, s, true)
+	asanaLogger.Log.Success("docker-compose.yml generated.")
+}
+
+func generateK8s(s stack) {
+	if err := os.MkdirAll("k8s", 0755); err != nil {
+		asanaLogger.Log.Fatalf("Error creating k8s: %v", err.Error())
+	}
+	writeTemplate(filepath.Join("k8s", "deployment.yaml"), k8sDeploymentTemplate, s, true)
+	if len(s.Ports) > 0 {
+		writeTemplate(filepath.Join("k8s", "service.yaml"), k8sServiceTemplate, s, true)
+	} else {
+		asanaLogger.Log.Warn("No port exposed, skipping the Service")
+	}
+	if s.AppConf != "" {
+		writeTemplate(filepath.Join("k8s", "configmap.yaml"), k8sConfigMapTemplate, s, true)
+	} else {
+		asanaLogger.Log.Warn("conf/app.yaml not found, skipping the ConfigMap")
+	}
+	asanaLogger.Log.Success("Kubernetes manifests generated in k8s/.")
+}
+
+// indent indents the lines of s
+func indent(n int, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = strings.Repeat(" ", n) + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/goasana/asanacli/generate/swaggergen"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+func diff(currPath string, args []string) int {
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		asanaLogger.Log.Fatal("Invalid format value. Must be either \"text\" or \"json\"")
+	}
+
+	var oldPath, newPath, worktree, gopath, output string
+	var err error
+	switch {
+	case against != "" && len(args) <= 1:
+		// The docs are generated out of the trees, leaving swagger/ as it is
+		output, err = ioutil.TempDir("", "asanacli-docs")
+		if err != nil {
+			asanaLogger.Log.Fatalf("Could not create a temporary directory: %s", err)
+		}
+		var appPath string
+		worktree, appPath, gopath = checkout(currPath, string(against))
+		oldPath, err = generateDocs(appPath, filepath.Join(output, "old"), gopath)
+		if err == nil && len(args) == 1 {
+			newPath = args[0]
+		} else if err == nil {
+			newPath, err = generateDocs(currPath, filepath.Join(output, "new"), "")
+		}
+	case against == "" && len(args) == 2:
+		oldPath, newPath = args[0], args[1]
+	default:
+		asanaLogger.Log.Fatal("Wrong number of arguments. Run: asanacli help docs")
+	}
+
+	var changes []swaggergen.DocChange
+	if err == nil {
+		changes, err = swaggergen.DiffDocs(oldPath, newPath)
+	}
+	if worktree != "" {
+		removeWorktree(currPath, worktree)
+	}
+	if gopath != "" {
+		_ = os.RemoveAll(gopath)
+	}
+	if output != "" {
+		_ = os.RemoveAll(output)
+	}
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not compare the docs: %s", err)
+	}
+	breaking := 0
+	for _, c := range changes {
+		if c.Breaking {
+			breaking++
+		}
+	}
+
+	if format == "json" {
+		out, _ := json.MarshalIndent(map[string]interface{}{
+			"breaking": breaking,
+			"changes":  changes,
+		}, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printChanges("Breaking changes", changes, true)
+		printChanges("Non-breaking changes", changes, false)
+		if len(changes) == 0 {
+			asanaLogger.Log.Success("No change found in the API")
+		}
+	}
+	if breaking > 0 {
+		if format == "text" {
+			asanaLogger.Log.Errorf("Found %d breaking change(s) in the API", breaking)
+		}
+		return 1
+	}
+	return 0
+}
+
+func printChanges(title string, changes []swaggergen.DocChange, breaking bool) {
+	printed := false
+	for _, c := range changes {
+		if c.Breaking != breaking {
+			continue
+		}
+		if !printed {
+			fmt.Println(title + ":")
+			printed = true
+		}
+		fmt.Println("  - " + c.String())
+	}
+}
+
+// checkout checks out a git revision of the repository of the application
+// in a temporary worktree, and returns the worktree, the path of the
+// application in it and the GOPATH to generate its docs with. The packages
+// of an application without go.mod are resolved from the GOPATH, so its
+// worktree is checked out in a GOPATH of its own, at the same import path.
+func checkout(currPath, ref string) (worktree, appPath, gopath string) {
+	prefix, err := git(currPath, "rev-parse", "--show-prefix")
+	if err != nil {
+		asanaLogger.Log.Fatalf("'%s' is not in a git repository: %s", currPath, err)
+	}
+	tmp, err := ioutil.TempDir("", "asanacli-docs-")
+	if err == nil {
+		// the paths compared with the GOPATH are the real ones
+		tmp, err = filepath.EvalSymlinks(tmp)
+	}
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not create a temporary directory: %s", err)
+	}
+	worktree = tmp
+	if !utils.IsExist(filepath.Join(currPath, "go.mod")) {
+		top, err := git(currPath, "rev-parse", "--show-toplevel")
+		pkg := ""
+		if err == nil {
+			pkg = gopathPackage(top)
+		}
+		if pkg == "" {
+			_ = os.RemoveAll(tmp)
+			asanaLogger.Log.Fatalf("'%s' has no go.mod and its repository is not in the GOPATH, "+
+				"the controllers of '%s' cannot be resolved", currPath, ref)
+		}
+		gopath = tmp
+		worktree = filepath.Join(tmp, "src", filepath.FromSlash(pkg))
+	}
+	if _, err := git(currPath, "worktree", "add", "--detach", worktree, ref); err != nil {
+		_ = os.RemoveAll(tmp)
+		asanaLogger.Log.Fatalf("Could not check out '%s': %s", ref, err)
+	}
+	return worktree, filepath.Join(worktree, filepath.FromSlash(prefix)), gopath
+}
+
+// gopathPackage returns the import path of a directory of the GOPATH,
+// empty if it is not in it
+func gopathPackage(dir string) string {
+	for _, gopath := range utils.GetGOPATHs() {
+		src, err := filepath.EvalSymlinks(filepath.Join(gopath, "src"))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(src, dir)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
+func removeWorktree(currPath, worktree string) {
+	if _, err := git(currPath, "worktree", "remove", "--force", worktree); err != nil {
+		asanaLogger.Log.Warnf("Could not remove the worktree '%s': %s", worktree, err)
+	}
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// generateDocs runs generate docs for the application at appPath into dir,
+// and returns the path of the generated swagger.json. The packages are
+// looked up in gopath first if set.
+func generateDocs(appPath, dir, gopath string) (string, error) {
+	asanaLogger.Log.Infof("Generating the docs of '%s'", appPath)
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	cmd := exec.Command(self, "generate", "docs", "-o="+dir, "-format=json")
+	cmd.Dir = appPath
+	if gopath != "" {
+		gopaths := append([]string{gopath}, utils.GetGOPATHs()...)
+		cmd.Env = append(os.Environ(), "GOPATH="+strings.Join(gopaths, string(filepath.ListSeparator)), "GO111MODULE=off")
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("could not generate the docs of '%s': %s\n%s", appPath, err, out)
+	}
+	return filepath.Join(dir, "swagger.json"), nil
+}
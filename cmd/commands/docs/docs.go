@@ -0,0 +1,184 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package docs ...
+package docs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/goasana/asanacli/cmd/commands"
+	"github.com/goasana/asanacli/cmd/commands/version"
+	"github.com/goasana/asanacli/generate/swaggergen"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+var CmdDocs = &commands.Command{
+	UsageLine: "docs [command]",
+	Short:     "Checks and serves the API documentation",
+	Long: `▶ {{"To check the annotations of the controllers and the routes:"|bold}}
+
+     $ asanacli docs lint
+
+     Reports malformed or unknown annotations, path params missing from the route,
+     duplicate operation IDs, models which cannot be found, annotated methods without
+     @router and routes which cannot be resolved, each with its file and line.
+     Exits with a non-zero status if any issue is found, so that it can run in CI.
+
+  ▶ {{"To browse the docs generated by generate docs:"|bold}}
+
+     $ asanacli docs serve [-a=127.0.0.1] [-p=8089] [-dir=swagger]
+
+     Serves the swagger.json or openapi.json of dir, read again on each request, with the
+     Swagger UI embedded into asanacli. The files of dir, e.g. a UI written by docs ui,
+     take precedence over the embedded ones.
+
+  ▶ {{"To write the UI next to the docs, to be served by the application:"|bold}}
+
+     $ asanacli docs ui [-dir=swagger] [-from=swagger-ui.zip]
+
+     Writes the embedded UI, or extracts the given archive of a UI, e.g. a release of
+     Swagger UI or Redoc, into dir. Entries which would be written outside of dir are
+     rejected.
+
+  ▶ {{"To detect the breaking changes of the API:"|bold}}
+
+     $ asanacli docs diff [-format=text|json] old.json new.json
+     $ asanacli docs diff -against=<git ref> [-format=text|json] [new.json]
+
+     Compares two docs produced by generate docs, or the docs generated at a git
+     revision with new.json, or else with the docs generated from the working tree. The
+     revision is checked out in a temporary GOPATH for the applications without go.mod.
+     Removed endpoints and responses, new required params and fields, type changes and
+     removed response fields are breaking. Exits with a non-zero status if any breaking
+     change is found.
+`,
+	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
+	Run:    runDocs,
+}
+
+var (
+	a       utils.DocValue
+	p       utils.DocValue
+	dir     utils.DocValue
+	from    utils.DocValue
+	against utils.DocValue
+	format  utils.DocValue
+)
+
+func init() {
+	CmdDocs.Flag.Var(&a, "a", "Listen address")
+	CmdDocs.Flag.Var(&p, "p", "Listen port")
+	CmdDocs.Flag.Var(&dir, "dir", "Directory of the docs")
+	CmdDocs.Flag.Var(&from, "from", "Archive of the UI to extract")
+	CmdDocs.Flag.Var(&against, "against", "Git revision to compare the docs with")
+	CmdDocs.Flag.Var(&format, "format", "Output format of the changes. Either text or json.")
+	commands.AvailableCommands = append(commands.AvailableCommands, CmdDocs)
+}
+
+func runDocs(cmd *commands.Command, args []string) int {
+	currPath, _ := os.Getwd()
+	if len(args) < 1 {
+		asanaLogger.Log.Fatal("Command is missing")
+	}
+
+	switch args[0] {
+	case "lint":
+		return lint(currPath)
+	case "serve":
+		_ = cmd.Flag.Parse(args[1:])
+		return serve(currPath)
+	case "ui":
+		_ = cmd.Flag.Parse(args[1:])
+		return ui(currPath)
+	case "diff":
+		_ = cmd.Flag.Parse(args[1:])
+		return diff(currPath, cmd.Flag.Args())
+	default:
+		asanaLogger.Log.Fatalf("Unknown docs command '%s'", args[0])
+	}
+	return 0
+}
+
+func lint(currPath string) int {
+	issues := swaggergen.LintDocs(currPath)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		asanaLogger.Log.Errorf("Found %d issue(s) in the API documentation", len(issues))
+		return 1
+	}
+	asanaLogger.Log.Success("No issue found in the API documentation")
+	return 0
+}
+
+func docsDir(currPath string) string {
+	if dir == "" {
+		dir = "swagger"
+	}
+	if filepath.IsAbs(string(dir)) {
+		return string(dir)
+	}
+	return filepath.Join(currPath, string(dir))
+}
+
+func serve(currPath string) int {
+	if a == "" {
+		a = "127.0.0.1"
+	}
+	if p == "" {
+		p = "8089"
+	}
+	docs := docsDir(currPath)
+	if !utils.IsExist(filepath.Join(docs, "swagger.json")) && !utils.IsExist(filepath.Join(docs, "openapi.json")) {
+		asanaLogger.Log.Fatalf("No swagger.json or openapi.json found in '%s'. Run: asanacli generate docs", docs)
+	}
+
+	embedded := http.FileServer(swaggergen.UIFileSystem())
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fpath := filepath.Join(docs, filepath.FromSlash(filepath.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(fpath); err == nil && !info.IsDir() {
+			http.ServeFile(w, r, fpath)
+			return
+		}
+		embedded.ServeHTTP(w, r)
+	})
+	asanaLogger.Log.Infof("Serving the docs of '%s' on http://%s:%s", docs, a, p)
+	if err := http.ListenAndServe(string(a)+":"+string(p), handler); err != nil {
+		asanaLogger.Log.Error(err.Error())
+		return 1
+	}
+	return 0
+}
+
+func ui(currPath string) int {
+	docs := docsDir(currPath)
+	var err error
+	if from != "" {
+		err = swaggergen.ExtractUI(string(from), docs)
+	} else {
+		err = swaggergen.WriteUI(docs)
+	}
+	if err != nil {
+		asanaLogger.Log.Errorf("Could not write the UI: %s", err)
+		return 1
+	}
+	asanaLogger.Log.Successf("UI written to '%s'", docs)
+	return 0
+}
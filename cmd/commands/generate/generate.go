@@ -37,6 +37,10 @@ var CmdGenerate = &commands.Command{
 
      $ asana generate model [modelname] [-fields="name:type"]
 
+     Fields are written as name:type[:argument][:modifier...], e.g.
+     -fields="email:string:255:unique,price:decimal:10,2:nullable,user:fk:users"
+     Modifiers: nullable, unique, index, default=value
+
   ▶ {{"To generate a controller:"|bold}}
 
      $ asana generate controller [controllerfile]
@@ -51,7 +55,21 @@ var CmdGenerate = &commands.Command{
 
   ▶ {{"To generate swagger doc file:"|bold}}
 
-     $ asana generate docs
+     $ asana generate docs [-openapi=2|3] [-format=json,yaml] [-o=swagger]
+
+     Besides the json and yaml documents, -format can render a Markdown reference with a
+     page per namespace (markdown), a Postman collection (postman) and an Insomnia export
+     (insomnia), with example bodies, into the -o directory.
+
+     Routes are read from every file of the routers package, or from the package directory
+     or entry file set by "router" in the "docs" section of the Asanafile.
+
+  ▶ {{"To generate a typed client of the API:"|bold}}
+
+     $ asana generate client [-lang=go|ts] [-o=client]
+
+     Generates a method per documented operation, taking a request struct with its path,
+     query, header, form and body params, and a type per model, without any external tool.
 
   ▶ {{"To generate a test case:"|bold}}
 
@@ -60,6 +78,13 @@ var CmdGenerate = &commands.Command{
   ▶ {{"To generate appcode based on an existing database:"|bold}}
 
      $ asana generate appcode [-tables=""] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-level=3]
+
+     Generated code goes to *_gen.go files and to the generated region of routers/router.go,
+     which are rewritten on each run. Code written in the other files is never touched.
+     Go types can be overridden in the "database" section of the Asanafile, with "types"
+     keyed by SQL type or table.column (type and import), and "nullable" (pointer or sql).
+     The defaults are types the ORM supports; richer ones, e.g. json.RawMessage, uuid.UUID
+     or pq arrays keyed by element type (_int4), must be set in "types".
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    GenerateCode,
@@ -72,6 +97,10 @@ func init() {
 	CmdGenerate.Flag.Var(&generate.Level, "level", "Either 1, 2 or 3. i.e. 1=models; 2=models and controllers; 3=models, controllers and routers.")
 	CmdGenerate.Flag.Var(&generate.Fields, "fields", "List of table Fields.")
 	CmdGenerate.Flag.Var(&generate.DDL, "ddl", "Generate DDL Migration")
+	CmdGenerate.Flag.Var(&generate.OpenAPI, "openapi", "OpenAPI version of the docs. Either 2 (Swagger 2.0) or 3.")
+	CmdGenerate.Flag.Var(&generate.Lang, "lang", "Language of the client. Either go or ts.")
+	CmdGenerate.Flag.Var(&generate.Output, "o", "Output directory of the client or the docs.")
+	CmdGenerate.Flag.Var(&generate.Format, "format", "Formats of the docs separated by commas: json, yaml, markdown, postman or insomnia.")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdGenerate)
 }
 
@@ -95,7 +124,9 @@ func GenerateCode(cmd *commands.Command, args []string) int {
 	case "scaffold":
 		scaffold(cmd, args, currPath)
 	case "docs":
-		swaggergen.GenerateDocs(currPath)
+		docs(cmd, args, currPath)
+	case "client":
+		client(cmd, args, currPath)
 	case "appcode":
 		appCode(cmd, args, currPath)
 	case "migration":
@@ -106,6 +137,8 @@ func GenerateCode(cmd *commands.Command, args []string) int {
 		model(cmd, args, currPath)
 	case "view":
 		view(args, currPath)
+	case "test":
+		testCode(args, currPath)
 	default:
 		asanaLogger.Log.Fatal("Command is missing")
 	}
@@ -208,6 +241,37 @@ func model(cmd *commands.Command, args []string, currPath string) {
 	generate.GenerateModel(sname, generate.Fields.String(), currPath)
 }
 
+func docs(cmd *commands.Command, args []string, currPath string) {
+	_ = cmd.Flag.Parse(args[1:])
+	if err := swaggergen.SetDocsOutput(generate.Output.String(), generate.Format.String()); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+	switch generate.OpenAPI {
+	case "", "2":
+		swaggergen.GenerateDocs(currPath)
+	case "3":
+		swaggergen.GenerateOpenAPI(currPath)
+	default:
+		asanaLogger.Log.Fatal("Invalid openapi value. Must be either \"2\" or \"3\"")
+	}
+}
+
+func client(cmd *commands.Command, args []string, currPath string) {
+	_ = cmd.Flag.Parse(args[1:])
+	swaggergen.GenerateClient(currPath, generate.Lang.String(), generate.Output.String())
+}
+
+func testCode(args []string, currPath string) {
+	switch len(args) {
+	case 1:
+		swaggergen.GenerateTests(currPath, "")
+	case 2:
+		swaggergen.GenerateTests(currPath, args[1])
+	default:
+		asanaLogger.Log.Fatal("Wrong number of arguments. Run: asanacli help generate")
+	}
+}
+
 func view(args []string, currPath string) {
 	if len(args) == 2 {
 		cname := args[1]
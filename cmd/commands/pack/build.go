@@ -0,0 +1,321 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package pack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+// target is a platform to build the application for
+type target struct {
+	goos   string
+	goarch string
+	goarm  string
+}
+
+// parseTargets parses a list of targets separated by commas, e.g.
+// linux/amd64,linux/arm/7,darwin/arm64
+func parseTargets(list string) ([]target, error) {
+	var targets []target
+	seen := make(map[target]bool)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.Split(s, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid target '%s', must be os/arch or os/arm/version", s)
+		}
+		t := target{goos: parts[0], goarch: parts[1]}
+		if len(parts) == 3 {
+			t.goarm = strings.TrimPrefix(parts[2], "v")
+		}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func (t target) String() string {
+	if t.goarm != "" {
+		return t.goos + "/" + t.goarch + "/" + t.goarm
+	}
+	return t.goos + "/" + t.goarch
+}
+
+// suffix returns the suffix of the archive of the target, e.g. _linux_amd64
+func (t target) suffix() string {
+	s := "_" + t.goos + "_" + t.goarch
+	if t.goarm != "" {
+		s += "v" + t.goarm
+	}
+	return s
+}
+
+func (t target) env() []string {
+	env := []string{"GOOS=" + t.goos, "GOARCH=" + t.goarch}
+	if t.goarm != "" {
+		env = append(env, "GOARM="+t.goarm)
+	}
+	return env
+}
+
+// buildTarget builds the application for a target into dir. The output of
+// go build is returned with the error, as the targets are built in parallel.
+func buildTarget(t target, appName, appPath, dir string, args, envs []string) error {
+	binPath := path.Join(dir, appName)
+	if t.goos == "windows" {
+		binPath += ".exe"
+	}
+	args = append([]string{"build", "-o", binPath}, args...)
+	if verbose {
+		asanaLogger.Log.Infof("%s: go %s", t, strings.Join(args, " "))
+	}
+
+	execmd := exec.Command("go", args...)
+	execmd.Env = append(append(os.Environ(), envs...), t.env()...)
+	execmd.Dir = appPath
+	if out, err := execmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s\n%s", err, out)
+	}
+	return nil
+}
+
+// goBuildArgs returns the args of go build: the ones of -ba, with
+// -trimpath and the version stamped by ldflags
+func goBuildArgs(buildArgs, stamp string) ([]string, error) {
+	args, err := splitArgs(buildArgs)
+	if err != nil {
+		return nil, err
+	}
+	trimpath := false
+	for _, a := range args {
+		trimpath = trimpath || a == "-trimpath" || a == "--trimpath"
+	}
+	if !trimpath {
+		args = append([]string{"-trimpath"}, args...)
+	}
+	if stamp == "" {
+		return args, nil
+	}
+	// go build only takes the last -ldflags, the stamp is added to it
+	for i := len(args) - 1; i >= 0; i-- {
+		a := strings.TrimPrefix(args[i], "-")
+		switch {
+		case a == "-ldflags" || a == "ldflags":
+			if i+1 < len(args) {
+				args[i+1] = strings.TrimSpace(args[i+1] + " " + stamp)
+				return args, nil
+			}
+		case strings.HasPrefix(a, "ldflags=") || strings.HasPrefix(a, "-ldflags="):
+			args[i] = strings.TrimSpace(args[i] + " " + stamp)
+			return args, nil
+		}
+	}
+	return append(args, "-ldflags", stamp), nil
+}
+
+// splitArgs splits a command line as a shell does: single quotes keep
+// their content as it is, double quotes and backslashes escape the spaces
+// and the quotes, e.g. -ldflags "-s -w -X 'main.v=1 2'"
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var buf strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, ch := range s {
+		switch {
+		case escaped:
+			buf.WriteRune(ch)
+			escaped = false
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(ch)
+			}
+		case quote == '"':
+			switch ch {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				buf.WriteRune(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote, inArg = ch, true
+		case ch == '\\':
+			escaped, inArg = true, true
+		case unicode.IsSpace(ch):
+			if inArg {
+				args = append(args, buf.String())
+				buf.Reset()
+				inArg = false
+			}
+		default:
+			buf.WriteRune(ch)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in '%s'", quote, s)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in '%s'", s)
+	}
+	if inArg {
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
+// gitVersion describes the revision of the application, to be stamped
+type gitVersion struct {
+	version string
+	commit  string
+	date    time.Time
+}
+
+// readGitVersion reads the version of the application from git, nil if it
+// is not in a git repository
+func readGitVersion(appPath string) *gitVersion {
+	commit, err := git(appPath, "rev-parse", "HEAD")
+	if err != nil {
+		return nil
+	}
+	v := &gitVersion{commit: commit}
+	v.version, err = git(appPath, "describe", "--tags", "--always", "--dirty")
+	if err != nil {
+		v.version = commit
+	}
+	if ct, err := git(appPath, "log", "-1", "--format=%ct"); err == nil {
+		if sec, err := strconv.ParseInt(ct, 10, 64); err == nil {
+			v.date = time.Unix(sec, 0).UTC()
+		}
+	}
+	return v
+}
+
+// git runs git in dir and returns its output, without the trailing newline
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ldflags returns the flags which set main.version, main.commit and
+// main.date. The linker ignores the variables which do not exist.
+func (v *gitVersion) ldflags() string {
+	return fmt.Sprintf("-X main.version=%s -X main.commit=%s -X main.date=%s",
+		v.version, v.commit, v.date.Format(time.RFC3339))
+}
+
+// sourceDateEpoch returns the time of the files in the archives: the
+// SOURCE_DATE_EPOCH environment variable, or the date of the last commit,
+// or else a fixed date, so that packing the same sources gives the same
+// archives
+func sourceDateEpoch(v *gitVersion) time.Time {
+	if s := os.Getenv("SOURCE_DATE_EPOCH"); s != "" {
+		if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+		asanaLogger.Log.Warnf("Invalid SOURCE_DATE_EPOCH '%s'", s)
+	}
+	if v != nil && !v.date.IsZero() {
+		return v.date
+	}
+	// the earliest date of the zip format
+	return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// checksumsFile is the file of the checksums of the archives
+const checksumsFile = "checksums.txt"
+
+// packOutputs returns the files of dir written by pack for the application:
+// its archives, whatever the target and the format, and the checksums
+func packOutputs(dir, appName string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var outputs []string
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.IsDir() {
+			continue
+		}
+		if name == checksumsFile {
+			outputs = append(outputs, path.Join(dir, name))
+			continue
+		}
+		if !strings.HasPrefix(name, appName) {
+			continue
+		}
+		for _, ext := range formats {
+			if strings.HasSuffix(name, "."+ext) {
+				outputs = append(outputs, path.Join(dir, name))
+				break
+			}
+		}
+	}
+	return outputs
+}
+
+// writeChecksums writes the SHA-256 of the archives into checksums.txt, in
+// the format of sha256sum
+func writeChecksums(dir string, archives []string) error {
+	sorted := append([]string{}, archives...)
+	sort.Strings(sorted)
+	var lines []string
+	for _, archive := range sorted {
+		f, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		utils.CloseFile(f)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, hex.EncodeToString(h.Sum(nil))+"  "+path.Base(archive))
+	}
+	fpath := path.Join(dir, checksumsFile)
+	return ioutil.WriteFile(fpath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
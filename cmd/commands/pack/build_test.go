@@ -0,0 +1,50 @@
+package pack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoBuildArgs(t *testing.T) {
+	tests := []struct {
+		buildArgs string
+		stamp     string
+		want      []string
+	}{
+		{"", "", []string{"-trimpath"}},
+		{"-v -race", "", []string{"-trimpath", "-v", "-race"}},
+		{"-trimpath -v", "", []string{"-trimpath", "-v"}},
+		{`-ldflags "-s -w -X main.v=1"`, "",
+			[]string{"-trimpath", "-ldflags", "-s -w -X main.v=1"}},
+		{`-ldflags '-s -w' -tags "a b"`, "",
+			[]string{"-trimpath", "-ldflags", "-s -w", "-tags", "a b"}},
+		{`-ldflags="-X 'main.name=my app'"`, "",
+			[]string{"-trimpath", "-ldflags=-X 'main.name=my app'"}},
+		{`-tags a\ b`, "", []string{"-trimpath", "-tags", "a b"}},
+		{`-ldflags "-X \"main.v=1\""`, "",
+			[]string{"-trimpath", "-ldflags", `-X "main.v=1"`}},
+		{"-v", "-X main.version=1", []string{"-trimpath", "-v", "-ldflags", "-X main.version=1"}},
+		{`-ldflags "-s -w"`, "-X main.version=1",
+			[]string{"-trimpath", "-ldflags", "-s -w -X main.version=1"}},
+		{`--ldflags=-s`, "-X main.version=1",
+			[]string{"-trimpath", "--ldflags=-s -X main.version=1"}},
+	}
+	for _, tt := range tests {
+		got, err := goBuildArgs(tt.buildArgs, tt.stamp)
+		if err != nil {
+			t.Errorf("goBuildArgs(%q, %q): %v", tt.buildArgs, tt.stamp, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("goBuildArgs(%q, %q) = %q, want %q", tt.buildArgs, tt.stamp, got, tt.want)
+		}
+	}
+}
+
+func TestGoBuildArgsInvalid(t *testing.T) {
+	for _, buildArgs := range []string{`-ldflags "-s -w`, `-ldflags '-s`, `-v \`} {
+		if args, err := goBuildArgs(buildArgs, ""); err == nil {
+			t.Errorf("goBuildArgs(%q) = %q, want an error", buildArgs, args)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package pack
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asanacli/config"
+)
+
+// manifest is the content of the archives described by the pack section
+// of the Asanafile
+type manifest struct {
+	include  *patternList
+	exclude  *patternList
+	filtered bool // the Asanafile sets include or exclude patterns
+	renames  []rename
+	modes    []modeOverride
+}
+
+// patternList is a list of gitignore-style patterns, the last matching
+// one decides
+type patternList struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type rename struct {
+	from, to string
+}
+
+type modeOverride struct {
+	pattern *patternList
+	glob    string
+	mode    os.FileMode
+}
+
+// vcsDirs are the directories of the version control systems
+var vcsDirs = []string{".git/", ".hg/", ".svn/", ".bzr/"}
+
+// newManifest returns the manifest of the Asanafile, nil if it has no pack
+// section
+func newManifest() (*manifest, error) {
+	conf := config.Conf.Pack
+	if len(conf.Include) == 0 && len(conf.Exclude) == 0 && len(conf.Rename) == 0 && len(conf.Modes) == 0 {
+		return nil, nil
+	}
+	m := &manifest{filtered: len(conf.Include) > 0 || len(conf.Exclude) > 0}
+	var err error
+	if len(conf.Include) > 0 {
+		if m.include, err = newPatternList(conf.Include); err != nil {
+			return nil, err
+		}
+	}
+	// the repositories are left out like with the default -exp, unless negated
+	if m.exclude, err = newPatternList(append(append([]string{}, vcsDirs...), conf.Exclude...)); err != nil {
+		return nil, err
+	}
+
+	for from, to := range conf.Rename {
+		from, to = strings.Trim(from, "/"), strings.Trim(to, "/")
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("invalid rename '%s: %s'", from, to)
+		}
+		m.renames = append(m.renames, rename{from: from, to: to})
+	}
+	// the most specific rename applies
+	sort.Slice(m.renames, func(i, j int) bool { return len(m.renames[i].from) > len(m.renames[j].from) })
+
+	for glob, mode := range conf.Modes {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || perm > 0777 {
+			return nil, fmt.Errorf("invalid mode '%s' for '%s', must be octal e.g. 0755", mode, glob)
+		}
+		p, err := newPatternList([]string{glob})
+		if err != nil {
+			return nil, err
+		}
+		m.modes = append(m.modes, modeOverride{pattern: p, glob: glob, mode: os.FileMode(perm)})
+	}
+	// the most specific pattern applies
+	sort.Slice(m.modes, func(i, j int) bool {
+		if len(m.modes[i].glob) != len(m.modes[j].glob) {
+			return len(m.modes[i].glob) > len(m.modes[j].glob)
+		}
+		return m.modes[i].glob < m.modes[j].glob
+	})
+	return m, nil
+}
+
+// newPatternList compiles gitignore-style patterns: a pattern without a
+// slash matches a name at any depth, a leading slash anchors it to the
+// root of the application, a trailing slash matches directories only,
+// ** matches any number of directories and ! negates a pattern.
+func newPatternList(lines []string) (*patternList, error) {
+	l := &patternList{}
+	for _, orig := range lines {
+		line := strings.TrimSpace(orig)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := pattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			return nil, fmt.Errorf("invalid pattern '%s'", orig)
+		}
+
+		expr := globRegexp(line)
+		if !anchored {
+			expr = "(?:.*/)?" + expr
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %s", orig, err)
+		}
+		p.re = re
+		l.patterns = append(l.patterns, p)
+	}
+	return l, nil
+}
+
+// globRegexp converts a glob to a regular expression
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if j := strings.IndexByte(glob[i:], ']'); j > 0 {
+				class := glob[i+1 : i+j]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += j
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match reports whether the slash separated path relative to the root
+// matches the list, and whether a pattern matched at all
+func (l *patternList) match(name string, isDir bool) (matched, decided bool) {
+	for _, p := range l.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(name) {
+			matched, decided = !p.negate, true
+		}
+	}
+	return
+}
+
+// matchPath reports whether a path or one of its parent directories
+// matches the list
+func (l *patternList) matchPath(name string, isDir bool) bool {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if matched, decided := l.match(strings.Join(parts[:i], "/"), true); decided && matched {
+			return true
+		}
+	}
+	matched, _ := l.match(name, isDir)
+	return matched
+}
+
+// excludesDir reports whether a directory is excluded with its content
+func (m *manifest) excludesDir(name string) bool {
+	matched, _ := m.exclude.match(name, true)
+	return matched
+}
+
+// includesFile reports whether a file goes in the archive
+func (m *manifest) includesFile(name string) bool {
+	if m.include != nil && !m.include.matchPath(name, false) {
+		return false
+	}
+	return !m.exclude.matchPath(name, false)
+}
+
+// destination returns the path of a file in the archive
+func (m *manifest) destination(name string) string {
+	for _, r := range m.renames {
+		if name == r.from {
+			return r.to
+		}
+		if strings.HasPrefix(name, r.from+"/") {
+			return r.to + name[len(r.from):]
+		}
+	}
+	return name
+}
+
+// mode returns the mode of a file in the archive
+func (m *manifest) mode(name string, fi os.FileInfo) os.FileMode {
+	for _, o := range m.modes {
+		if o.pattern.matchPath(name, false) {
+			return fi.Mode()&^os.ModePerm | o.mode
+		}
+	}
+	return fi.Mode()
+}
+
+// modeFileInfo overrides the mode of a file
+type modeFileInfo struct {
+	os.FileInfo
+	mode os.FileMode
+}
+
+func (fi modeFileInfo) Mode() os.FileMode { return fi.mode }
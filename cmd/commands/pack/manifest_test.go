@@ -0,0 +1,230 @@
+package pack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goasana/asanacli/config"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"*.go", `[^/]*\.go`},
+		{"a?c", `a[^/]c`},
+		{"**/logs", `(?:.*/)?logs`},
+		{"static/**", `static/.*`},
+		{"[abc].txt", `[abc]\.txt`},
+		{"[!abc].txt", `[^abc]\.txt`},
+		{"[abc", `\[abc`},
+		{`\*.txt`, `\*\.txt`},
+		{"a+b(c)", `a\+b\(c\)`},
+	}
+	for _, tt := range tests {
+		if got := globRegexp(tt.glob); got != tt.want {
+			t.Errorf("globRegexp(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestPatternList(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		isDir    bool
+		want     bool
+	}{
+		// without a slash, at any depth
+		{[]string{"*.log"}, "app.log", false, true},
+		{[]string{"*.log"}, "logs/app.log", false, true},
+		{[]string{"*.log"}, "app.logs", false, false},
+		// a leading slash anchors to the root
+		{[]string{"/app.log"}, "app.log", false, true},
+		{[]string{"/app.log"}, "logs/app.log", false, false},
+		{[]string{"logs/*.log"}, "logs/app.log", false, true},
+		{[]string{"logs/*.log"}, "old/logs/app.log", false, false},
+		{[]string{"logs/*.log"}, "logs/2020/app.log", false, false},
+		// a trailing slash matches the directories only
+		{[]string{"tmp/"}, "tmp", false, false},
+		{[]string{"tmp/"}, "tmp/a.txt", false, true},
+		{[]string{"tmp/"}, "cache/tmp/a.txt", false, true},
+		// ** matches any number of directories
+		{[]string{"static/**/*.map"}, "static/app.js.map", false, true},
+		{[]string{"static/**/*.map"}, "static/js/vendor/app.js.map", false, true},
+		{[]string{"**/testdata"}, "pkg/testdata/a.json", false, true},
+		// the last matching pattern decides
+		{[]string{"*.txt", "!keep.txt"}, "keep.txt", false, false},
+		{[]string{"*.txt", "!keep.txt"}, "drop.txt", false, true},
+		{[]string{"!keep.txt", "*.txt"}, "keep.txt", false, true},
+		// comments and blank lines
+		{[]string{"# *.txt", "", "  "}, "a.txt", false, false},
+		{[]string{`\#notes`}, "#notes", false, true},
+	}
+	for _, tt := range tests {
+		l, err := newPatternList(tt.patterns)
+		if err != nil {
+			t.Errorf("newPatternList(%q): %v", tt.patterns, err)
+			continue
+		}
+		if got := l.matchPath(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+
+	for _, patterns := range [][]string{{"/"}, {"!"}, {"[z-a]"}} {
+		if _, err := newPatternList(patterns); err == nil {
+			t.Errorf("newPatternList(%q) did not fail", patterns)
+		}
+	}
+}
+
+// withPack runs the test with the pack section of an Asanafile
+func withPack(t *testing.T, include, exclude []string, rename, modes map[string]string) {
+	conf := config.Conf.Pack
+	t.Cleanup(func() { config.Conf.Pack = conf })
+	config.Conf.Pack.Include = include
+	config.Conf.Pack.Exclude = exclude
+	config.Conf.Pack.Rename = rename
+	config.Conf.Pack.Modes = modes
+}
+
+func TestManifestFiles(t *testing.T) {
+	withPack(t, nil, nil, nil, nil)
+	if m, err := newManifest(); m != nil || err != nil {
+		t.Fatalf("manifest without a pack section: %v, %v", m, err)
+	}
+
+	withPack(t, []string{"conf/", "static/", "views/", "app"}, []string{"*.scss", "!static/main.scss", "!.git/"}, nil, nil)
+	m, err := newManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []struct {
+		name string
+		want bool
+	}{
+		{"app", true},
+		{"main.go", false},
+		{"conf/app.yaml", true},
+		{"static/css/site.scss", false},
+		{"static/main.scss", true},
+		{"static/js/app.js", true},
+		{"views/.hg/store", false},
+		{"views/.git/HEAD", true},
+	}
+	for _, f := range files {
+		if got := m.includesFile(f.name); got != f.want {
+			t.Errorf("includesFile(%q) = %v, want %v", f.name, got, f.want)
+		}
+	}
+	dirs := []struct {
+		name string
+		want bool
+	}{
+		{".hg", true},
+		{".svn", true},
+		{"static/.bzr", true},
+		{".git", false},
+		{"static", false},
+	}
+	for _, d := range dirs {
+		if got := m.excludesDir(d.name); got != d.want {
+			t.Errorf("excludesDir(%q) = %v, want %v", d.name, got, d.want)
+		}
+	}
+}
+
+func TestManifestDefaultExclusions(t *testing.T) {
+	withPack(t, nil, nil, map[string]string{"conf": "etc"}, nil)
+	m, err := newManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.filtered {
+		t.Error("the manifest is filtered without include nor exclude patterns")
+	}
+	for _, dir := range vcsDirs {
+		name := dir[:len(dir)-1]
+		if !m.excludesDir(name) || !m.excludesDir("vendor/pkg/"+name) {
+			t.Errorf("the %s directories are not excluded", name)
+		}
+		if m.includesFile(name + "/config") {
+			t.Errorf("the files of %s are included", name)
+		}
+	}
+	if !m.includesFile(".gitignore") || m.excludesDir("static") {
+		t.Error("files other than the repositories are excluded")
+	}
+}
+
+func TestManifestDestination(t *testing.T) {
+	withPack(t, nil, nil, map[string]string{"conf": "etc", "conf/prod/": "/etc/app", "bin/app": "app"}, nil)
+	m, err := newManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"conf/app.yaml", "etc/app.yaml"},
+		{"conf/prod/app.yaml", "etc/app/app.yaml"},
+		{"config/app.yaml", "config/app.yaml"},
+		{"bin/app", "app"},
+		{"bin/app.sh", "bin/app.sh"},
+		{"static/conf", "static/conf"},
+	}
+	for _, tt := range tests {
+		if got := m.destination(tt.name); got != tt.want {
+			t.Errorf("destination(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, rename := range []map[string]string{{"/": "etc"}, {"conf": ""}} {
+		withPack(t, nil, nil, rename, nil)
+		if _, err := newManifest(); err == nil {
+			t.Errorf("invalid rename %v accepted", rename)
+		}
+	}
+}
+
+type testFileInfo struct {
+	os.FileInfo
+	mode os.FileMode
+}
+
+func (fi testFileInfo) Mode() os.FileMode { return fi.mode }
+
+func TestManifestMode(t *testing.T) {
+	withPack(t, nil, nil, nil, map[string]string{"*.sh": "0755", "scripts/*.sh": "0700", "conf/": "0600"})
+	m, err := newManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{"run.sh", 0644, 0755},
+		{"bin/run.sh", 0644, 0755},
+		{"scripts/run.sh", 0644, 0700},
+		{"conf/app.yaml", 0644, 0600},
+		{"conf/app.yaml", os.ModeSymlink | 0777, os.ModeSymlink | 0600},
+		{"app", 0755, 0755},
+	}
+	for _, tt := range tests {
+		if got := m.mode(tt.name, testFileInfo{mode: tt.mode}); got != tt.want {
+			t.Errorf("mode(%q, %v) = %v, want %v", tt.name, tt.mode, got, tt.want)
+		}
+	}
+
+	for _, mode := range []string{"755x", "01000", "rwx"} {
+		withPack(t, nil, nil, nil, map[string]string{"*.sh": mode})
+		if _, err := newManifest(); err == nil {
+			t.Errorf("invalid mode %s accepted", mode)
+		}
+	}
+}
@@ -3,18 +3,18 @@ package pack
 import (
 	"archive/tar"
 	"archive/zip"
-	"compress/gzip"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	path "path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -33,6 +33,36 @@ var CmdPack = &commands.Command{
 
   {{"Example:"|bold}}
     $ asanacli pack -v -ba="-ldflags '-s -w'"
+
+  {{"To build for several platforms in parallel, with an archive per target:"|bold}}
+    $ asanacli pack -targets=linux/amd64,linux/arm64,linux/arm/7,darwin/arm64
+
+  The builds are reproducible: go build runs with -trimpath, and the files of the
+  archives are sorted by name with the time of the last commit, or SOURCE_DATE_EPOCH.
+  In a git repository, main.version, main.commit and main.date are set by -ldflags,
+  unless -stamp=false. A checksums.txt file is written next to the archives.
+
+  {{"To produce several formats, e.g. a self-extracting script and Linux packages:"|bold}}
+    $ asanacli pack -f=tar.zst,sh,deb,rpm
+
+  tar.zst and tar.xz need the zstd and xz programs. The .deb and .rpm packages install
+  the application in /opt/<app>, with a systemd unit. The install directory, maintainer,
+  description and unit file can be set in the pack section of the Asanafile.
+
+  {{"The content of the archives can be set in the pack section of the Asanafile:"|bold}}
+    pack:
+      include: ["conf/", "views/", "static/", ".well-known/"]
+      exclude: ["*.go", "**/*.tmp", "!static/vendor/*.tmp"]
+      rename:
+        conf/prod.yaml: conf/app.yaml
+      modes:
+        scripts/*.sh: "0755"
+
+  The patterns follow the rules of .gitignore. With an include or exclude list,
+  the -exp and -exs defaults are not applied. The built binary is always packed.
+
+  {{"To print the files which would go in the archive, without packing:"|bold}}
+    $ asanacli pack --list
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    packApp,
@@ -51,6 +81,9 @@ var (
 	buildEnvs utils.ListOpts
 	verbose   bool
 	format    string
+	targets   string
+	stamp     bool
+	list      bool
 )
 
 func init() {
@@ -60,13 +93,16 @@ func init() {
 	fs.StringVar(&buildArgs, "ba", "", "Specify additional args for Go build.")
 	fs.Var(&buildEnvs, "be", "Specify additional env variables for Go build. e.g. GOARCH=arm.")
 	fs.StringVar(&outputP, "o", "", "Set the compressed file output path. Defaults to the current path.")
-	fs.StringVar(&format, "f", "tar.gz", "Set file format. Either tar.gz or zip. Defaults to tar.gz.")
+	fs.StringVar(&format, "f", "tar.gz", "Set the formats, separated by commas: tar.gz, tar.zst, tar.xz, zip, sh, deb or rpm. Defaults to tar.gz.")
 	fs.StringVar(&excludeP, "exp", ".", "Set prefixes of paths to be excluded. Uses a column (:) as separator.")
 	fs.StringVar(&excludeS, "exs", ".go:.DS_Store:.tmp", "Set suffixes of paths to be excluded. Uses a column (:) as separator.")
 	fs.Var(&excludeR, "exr", "Set a regular expression of files to be excluded.")
 	fs.BoolVar(&fsym, "fs", false, "Tell the command to follow symlinks. Defaults to false.")
 	fs.BoolVar(&ssym, "ss", false, "Tell the command to skip symlinks. Defaults to false.")
 	fs.BoolVar(&verbose, "v", false, "Be more verbose during the operation. Defaults to false.")
+	fs.StringVar(&targets, "targets", "", "Set the os/arch targets to build for, separated by commas. e.g. linux/amd64,darwin/arm64.")
+	fs.BoolVar(&stamp, "stamp", true, "Tell the command to set the version of the application from git with -ldflags. Defaults to true.")
+	fs.BoolVar(&list, "list", false, "Print the files which would go in the archive, without building nor packing.")
 	CmdPack.Flag = *fs
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdPack)
 }
@@ -86,14 +122,23 @@ func (f byName) Len() int           { return len(f) }
 func (f byName) Less(i, j int) bool { return f[i].Name() < f[j].Name() }
 func (f byName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
 
-type walkFileTree struct {
-	wak           walker
-	prefix        string
+// filters selects the files of the archives
+type filters struct {
 	excludePrefix []string
 	excludeRegexp []*regexp.Regexp
 	excludeSuffix []string
-	allFiles      map[string]bool
-	output        *io.Writer
+	manifest      *manifest // pack section of the Asanafile
+	appRoot       string    // root the include and exclude patterns apply to
+}
+
+type walkFileTree struct {
+	wak    walker
+	prefix string
+	filters
+	allFiles map[string]bool
+	output   *io.Writer
+	skip     map[string]bool // outputs of the packs
+	mtime    time.Time       // time of all the files, for reproducible archives
 }
 
 func (wft *walkFileTree) isExclude(fPath string) bool {
@@ -124,6 +169,18 @@ func (wft *walkFileTree) isExcludeName(name string) bool {
 	return false
 }
 
+// isExcludeManifest reports whether the include and exclude patterns of
+// the Asanafile leave a path out
+func (wft *walkFileTree) isExcludeManifest(relPath string, isDir bool) bool {
+	if wft.manifest == nil || wft.prefix != wft.appRoot {
+		return false
+	}
+	if isDir {
+		return wft.manifest.excludesDir(relPath)
+	}
+	return !wft.manifest.includesFile(relPath)
+}
+
 func (wft *walkFileTree) isEmpty(fPath string) bool {
 	fh, _ := os.Open(fPath)
 	defer fh.Close()
@@ -137,6 +194,9 @@ func (wft *walkFileTree) isEmpty(fPath string) bool {
 		if wft.isExcludeName(fn) {
 			continue
 		}
+		if wft.isExcludeManifest(wft.virPath(fp), fi.IsDir()) {
+			continue
+		}
 		if fi.Mode()&os.ModeSymlink > 0 {
 			continue
 		}
@@ -182,7 +242,10 @@ func (wft *walkFileTree) walkLeaf(fPath string, fi os.FileInfo, err error) error
 		return err
 	}
 
-	if fPath == outputP {
+	if wft.skip[fPath] {
+		if fi.IsDir() {
+			return path.SkipDir
+		}
 		return nil
 	}
 
@@ -195,8 +258,17 @@ func (wft *walkFileTree) walkLeaf(fPath string, fi os.FileInfo, err error) error
 	}
 
 	name := wft.virPath(fPath)
+	if m := wft.manifest; m != nil {
+		if mode := m.mode(name, fi); mode != fi.Mode() {
+			fi = modeFileInfo{FileInfo: fi, mode: mode}
+		}
+		name = m.destination(name)
+	}
 
 	if wft.allFiles[name] {
+		if src := wft.virPath(fPath); src != name {
+			asanaLogger.Log.Warnf("Skipping '%s': '%s' is already in the archive", src, name)
+		}
 		return nil
 	}
 
@@ -230,6 +302,10 @@ func (wft *walkFileTree) iterDirectory(fPath string, fi os.FileInfo) error {
 		if wft.isExclude(relPath) {
 			return nil
 		}
+
+		if wft.isExcludeManifest(relPath, fi.IsDir()) {
+			return nil
+		}
 	}
 
 	err := wft.walkLeaf(fPath, fi, nil)
@@ -286,6 +362,11 @@ func (wft *tarWalk) compress(name, fPath string, fi os.FileInfo) (bool, error) {
 		return false, err
 	}
 	hdr.Name = name
+	hdr.ModTime = wft.mtime
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
 
 	tw := wft.tw
 	err = tw.WriteHeader(hdr)
@@ -322,6 +403,10 @@ func (wft *zipWalk) compress(name, fPath string, fi os.FileInfo) (bool, error) {
 		return false, err
 	}
 	hdr.Name = name
+	hdr.Modified = wft.mtime
+	if !isSym {
+		hdr.Method = zip.Deflate
+	}
 
 	zw := wft.zw
 	w, err := zw.CreateHeader(hdr)
@@ -353,64 +438,121 @@ func (wft *zipWalk) compress(name, fPath string, fi os.FileInfo) (bool, error) {
 	return true, nil
 }
 
-func packDirectory(output io.Writer, excludePrefix []string, excludeSuffix []string,
-	excludeRegexp []*regexp.Regexp, includePath ...string) (err error) {
+type listWalk struct {
+	walkFileTree
+	w io.Writer
+}
 
-	asanaLogger.Log.Infof("Excluding relpath prefix: %s", strings.Join(excludePrefix, ":"))
-	asanaLogger.Log.Infof("Excluding relpath suffix: %s", strings.Join(excludeSuffix, ":"))
-	if len(excludeRegexp) > 0 {
-		asanaLogger.Log.Infof("Excluding filename regex: `%s`", strings.Join(excludeR, "`, `"))
+func (wft *listWalk) compress(name, fPath string, fi os.FileInfo) (bool, error) {
+	line := fmt.Sprintf("%s  %s", fi.Mode(), name)
+	if src := wft.virPath(fPath); src != name {
+		line += "  (" + src + ")"
 	}
+	_, err := fmt.Fprintln(wft.w, line)
+	return true, err
+}
 
-	w, err := os.OpenFile(outputP, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+// listDirectory prints the files which would be packed from includePath
+func listDirectory(output io.Writer, skip map[string]bool, f filters, includePath ...string) error {
+	walk := new(listWalk)
+	walk.output = &output
+	walk.w = os.Stdout
+	walk.allFiles = make(map[string]bool)
+	walk.wak = walk
+	walk.filters = f
+	walk.skip = skip
+	for _, p := range includePath {
+		if err := walk.walkRoot(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func packDirectory(output io.Writer, archive, format string, skip map[string]bool, mtime time.Time, f filters,
+	pkg packageInfo, includePath ...string) (err error) {
+
+	perm := os.FileMode(0644)
+	if format == "sh" {
+		perm = 0755
+	}
+	w, err := os.OpenFile(archive, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
+	defer utils.CloseFile(w)
 
 	var wft walker
+	var closers []io.Closer
+	var packFiles func() error
 
-	if format == "zip" {
+	switch {
+	case format == "zip":
 		walk := new(zipWalk)
 		walk.output = &output
 		zw := zip.NewWriter(w)
-		defer func() {
-			_ = zw.Close()
-		}()
+		closers = append(closers, zw)
 		walk.allFiles = make(map[string]bool)
 		walk.zw = zw
 		walk.wak = walk
-		walk.excludePrefix = excludePrefix
-		walk.excludeSuffix = excludeSuffix
-		walk.excludeRegexp = excludeRegexp
+		walk.filters = f
+		walk.skip = skip
+		walk.mtime = mtime
 		wft = walk
-	} else {
+	case isPackage(format):
+		// The packages are written once all the files are known
+		walk := new(packageWalk)
+		walk.output = &output
+		walk.allFiles = make(map[string]bool)
+		walk.wak = walk
+		walk.filters = f
+		walk.skip = skip
+		walk.mtime = mtime
+		wft = walk
+		packFiles = func() error {
+			if format == "deb" {
+				return writeDeb(w, pkg, walk.files, mtime)
+			}
+			return writeRPM(w, pkg, walk.files, mtime)
+		}
+	default:
+		if format == "sh" {
+			if _, err = fmt.Fprintf(w, selfExtractHeader, pkg.name); err != nil {
+				return err
+			}
+		}
 		walk := new(tarWalk)
 		walk.output = &output
-		cw := gzip.NewWriter(w)
+		cw, err := newCompressor(w, format)
+		if err != nil {
+			return err
+		}
 		tw := tar.NewWriter(cw)
-
-		defer func() {
-			_ = tw.Flush()
-			_ = cw.Flush()
-			_ = tw.Close()
-			_ = cw.Close()
-		}()
+		closers = append(closers, tw, cw)
 		walk.allFiles = make(map[string]bool)
 		walk.tw = tw
 		walk.wak = walk
-		walk.excludePrefix = excludePrefix
-		walk.excludeSuffix = excludeSuffix
-		walk.excludeRegexp = excludeRegexp
+		walk.filters = f
+		walk.skip = skip
+		walk.mtime = mtime
 		wft = walk
 	}
 
 	for _, p := range includePath {
 		err = wft.walkRoot(p)
 		if err != nil {
-			return
+			break
+		}
+	}
+	if err == nil && packFiles != nil {
+		err = packFiles()
+	}
+	// the writers are closed even on errors, to stop the compressors
+	for _, c := range closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
 		}
 	}
-
 	return
 }
 
@@ -456,6 +598,39 @@ func packApp(cmd *commands.Command, args []string) int {
 		goarch = v
 	}
 
+	var envs []string
+	for _, env := range buildEnvs {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if len(k) > 0 && len(v) > 0 {
+				switch k {
+				case "GOOS":
+					goos = v
+				case "GOARCH":
+					goarch = v
+				default:
+					envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+				}
+			}
+		}
+	}
+
+	// Without -targets, a single archive is built for the current platform
+	platforms := []target{{goos: goos, goarch: goarch, goarm: os.Getenv("GOARM")}}
+	if targets != "" {
+		platforms, err = parseTargets(targets)
+		if err != nil {
+			asanaLogger.Log.Fatal(err.Error())
+		}
+		if !build {
+			asanaLogger.Log.Warn("-targets is ignored as -b=false")
+		}
+	}
+	if !build {
+		platforms = platforms[:1]
+	}
+
 	str := strconv.FormatInt(time.Now().UnixNano(), 10)[9:]
 
 	tmpdir := path.Join(os.TempDir(), "asanaPack-"+str)
@@ -469,78 +644,46 @@ func packApp(cmd *commands.Command, args []string) int {
 		}
 	}()
 
-	if build {
-		asanaLogger.Log.Info("Building application...")
-		var envs []string
-		for _, env := range buildEnvs {
-			parts := strings.SplitN(env, "=", 2)
-			if len(parts) == 2 {
-				k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
-				if len(k) > 0 && len(v) > 0 {
-					switch k {
-					case "GOOS":
-						goos = v
-					case "GOARCH":
-						goarch = v
-					default:
-						envs = append(envs, fmt.Sprintf("%s=%s", k, v))
-					}
-				}
-			}
-		}
-
-		_ = os.Setenv("GOOS", goos)
-		_ = os.Setenv("GOARCH", goarch)
-
-		asanaLogger.Log.Infof("Using: GOOS=%s GOARCH=%s", goos, goarch)
-
-		binPath := path.Join(tmpdir, appName)
-		if goos == "windows" {
-			binPath += ".exe"
-		}
-
-		args := []string{"build", "-o", binPath}
-		if len(buildArgs) > 0 {
-			args = append(args, buildArgs)
+	var packFormats []string
+	for _, fm := range strings.Split(format, ",") {
+		if fm = strings.TrimSpace(fm); fm == "" {
+			continue
 		}
-
-		if verbose {
-			_, _ = fmt.Fprintf(output, "\t%s%s+ go %s%s%s\n", "\x1b[32m", "\x1b[1m", strings.Join(args, " "), "\x1b[21m", "\x1b[0m")
+		if _, ok := formats[fm]; !ok {
+			asanaLogger.Log.Fatalf("Unknown format '%s', must be tar.gz, tar.zst, tar.xz, zip, sh, deb or rpm", fm)
 		}
-
-		execmd := exec.Command("go", args...)
-		execmd.Env = append(os.Environ(), envs...)
-		execmd.Stdout = os.Stdout
-		execmd.Stderr = os.Stderr
-		execmd.Dir = thePath
-		err = execmd.Run()
-		if err != nil {
-			asanaLogger.Log.Fatal(err.Error())
-		}
-
-		asanaLogger.Log.Success("Build Successful!")
+		packFormats = append(packFormats, fm)
 	}
-
-	switch format {
-	case "zip":
-	default:
-		format = "tar.gz"
+	if len(packFormats) == 0 {
+		packFormats = []string{"tar.gz"}
 	}
 
-	outputN := appName + "." + format
-
 	if outputP == "" || !path.IsAbs(outputP) {
 		outputP = path.Join(curPath, outputP)
 	}
 
-	if _, err := os.Stat(outputP); err != nil {
+	if _, err := os.Stat(outputP); err != nil && !list {
 		err = os.MkdirAll(outputP, 0755)
 		if err != nil {
 			asanaLogger.Log.Fatal(err.Error())
 		}
 	}
 
-	outputP = path.Join(outputP, outputN)
+	m, err := newManifest()
+	if err != nil {
+		asanaLogger.Log.Fatalf("Invalid pack section in the Asanafile: %s", err)
+	}
+	// The include and exclude patterns replace the default prefixes and suffixes
+	if m != nil && m.filtered {
+		set := make(map[string]bool)
+		cmd.Flag.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+		if !set["exp"] {
+			excludeP = ""
+		}
+		if !set["exs"] {
+			excludeS = ""
+		}
+	}
 
 	var exp, exs []string
 	for _, p := range strings.Split(excludeP, ":") {
@@ -565,13 +708,148 @@ func packApp(cmd *commands.Command, args []string) int {
 		}
 	}
 
-	asanaLogger.Log.Infof("Writing to output: %s", outputP)
+	if len(exp) > 0 {
+		asanaLogger.Log.Infof("Excluding relpath prefix: %s", strings.Join(exp, ":"))
+	}
+	if len(exs) > 0 {
+		asanaLogger.Log.Infof("Excluding relpath suffix: %s", strings.Join(exs, ":"))
+	}
+	if len(exr) > 0 {
+		asanaLogger.Log.Infof("Excluding filename regex: `%s`", strings.Join(excludeR, "`, `"))
+	}
+	f := filters{excludePrefix: exp, excludeSuffix: exs, excludeRegexp: exr, manifest: m, appRoot: thePath}
 
-	err = packDirectory(output, exp, exs, exr, tmpdir, thePath)
+	gitV := readGitVersion(thePath)
+	ldflags := ""
+	if stamp && gitV != nil {
+		ldflags = gitV.ldflags()
+		asanaLogger.Log.Infof("Stamping version %s", gitV.version)
+	}
+	goArgs, err := goBuildArgs(buildArgs, ldflags)
 	if err != nil {
-		asanaLogger.Log.Fatal(err.Error())
+		asanaLogger.Log.Fatalf("Invalid -ba: %s", err)
+	}
+	mtime := sourceDateEpoch(gitV)
+
+	// archives[i][j] is the archive of platforms[i] in packFormats[j]
+	archives := make([][]string, len(platforms))
+	skip := make(map[string]bool)
+	for i, t := range platforms {
+		for _, fm := range packFormats {
+			outputN := appName + "." + formats[fm]
+			if targets != "" && build {
+				outputN = appName + t.suffix() + "." + formats[fm]
+			}
+			archive := path.Join(outputP, outputN)
+			if isPackage(fm) && t.goos != "linux" {
+				asanaLogger.Log.Warnf("Skipping %s for %s, the packages are for Linux", fm, t)
+				archive = ""
+			}
+			archives[i] = append(archives[i], archive)
+			if archive != "" {
+				skip[archive] = true
+			}
+		}
+	}
+	// Nor are the outputs of the previous packs, nor the output directory
+	for _, fpath := range append(packOutputs(outputP, appName), packOutputs(thePath, appName)...) {
+		skip[fpath] = true
+	}
+	if outputP != thePath {
+		skip[outputP] = true
+	}
+	pkgVersion := packageVersion(gitV)
+
+	if list {
+		return listApp(output, platforms[0], appName, tmpdir, skip, f, thePath)
+	}
+
+	if build {
+		asanaLogger.Log.Info("Building application...")
+	}
+	errs := make([]error, len(platforms))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i, t := range platforms {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			binDir := path.Join(tmpdir, strings.Replace(t.String(), "/", "_", -1))
+			_ = os.Mkdir(binDir, 0700)
+			if build {
+				asanaLogger.Log.Infof("Building for GOOS=%s GOARCH=%s", t.goos, t.goarch)
+				if err := buildTarget(t, appName, thePath, binDir, goArgs, envs); err != nil {
+					errs[i] = fmt.Errorf("build for %s failed: %s", t, err)
+					return
+				}
+			}
+			pkg := packageInfo{name: appName, appPath: thePath, version: pkgVersion, target: t}
+			for j, fm := range packFormats {
+				if archives[i][j] == "" {
+					continue
+				}
+				asanaLogger.Log.Infof("Writing to output: %s", archives[i][j])
+				if err := packDirectory(output, archives[i][j], fm, skip, mtime, f, pkg, binDir, thePath); err != nil {
+					errs[i] = fmt.Errorf("packing %s for %s failed: %s", fm, t, err)
+					return
+				}
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	failed := false
+	for _, err := range errs {
+		if err != nil {
+			asanaLogger.Log.Error(err.Error())
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	if build {
+		asanaLogger.Log.Success("Build Successful!")
+	}
+
+	var written []string
+	for _, as := range archives {
+		for _, archive := range as {
+			if archive != "" {
+				written = append(written, archive)
+			}
+		}
+	}
+	if err := writeChecksums(outputP, written); err != nil {
+		asanaLogger.Log.Fatalf("Could not write the checksums: %s", err)
 	}
 
 	asanaLogger.Log.Success("Application packed!")
 	return 0
 }
+
+// listApp prints the files which would be packed for a target. The binary
+// is not built, an empty file stands for it.
+func listApp(output io.Writer, t target, appName, tmpdir string, skip map[string]bool, f filters, thePath string) int {
+	var roots []string
+	if build {
+		binDir := path.Join(tmpdir, strings.Replace(t.String(), "/", "_", -1))
+		binPath := path.Join(binDir, appName)
+		if t.goos == "windows" {
+			binPath += ".exe"
+		}
+		_ = os.Mkdir(binDir, 0700)
+		if err := ioutil.WriteFile(binPath, nil, 0755); err != nil {
+			asanaLogger.Log.Fatalf("Could not list the files: %s", err)
+		}
+		_ = os.Chmod(binPath, 0755)
+		roots = append(roots, binDir)
+	}
+	if err := listDirectory(output, skip, f, append(roots, thePath)...); err != nil {
+		asanaLogger.Log.Fatalf("Could not list the files: %s", err)
+	}
+	return 0
+}
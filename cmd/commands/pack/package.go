@@ -0,0 +1,454 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goasana/asanacli/config"
+)
+
+// formats are the formats of -f, with the extension of their archives
+var formats = map[string]string{
+	"tar.gz":  "tar.gz",
+	"tar.zst": "tar.zst",
+	"tar.xz":  "tar.xz",
+	"zip":     "zip",
+	"sh":      "sh",
+	"deb":     "deb",
+	"rpm":     "rpm",
+}
+
+// isPackage reports whether a format is a package of a Linux distribution
+func isPackage(format string) bool {
+	return format == "deb" || format == "rpm"
+}
+
+// newCompressor returns the writer compressing a tar archive of a format
+func newCompressor(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case "tar.zst":
+		return execCompressor(w, "zstd", "-q", "-c", "-19")
+	case "tar.xz":
+		// a single thread, as the output depends on the number of threads
+		return execCompressor(w, "xz", "-q", "-c", "-9", "-T1")
+	default:
+		return gzip.NewWriter(w), nil
+	}
+}
+
+// execWriter pipes the archive through a compression program
+type execWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+}
+
+func execCompressor(w io.Writer, name string, args ...string) (io.WriteCloser, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is required for this format: %s", name, err)
+	}
+	ew := &execWriter{cmd: exec.Command(name, args...)}
+	ew.cmd.Stdout = w
+	ew.cmd.Stderr = &ew.stderr
+	in, err := ew.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	ew.WriteCloser = in
+	if err := ew.cmd.Start(); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+func (ew *execWriter) Close() error {
+	err := ew.WriteCloser.Close()
+	if werr := ew.cmd.Wait(); werr != nil {
+		return fmt.Errorf("%s failed: %s %s", path.Base(ew.cmd.Path), werr, strings.TrimSpace(ew.stderr.String()))
+	}
+	return err
+}
+
+// selfExtractHeader is the script which extracts the tar.gz archive
+// appended to it, to the directory given as argument
+const selfExtractHeader = `#!/bin/sh
+# Self-extracting archive of %[1]s, created by asanacli pack.
+# Usage: sh %[1]s.sh [directory]
+set -e
+dest="${1:-%[1]s}"
+mkdir -p "$dest"
+line=$(awk '/^__ARCHIVE_BELOW__$/ { print NR + 1; exit 0 }' "$0")
+tail -n +"$line" "$0" | tar -xzf - -C "$dest"
+echo "%[1]s extracted to $dest"
+exit 0
+__ARCHIVE_BELOW__
+`
+
+// packageInfo describes the .deb or .rpm package of an application
+type packageInfo struct {
+	name    string // name of the application, and of its binary
+	appPath string
+	version string
+	target  target
+}
+
+// packageVersion returns the version of the packages from git, made of the
+// characters allowed by both dpkg and rpm
+func packageVersion(v *gitVersion) string {
+	if v == nil {
+		return "0.0.0"
+	}
+	s := strings.TrimPrefix(v.version, "v")
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		s = "0.0.0+" + s
+	}
+	// git describe gives tag-count-gcommit, the first dash becomes a plus
+	first := true
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '+', r == '~':
+			return r
+		case r == '-' && first:
+			first = false
+			return '+'
+		}
+		return '.'
+	}, s)
+}
+
+// packageName returns the name of the package, in lower case
+func (p packageInfo) packageName() string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '+', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, p.name)
+}
+
+func (p packageInfo) installDir() string {
+	if dir := config.Conf.Pack.InstallDir; dir != "" {
+		return "/" + strings.Trim(dir, "/")
+	}
+	return "/opt/" + p.packageName()
+}
+
+func (p packageInfo) maintainer() string {
+	if m := config.Conf.Pack.Maintainer; m != "" {
+		return m
+	}
+	name, _ := git(p.appPath, "config", "user.name")
+	email, _ := git(p.appPath, "config", "user.email")
+	if name == "" {
+		name = p.name + " maintainers"
+	}
+	if email == "" {
+		email = "root@localhost"
+	}
+	return name + " <" + email + ">"
+}
+
+func (p packageInfo) description() string {
+	if d := config.Conf.Pack.Description; d != "" {
+		return d
+	}
+	return p.name + " application, packed by asanacli"
+}
+
+// unit returns the systemd unit of the application
+func (p packageInfo) unit() ([]byte, error) {
+	if unit := config.Conf.Pack.Unit; unit != "" {
+		if !path.IsAbs(unit) {
+			unit = path.Join(p.appPath, unit)
+		}
+		return ioutil.ReadFile(unit)
+	}
+	dir := p.installDir()
+	return []byte(fmt.Sprintf(`[Unit]
+Description=%s
+After=network.target
+
+[Service]
+Type=simple
+WorkingDirectory=%s
+ExecStart=%s/%s
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`, p.description(), dir, dir, p.name)), nil
+}
+
+// debArch returns the Debian architecture of a target
+func (t target) debArch() string {
+	switch t.goarch {
+	case "386":
+		return "i386"
+	case "arm":
+		if t.goarm == "" || t.goarm == "7" {
+			return "armhf"
+		}
+		return "armel"
+	case "ppc64le":
+		return "ppc64el"
+	case "mips64le":
+		return "mips64el"
+	case "mipsle":
+		return "mipsel"
+	}
+	return t.goarch
+}
+
+// rpmArch returns the RPM architecture of a target
+func (t target) rpmArch() string {
+	switch t.goarch {
+	case "amd64":
+		return "x86_64"
+	case "386":
+		return "i686"
+	case "arm64":
+		return "aarch64"
+	case "arm":
+		if t.goarm == "" || t.goarm == "7" {
+			return "armv7hl"
+		}
+		return "armv6hl"
+	}
+	return t.goarch
+}
+
+// packageFile is a file found by the walker, to be installed
+type packageFile struct {
+	name  string // path in the install directory
+	fPath string
+	fi    os.FileInfo
+}
+
+type packageWalk struct {
+	walkFileTree
+	files []packageFile
+}
+
+func (wft *packageWalk) compress(name, fPath string, fi os.FileInfo) (bool, error) {
+	wft.files = append(wft.files, packageFile{name: name, fPath: fPath, fi: fi})
+	return true, nil
+}
+
+// packageEntry is a file, directory or symlink of a package
+type packageEntry struct {
+	name  string // absolute path
+	mode  os.FileMode
+	size  int64
+	link  string
+	fPath string
+	data  []byte
+	owned bool // directory created by the package
+}
+
+func (e packageEntry) isDir() bool { return e.mode.IsDir() }
+
+func (e packageEntry) open() (io.ReadCloser, error) {
+	if e.fPath == "" {
+		return ioutil.NopCloser(bytes.NewReader(e.data)), nil
+	}
+	return os.Open(e.fPath)
+}
+
+// packageEntries returns the entries of a package, sorted by path: the
+// files in the install directory, the systemd unit in unitDir, and their
+// parent directories
+func packageEntries(p packageInfo, files []packageFile, unitDir string) ([]packageEntry, error) {
+	installDir := p.installDir()
+	var entries []packageEntry
+	dirs := make(map[string]bool)
+	var addDirs func(name string)
+	addDirs = func(name string) {
+		dir := path.ToSlash(path.Dir(name))
+		if dir == "/" || dirs[dir] {
+			return
+		}
+		dirs[dir] = true
+		addDirs(dir)
+		owned := dir == installDir || strings.HasPrefix(dir, installDir+"/")
+		entries = append(entries, packageEntry{name: dir, mode: os.ModeDir | 0755, owned: owned})
+	}
+
+	for _, f := range files {
+		e := packageEntry{name: installDir + "/" + f.name, mode: f.fi.Mode(), fPath: f.fPath, size: f.fi.Size()}
+		if e.mode&os.ModeSymlink != 0 {
+			link, err := os.Readlink(f.fPath)
+			if err != nil {
+				return nil, err
+			}
+			e.link, e.fPath, e.size = link, "", int64(len(link))
+		}
+		entries = append(entries, e)
+		addDirs(e.name)
+	}
+
+	unit, err := p.unit()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the systemd unit: %s", err)
+	}
+	e := packageEntry{name: unitDir + "/" + p.packageName() + ".service", mode: 0644, data: unit, size: int64(len(unit))}
+	entries = append(entries, e)
+	addDirs(e.name)
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// writeDeb writes a Debian package: an ar archive of the control and data
+// tarballs
+func writeDeb(w io.Writer, p packageInfo, files []packageFile, mtime time.Time) error {
+	entries, err := packageEntries(p, files, "/lib/systemd/system")
+	if err != nil {
+		return err
+	}
+
+	var data, md5sums bytes.Buffer
+	var size int64
+	err = writeTarGz(&data, mtime, func(tw *tar.Writer) error {
+		for _, e := range entries {
+			hdr := &tar.Header{Name: "." + e.name, Mode: int64(e.mode.Perm()), ModTime: mtime, Uname: "root", Gname: "root"}
+			switch {
+			case e.isDir():
+				hdr.Typeflag, hdr.Name = tar.TypeDir, hdr.Name+"/"
+			case e.link != "":
+				hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+			default:
+				hdr.Typeflag, hdr.Size = tar.TypeReg, e.size
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			h := md5.New()
+			if err := copyEntry(io.MultiWriter(tw, h), e); err != nil {
+				return err
+			}
+			size += e.size
+			fmt.Fprintf(&md5sums, "%x  %s\n", h.Sum(nil), e.name[1:])
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	name := p.packageName() + ".service"
+	control := map[string]string{
+		"control": fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\nMaintainer: %s\nInstalled-Size: %d\nSection: misc\nPriority: optional\nDescription: %s\n",
+			p.packageName(), p.version, p.target.debArch(), p.maintainer(), (size+1023)/1024, p.description()),
+		"md5sums": md5sums.String(),
+		"postinst": "#!/bin/sh\nset -e\nif [ -d /run/systemd/system ]; then\n" +
+			"\tsystemctl daemon-reload >/dev/null || true\nfi\n",
+		"prerm": "#!/bin/sh\nset -e\nif [ -d /run/systemd/system ] && [ \"$1\" = remove ]; then\n" +
+			"\tsystemctl stop " + name + " >/dev/null || true\n\tsystemctl disable " + name + " >/dev/null || true\nfi\n",
+		"postrm": "#!/bin/sh\nset -e\nif [ -d /run/systemd/system ]; then\n" +
+			"\tsystemctl daemon-reload >/dev/null || true\nfi\n",
+	}
+	var ctrl bytes.Buffer
+	err = writeTarGz(&ctrl, mtime, func(tw *tar.Writer) error {
+		for _, n := range []string{"control", "md5sums", "postinst", "prerm", "postrm"} {
+			mode := int64(0755)
+			if n == "control" || n == "md5sums" {
+				mode = 0644
+			}
+			hdr := &tar.Header{Name: "./" + n, Mode: mode, Size: int64(len(control[n])), ModTime: mtime,
+				Typeflag: tar.TypeReg, Uname: "root", Gname: "root"}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(tw, control[n]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", ctrl.Bytes()},
+		{"data.tar.gz", data.Bytes()},
+	} {
+		hdr := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name, mtime.Unix(), 0, 0, "100644", len(m.data))
+		if _, err := io.WriteString(w, hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(m.data); err != nil {
+			return err
+		}
+		if len(m.data)%2 == 1 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeTarGz writes a tar.gz archive with fill
+func writeTarGz(w io.Writer, mtime time.Time, fill func(*tar.Writer) error) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := fill(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func copyEntry(w io.Writer, e packageEntry) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	n, err := io.Copy(w, r)
+	if err == nil && n != e.size {
+		err = fmt.Errorf("'%s' changed while packing", e.fPath)
+	}
+	return err
+}
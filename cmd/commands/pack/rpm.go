@@ -0,0 +1,391 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package pack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tags of the RPM headers, see https://rpm-software-management.github.io/rpm/manual/format.html
+const (
+	rpmTagHeaderSignatures = 62
+	rpmTagHeaderImmutable  = 63
+	rpmTagI18nTable        = 100
+
+	rpmSigSHA1        = 269
+	rpmSigSHA256      = 273
+	rpmSigSize        = 1000
+	rpmSigMD5         = 1004
+	rpmSigPayloadSize = 1007
+
+	rpmTagName            = 1000
+	rpmTagVersion         = 1001
+	rpmTagRelease         = 1002
+	rpmTagSummary         = 1004
+	rpmTagDescription     = 1005
+	rpmTagBuildTime       = 1006
+	rpmTagBuildHost       = 1007
+	rpmTagSize            = 1009
+	rpmTagVendor          = 1011
+	rpmTagLicense         = 1014
+	rpmTagPackager        = 1015
+	rpmTagGroup           = 1016
+	rpmTagOS              = 1021
+	rpmTagArch            = 1022
+	rpmTagPostIn          = 1024
+	rpmTagPreUn           = 1025
+	rpmTagPostUn          = 1026
+	rpmTagFileSizes       = 1028
+	rpmTagFileModes       = 1030
+	rpmTagFileRdevs       = 1033
+	rpmTagFileMtimes      = 1034
+	rpmTagFileDigests     = 1035
+	rpmTagFileLinkTos     = 1036
+	rpmTagFileFlags       = 1037
+	rpmTagFileUserName    = 1039
+	rpmTagFileGroupName   = 1040
+	rpmTagProvideName     = 1047
+	rpmTagRequireFlags    = 1048
+	rpmTagRequireName     = 1049
+	rpmTagRequireVersion  = 1050
+	rpmTagPostInProg      = 1086
+	rpmTagPreUnProg       = 1087
+	rpmTagPostUnProg      = 1088
+	rpmTagFileDevices     = 1095
+	rpmTagFileInodes      = 1096
+	rpmTagFileLangs       = 1097
+	rpmTagProvideFlags    = 1112
+	rpmTagProvideVersion  = 1113
+	rpmTagDirIndexes      = 1116
+	rpmTagBaseNames       = 1117
+	rpmTagDirNames        = 1118
+	rpmTagPayloadFormat   = 1124
+	rpmTagPayloadCompress = 1125
+	rpmTagPayloadFlags    = 1126
+	rpmTagFileDigestAlgo  = 5011
+
+	rpmSenseLess   = 1 << 1
+	rpmSenseEqual  = 1 << 3
+	rpmSenseRPMLib = 1 << 24
+
+	rpmDigestSHA256 = 8
+)
+
+// Types of the values of the RPM headers
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18nString  = 9
+)
+
+// rpmI18n is a translatable string, only in the C locale
+type rpmI18n string
+
+// rpmHeader is a header of an RPM package: the signature or the main header
+type rpmHeader map[int32]interface{}
+
+// bytes encodes the header, with its region tag
+func (h rpmHeader) bytes(region int32) []byte {
+	tags := make([]int, 0, len(h))
+	for tag := range h {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+
+	var index, store bytes.Buffer
+	entry := func(tag, typ int32, offset, count int) {
+		_ = binary.Write(&index, binary.BigEndian, []int32{tag, typ, int32(offset), int32(count)})
+	}
+	align := func(n int) {
+		for store.Len()%n != 0 {
+			store.WriteByte(0)
+		}
+	}
+	for _, tag := range tags {
+		t := int32(tag)
+		switch v := h[t].(type) {
+		case string:
+			entry(t, rpmString, store.Len(), 1)
+			store.WriteString(v + "\x00")
+		case rpmI18n:
+			entry(t, rpmI18nString, store.Len(), 1)
+			store.WriteString(string(v) + "\x00")
+		case []string:
+			entry(t, rpmStringArray, store.Len(), len(v))
+			for _, s := range v {
+				store.WriteString(s + "\x00")
+			}
+		case []int16:
+			align(2)
+			entry(t, rpmInt16, store.Len(), len(v))
+			_ = binary.Write(&store, binary.BigEndian, v)
+		case []int32:
+			align(4)
+			entry(t, rpmInt32, store.Len(), len(v))
+			_ = binary.Write(&store, binary.BigEndian, v)
+		case []byte:
+			entry(t, rpmBin, store.Len(), len(v))
+			store.Write(v)
+		}
+	}
+	// The region tag comes first in the index, its data is a copy of its
+	// own index entry at the end of the store, with the negated size of
+	// the index as offset
+	count := len(tags) + 1
+	_ = binary.Write(&store, binary.BigEndian, []int32{region, rpmBin, int32(-16 * count), 16})
+
+	var b bytes.Buffer
+	b.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	_ = binary.Write(&b, binary.BigEndian, []int32{int32(count), int32(store.Len())})
+	_ = binary.Write(&b, binary.BigEndian, []int32{region, rpmBin, int32(store.Len() - 16), 16})
+	b.Write(index.Bytes())
+	b.Write(store.Bytes())
+	return b.Bytes()
+}
+
+// writeRPM writes an RPM package: the lead, the signature, the header and
+// the gzipped cpio payload
+func writeRPM(w io.Writer, p packageInfo, files []packageFile, mtime time.Time) error {
+	entries, err := packageEntries(p, files, "/usr/lib/systemd/system")
+	if err != nil {
+		return err
+	}
+	var owned []packageEntry
+	for _, e := range entries {
+		if !e.isDir() || e.owned {
+			owned = append(owned, e)
+		}
+	}
+
+	var payload bytes.Buffer
+	digests := make([]string, len(owned))
+	gw, _ := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	cw := &countWriter{w: gw}
+	for i, e := range owned {
+		var h hash.Hash
+		if !e.isDir() && e.link == "" {
+			h = sha256.New()
+		}
+		if err := writeCpioEntry(cw, i+1, e, mtime, h); err != nil {
+			return err
+		}
+		if h != nil {
+			digests[i] = fmt.Sprintf("%x", h.Sum(nil))
+		}
+	}
+	if err := writeCpioEntry(cw, 0, packageEntry{name: "TRAILER!!!"}, time.Unix(0, 0), nil); err != nil {
+		return err
+	}
+	payloadSize := cw.n
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	release := "1"
+	h := rpmHeader{
+		rpmTagI18nTable:       []string{"C"},
+		rpmTagName:            p.packageName(),
+		rpmTagVersion:         p.version,
+		rpmTagRelease:         release,
+		rpmTagSummary:         rpmI18n(p.description()),
+		rpmTagDescription:     rpmI18n(p.description()),
+		rpmTagBuildTime:       []int32{int32(mtime.Unix())},
+		rpmTagBuildHost:       "localhost",
+		rpmTagVendor:          p.maintainer(),
+		rpmTagPackager:        p.maintainer(),
+		rpmTagLicense:         "Unspecified",
+		rpmTagGroup:           rpmI18n("Unspecified"),
+		rpmTagOS:              "linux",
+		rpmTagArch:            p.target.rpmArch(),
+		rpmTagProvideName:     []string{p.packageName()},
+		rpmTagProvideFlags:    []int32{rpmSenseEqual},
+		rpmTagProvideVersion:  []string{p.version + "-" + release},
+		rpmTagRequireName:     []string{"rpmlib(CompressedFileNames)", "rpmlib(FileDigests)", "rpmlib(PayloadFilesHavePrefix)"},
+		rpmTagRequireFlags:    []int32{rpmSenseRPMLib | rpmSenseLess | rpmSenseEqual, rpmSenseRPMLib | rpmSenseLess | rpmSenseEqual, rpmSenseRPMLib | rpmSenseLess | rpmSenseEqual},
+		rpmTagRequireVersion:  []string{"3.0.4-1", "4.6.0-1", "4.0-1"},
+		rpmTagPayloadFormat:   "cpio",
+		rpmTagPayloadCompress: "gzip",
+		rpmTagPayloadFlags:    "9",
+		rpmTagFileDigestAlgo:  []int32{rpmDigestSHA256},
+	}
+
+	unit := p.packageName() + ".service"
+	h[rpmTagPostIn] = "systemctl daemon-reload >/dev/null 2>&1 || :\n"
+	h[rpmTagPreUn] = "if [ $1 -eq 0 ]; then\n\tsystemctl --no-reload disable --now " + unit + " >/dev/null 2>&1 || :\nfi\n"
+	h[rpmTagPostUn] = "systemctl daemon-reload >/dev/null 2>&1 || :\n"
+	h[rpmTagPostInProg] = "/bin/sh"
+	h[rpmTagPreUnProg] = "/bin/sh"
+	h[rpmTagPostUnProg] = "/bin/sh"
+
+	var sizes, mtimes, flags, devices, inodes, dirIndexes []int32
+	var modes, rdevs []int16
+	var linkTos, users, groups, langs, baseNames, dirNames []string
+	dirIndex := make(map[string]int32)
+	var size int64
+	for i, e := range owned {
+		dir, base := e.name[:strings.LastIndex(e.name, "/")+1], e.name[strings.LastIndex(e.name, "/")+1:]
+		if _, ok := dirIndex[dir]; !ok {
+			dirIndex[dir] = int32(len(dirNames))
+			dirNames = append(dirNames, dir)
+		}
+		fileSize := e.size
+		if e.isDir() {
+			fileSize = 4096
+		}
+		size += fileSize
+		sizes = append(sizes, int32(fileSize))
+		mtimes = append(mtimes, int32(mtime.Unix()))
+		modes = append(modes, int16(cpioMode(e)))
+		rdevs = append(rdevs, 0)
+		flags = append(flags, 0)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(i+1))
+		linkTos = append(linkTos, e.link)
+		users = append(users, "root")
+		groups = append(groups, "root")
+		langs = append(langs, "")
+		baseNames = append(baseNames, base)
+		dirIndexes = append(dirIndexes, dirIndex[dir])
+	}
+	h[rpmTagSize] = []int32{int32(size)}
+	h[rpmTagFileSizes] = sizes
+	h[rpmTagFileModes] = modes
+	h[rpmTagFileRdevs] = rdevs
+	h[rpmTagFileMtimes] = mtimes
+	h[rpmTagFileDigests] = digests
+	h[rpmTagFileLinkTos] = linkTos
+	h[rpmTagFileFlags] = flags
+	h[rpmTagFileUserName] = users
+	h[rpmTagFileGroupName] = groups
+	h[rpmTagFileDevices] = devices
+	h[rpmTagFileInodes] = inodes
+	h[rpmTagFileLangs] = langs
+	h[rpmTagDirIndexes] = dirIndexes
+	h[rpmTagBaseNames] = baseNames
+	h[rpmTagDirNames] = dirNames
+	header := h.bytes(rpmTagHeaderImmutable)
+
+	md5sum := md5.New()
+	md5sum.Write(header)
+	md5sum.Write(payload.Bytes())
+	sig := rpmHeader{
+		rpmSigSHA1:        fmt.Sprintf("%x", sha1.Sum(header)),
+		rpmSigSHA256:      fmt.Sprintf("%x", sha256.Sum256(header)),
+		rpmSigSize:        []int32{int32(len(header) + payload.Len())},
+		rpmSigMD5:         md5sum.Sum(nil),
+		rpmSigPayloadSize: []int32{int32(payloadSize)},
+	}
+	signature := sig.bytes(rpmTagHeaderSignatures)
+	// the signature is padded to 8 bytes
+	signature = append(signature, make([]byte, (8-len(signature)%8)%8)...)
+
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // binary package
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:75], p.packageName()+"-"+p.version+"-"+release)
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // header-style signature
+
+	for _, b := range [][]byte{lead, signature, header, payload.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cpioMode returns the mode of an entry, with the type bits of stat
+func cpioMode(e packageEntry) uint32 {
+	mode := uint32(e.mode.Perm())
+	switch {
+	case e.isDir():
+		return mode | 0040000
+	case e.link != "":
+		return mode | 0120000
+	}
+	return mode | 0100000
+}
+
+// writeCpioEntry writes an entry in the new ASCII format of cpio, and the
+// content of the regular files to h
+func writeCpioEntry(w io.Writer, ino int, e packageEntry, mtime time.Time, h hash.Hash) error {
+	name := e.name
+	var mode uint32
+	var nlink, size int64
+	switch {
+	case name == "TRAILER!!!":
+		nlink = 1
+	case e.isDir():
+		name, mode, nlink = "."+name, cpioMode(e), 2
+	default:
+		name, mode, nlink, size = "."+name, cpioMode(e), 1, e.size
+	}
+	hdr := fmt.Sprintf("070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%s\x00",
+		ino, mode, 0, 0, nlink, mtime.Unix(), size, 0, 0, 0, 0, len(name)+1, 0, name)
+	if _, err := io.WriteString(w, hdr+strings.Repeat("\x00", pad4(len(hdr)))); err != nil {
+		return err
+	}
+
+	switch {
+	case size == 0:
+		return nil
+	case e.link != "":
+		_, err := io.WriteString(w, e.link)
+		if err != nil {
+			return err
+		}
+	default:
+		dst := w
+		if h != nil {
+			dst = io.MultiWriter(w, h)
+		}
+		if err := copyEntry(dst, e); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, strings.Repeat("\x00", pad4(int(size))))
+	return err
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+// countWriter counts the bytes written to w
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
@@ -20,9 +20,11 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
-	"time"
-
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/goasana/asanacli/cmd/commands"
 	"github.com/goasana/asanacli/cmd/commands/version"
@@ -33,28 +35,49 @@ import (
 )
 
 var cmdRs = &commands.Command{
-	UsageLine: "rs",
+	UsageLine: "rs [-list] [-w] script [args...]",
 	Short:     "Run customized scripts",
 	Long: `Run script allows you to run arbitrary commands using Asana.
   Custom commands are provided from the "scripts" object inside asana.json or Asanafile.
 
   To run a custom command, use: {{"$ asanacli rs mycmd ARGS" | bold}}
+
+  A script is either its command, or an object with a command, a description, an env list of
+  KEY=VALUE, a working dir and deps, the scripts to run before it. The deps run in parallel, each
+  script at most once, and the first failure stops the run with its exit code.
+  To list the scripts, use: {{"$ asanacli rs -list" | bold}}
+
+  To run a script again whenever the files change, use: {{"$ asanacli rs -w mycmd" | bold}}
+  The script is run again on the changes of its watch directories, or of the application, with
+  the watched extensions and the ignored files of the run command. A run still going is killed.
+  A script with a watch list is always run in this mode.
   {{if len .}}
 {{"AVAILABLE SCRIPTS"|headline}}{{range $cmdName, $cmd := .}}
   {{$cmdName | bold}}
-      {{$cmd}}{{end}}{{end}}
+      {{if $cmd.Description}}{{$cmd.Description}}{{else}}{{$cmd.Command}}{{end}}{{end}}{{end}}
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    runScript,
 }
 
+var (
+	list  bool
+	watch bool
+)
+
 func init() {
 	config.LoadConfig()
 	cmdRs.Long = utils.TmplToString(cmdRs.Long, config.Conf.Scripts)
+	cmdRs.Flag.BoolVar(&list, "list", false, "List the scripts with their descriptions.")
+	cmdRs.Flag.BoolVar(&watch, "w", false, "Run the script again whenever the files change.")
 	commands.AvailableCommands = append(commands.AvailableCommands, cmdRs)
 }
 
 func runScript(cmd *commands.Command, args []string) int {
+	if list {
+		listScripts()
+		return 0
+	}
 	if len(args) == 0 {
 		cmd.Usage()
 	}
@@ -62,41 +85,205 @@ func runScript(cmd *commands.Command, args []string) int {
 	start := time.Now()
 	script, args := args[0], args[1:]
 
-	if c, exist := config.Conf.Scripts[script]; exist {
-		command := customCommand{
-			Name:    script,
-			Command: c,
-			Args:    args,
-		}
-		if err := command.run(); err != nil {
-			asanaLogger.Log.Error(err.Error())
-		}
-	} else {
+	if _, exist := config.Conf.Scripts[script]; !exist {
 		asanaLogger.Log.Errorf("Command '%s' not found in Asanafile/asana.json", script)
+		return 1
+	}
+	if err := checkDeps(script, nil); err != nil {
+		asanaLogger.Log.Error(err.Error())
+		return 1
+	}
+
+	if watch || len(config.Conf.Scripts[script].Watch) > 0 {
+		watchScript(script, args)
+		return 0
+	}
+
+	r := newRunner()
+	if code := r.run(script, args); code != 0 {
+		asanaLogger.Log.Errorf("'%s' failed with exit code %d", script, code)
+		return code
 	}
 	elapsed := time.Since(start)
 	fmt.Println(colors.GreenBold(fmt.Sprintf("Finished in %s.", elapsed)))
 	return 0
 }
 
+// listScripts prints the scripts, sorted by name
+func listScripts() {
+	names := make([]string, 0, len(config.Conf.Scripts))
+	for name := range config.Conf.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		s := config.Conf.Scripts[name]
+		desc := s.Description
+		if desc == "" {
+			desc = s.Command
+		}
+		if len(s.Deps) > 0 {
+			desc += colors.Gray(" (deps: " + strings.Join(s.Deps, ", ") + ")")
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", colors.Bold(name), desc)
+	}
+	_ = w.Flush()
+}
+
+// checkDeps checks that the dependencies of a script exist and have no
+// cycle, path being the scripts depending on it
+func checkDeps(name string, path []string) error {
+	for i, p := range path {
+		if p == name {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[i:], name), " -> "))
+		}
+	}
+	s, exist := config.Conf.Scripts[name]
+	if !exist {
+		return fmt.Errorf("dependency '%s' of '%s' not found in Asanafile/asana.json", name, path[len(path)-1])
+	}
+	for _, dep := range s.Deps {
+		if err := checkDeps(dep, append(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runner runs scripts and their dependencies, each one at most once
+type runner struct {
+	sync.Mutex
+	runs   map[string]*scriptRun
+	cmds   map[*exec.Cmd]bool // running commands
+	killed bool
+}
+
+func newRunner() *runner {
+	return &runner{runs: make(map[string]*scriptRun), cmds: make(map[*exec.Cmd]bool)}
+}
+
+type scriptRun struct {
+	done chan struct{}
+	code int
+}
+
+// run runs a script after its dependencies, and returns its exit code
+func (r *runner) run(name string, args []string) int {
+	r.Lock()
+	if sr, ok := r.runs[name]; ok {
+		r.Unlock()
+		<-sr.done
+		return sr.code
+	}
+	sr := &scriptRun{done: make(chan struct{})}
+	r.runs[name] = sr
+	r.Unlock()
+	defer close(sr.done)
+
+	s := config.Conf.Scripts[name]
+	codes := make([]int, len(s.Deps))
+	var wg sync.WaitGroup
+	for i, dep := range s.Deps {
+		wg.Add(1)
+		go func(i int, dep string) {
+			defer wg.Done()
+			codes[i] = r.run(dep, nil)
+		}(i, dep)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != 0 {
+			asanaLogger.Log.Errorf("Skipping '%s', its dependency '%s' failed", name, s.Deps[i])
+			sr.code = code
+			return code
+		}
+	}
+
+	command := customCommand{
+		Name:    name,
+		Command: s.Command,
+		Args:    args,
+		Env:     s.Env,
+		Dir:     s.Dir,
+	}
+	sr.code = r.exec(command)
+	return sr.code
+}
+
+// exec runs a command unless the runner is killed, and returns its exit
+// code
+func (r *runner) exec(c customCommand) int {
+	cmd := c.command()
+	r.Lock()
+	if r.killed {
+		r.Unlock()
+		return 1
+	}
+	asanaLogger.Log.Info(colors.GreenBold(fmt.Sprintf("Running '%s'...", c.Name)))
+	err := cmd.Start()
+	if err == nil {
+		r.cmds[cmd] = true
+	}
+	r.Unlock()
+	if err == nil {
+		err = cmd.Wait()
+		r.Lock()
+		delete(r.cmds, cmd)
+		r.Unlock()
+	}
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
+		if !r.isKilled() {
+			asanaLogger.Log.Errorf("'%s' failed: %s", c.Name, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// kill kills the running commands, the ones not started yet are skipped
+func (r *runner) kill() {
+	r.Lock()
+	defer r.Unlock()
+	r.killed = true
+	for cmd := range r.cmds {
+		killProcess(cmd)
+	}
+}
+
+func (r *runner) isKilled() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.killed
+}
+
 type customCommand struct {
 	Name    string
 	Command string
 	Args    []string
+	Env     []string
+	Dir     string
 }
 
-func (c *customCommand) run() error {
-	asanaLogger.Log.Info(colors.GreenBold(fmt.Sprintf("Running '%s'...", c.Name)))
+// command returns the command running the script
+func (c *customCommand) command() *exec.Cmd {
+	args := append([]string{c.Command}, c.Args...)
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
-	case "darwin", "linux":
-		args := append([]string{c.Command}, c.Args...)
-		cmd = exec.Command("sh", "-c", strings.Join(args, " "))
 	case "windows":
-		args := append([]string{c.Command}, c.Args...)
 		cmd = exec.Command("cmd", "/C", strings.Join(args, " "))
+	default:
+		cmd = exec.Command("sh", "-c", strings.Join(args, " "))
 	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	cmd.Dir = c.Dir
+	cmd.Env = append(os.Environ(), c.Env...)
+	setProcessGroup(cmd)
+	return cmd
 }
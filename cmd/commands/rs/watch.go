@@ -0,0 +1,85 @@
+// Copyright 2017 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package rs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/goasana/asanacli/cmd/commands/run"
+	"github.com/goasana/asanacli/config"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+// watchScript runs a script, and again whenever the files of its watch
+// directories change, killing the previous run if it is still going
+func watchScript(name string, args []string) {
+	dirs := config.Conf.Scripts[name].Watch
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var paths []string
+	for _, dir := range dirs {
+		if !utils.IsExist(dir) {
+			asanaLogger.Log.Warnf("Skipped directory: %s", dir)
+			continue
+		}
+		paths = append(paths, run.WatchDirs(dir)...)
+	}
+	if len(paths) == 0 {
+		asanaLogger.Log.Fatalf("No file to watch for '%s'", name)
+	}
+
+	var (
+		mu      sync.Mutex
+		current *runner
+		done    chan struct{}
+	)
+	start := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if current != nil {
+			current.kill()
+			<-done
+		}
+		r, d := newRunner(), make(chan struct{})
+		current, done = r, d
+		go func() {
+			defer close(d)
+			start := time.Now()
+			code := r.run(name, args)
+			if r.isKilled() {
+				asanaLogger.Log.Warnf("'%s' killed", name)
+				return
+			}
+			if code != 0 {
+				msg := fmt.Sprintf("'%s' failed with exit code %d", name, code)
+				utils.Notify(msg, "Script Failed")
+				asanaLogger.Log.Error(msg)
+				return
+			}
+			msg := fmt.Sprintf("'%s' passed in %s", name, time.Since(start))
+			utils.Notify(msg, "Script Passed")
+			asanaLogger.Log.Success(msg)
+		}()
+	}
+
+	run.WatchFiles(paths, func(fsnotify.Event) { start() })
+	start()
+	select {}
+}
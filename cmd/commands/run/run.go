@@ -23,6 +23,7 @@ import (
 	"github.com/goasana/asanacli/cmd/commands"
 	"github.com/goasana/asanacli/cmd/commands/version"
 	"github.com/goasana/asanacli/config"
+	"github.com/goasana/asanacli/generate/swaggergen"
 	asanaLogger "github.com/goasana/asanacli/logger"
 	"github.com/goasana/asanacli/utils"
 )
@@ -68,7 +69,7 @@ var started = make(chan bool)
 func init() {
 	CmdRun.Flag.Var(&mainFiles, "main", "Specify main go files.")
 	CmdRun.Flag.Var(&gendoc, "gendoc", "Enable auto-generate the docs.")
-	CmdRun.Flag.Var(&downdoc, "downdoc", "Enable auto-download of the swagger file if it does not exist.")
+	CmdRun.Flag.Var(&downdoc, "downdoc", "Enable writing the embedded swagger UI if it does not exist.")
 	CmdRun.Flag.Var(&excludedPaths, "e", "List of paths to exclude.")
 	CmdRun.Flag.BoolVar(&vendorWatch, "vendor", false, "Enable watch vendor folder.")
 	CmdRun.Flag.StringVar(&buildTags, "tags", "", "Set the build tags. See: https://golang.org/pkg/go/build/")
@@ -136,6 +137,9 @@ func RunApp(cmd *commands.Command, args []string) int {
 	for _, p := range config.Conf.DirStruct.Others {
 		paths = append(paths, strings.Replace(p, "$GOPATH", currentGoPath, -1))
 	}
+	for _, p := range config.Conf.Bale.Dirs {
+		readBaleDirectories(p, &paths)
+	}
 
 	if len(extraPackages) > 0 {
 		// get the full path
@@ -166,11 +170,8 @@ func RunApp(cmd *commands.Command, args []string) int {
 		}
 	}
 	if downdoc == "true" {
-		if _, err := os.Stat(path.Join(appPath, "swagger", "index.html")); err != nil {
-			if os.IsNotExist(err) {
-				downloadFromURL(swaggerLink, "swagger.zip")
-				_ = unzipAndDelete("swagger.zip")
-			}
+		if err := swaggergen.WriteUI(path.Join(appPath, "swagger")); err != nil {
+			asanaLogger.Log.Errorf("Error while writing the swagger UI: %s", err)
 		}
 	}
 
@@ -178,6 +179,10 @@ func RunApp(cmd *commands.Command, args []string) int {
 	if config.Conf.EnableReload {
 		startReloadServer()
 	}
+	// Bale the resources first, they are then baled again when they change
+	if len(config.Conf.Bale.Dirs) > 0 {
+		Rebale()
+	}
 	if gendoc == "true" {
 		NewWatcher(paths, files, true)
 		AutoBuild(files, true)
@@ -231,6 +236,23 @@ func readAppDirectories(directory string, paths *[]string) {
 	}
 }
 
+// readBaleDirectories adds a baled directory and its subdirectories to the
+// watched paths, whatever the files they hold
+func readBaleDirectories(directory string, paths *[]string) {
+	_ = path.Walk(directory, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if isExcluded(p) {
+			return path.SkipDir
+		}
+		if absP, err := path.Abs(p); err == nil {
+			*paths = append(*paths, absP)
+		}
+		return nil
+	})
+}
+
 // If a file is excluded
 func isExcluded(filePath string) bool {
 	for _, p := range excludedPaths {
@@ -18,13 +18,16 @@ import (
 	"bytes"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/goasana/asanacli/cmd/commands/bale"
 	"github.com/goasana/asanacli/config"
+	"github.com/goasana/asanacli/generate/swaggergen"
 	asanaLogger "github.com/goasana/asanacli/logger"
 	"github.com/goasana/asanacli/logger/colors"
 	"github.com/goasana/asanacli/utils"
@@ -36,8 +39,6 @@ var (
 	state               sync.Mutex
 	eventTime           = make(map[string]int64)
 	scheduleTime        time.Time
-	watchExts           = config.Conf.WatchExts
-	watchExtsStatic     = config.Conf.WatchExtsStatic
 	ignoredFilesRegExps = []string{
 		`.#(\w+).go`,
 		`.(\w+).go.swp`,
@@ -59,8 +60,10 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 			select {
 			case e := <-watcher.Events:
 				isBuild := true
+				// The files of the baled directories are baled again before the build
+				isBale := isBaleFile(e.Name)
 
-				if ifStaticFile(e.Name) && config.Conf.EnableReload {
+				if !isBale && ifStaticFile(e.Name) && config.Conf.EnableReload {
 					sendReload(e.String())
 					continue
 				}
@@ -68,7 +71,11 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 				if shouldIgnoreFile(e.Name) {
 					continue
 				}
-				if !shouldWatchFileWithExtension(e.Name) {
+				// Skip the files written when baling, the build follows anyway
+				if isBaleOutput(e.Name) {
+					continue
+				}
+				if !isBale && !shouldWatchFileWithExtension(e.Name) {
 					continue
 				}
 
@@ -86,6 +93,9 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 						// Wait 1s before autobuild until there is no file change.
 						scheduleTime = time.Now().Add(1 * time.Second)
 						time.Sleep(time.Until(scheduleTime))
+						if isBale {
+							Rebale()
+						}
 						AutoBuild(files, isgenerate)
 
 						if config.Conf.EnableReload {
@@ -101,10 +111,53 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 		}
 	}()
 
+	watchPaths(watcher, paths)
+}
+
+// WatchDirs returns dir and its subdirectories holding files to watch
+func WatchDirs(dir string) []string {
+	var paths []string
+	readAppDirectories(dir, &paths)
+	return paths
+}
+
+// WatchFiles starts an fsnotify Watcher on the specified paths, and calls
+// fn once the files stop changing. The files are filtered as for the
+// builds, by their extension and the ignored names.
+func WatchFiles(paths []string, fn func(fsnotify.Event)) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		asanaLogger.Log.Fatalf("Failed to create watcher: %s", err)
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case e := <-watcher.Events:
+				if e.Op == fsnotify.Chmod || shouldIgnoreFile(e.Name) || !shouldWatchFileWithExtension(e.Name) {
+					continue
+				}
+				asanaLogger.Log.Hintf("Event fired: %s", e)
+				// Wait 1s until there is no file change
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(1*time.Second, func() { fn(e) })
+			case err := <-watcher.Errors:
+				asanaLogger.Log.Warnf("Watcher error: %s", err.Error()) // No need to exit here
+			}
+		}
+	}()
+
+	watchPaths(watcher, paths)
+}
+
+func watchPaths(watcher *fsnotify.Watcher, paths []string) {
 	asanaLogger.Log.Info("Initializing watcher...")
 	for _, path := range paths {
 		asanaLogger.Log.Hintf(colors.Bold("Watching: ")+"%s", path)
-		err = watcher.Add(path)
+		err := watcher.Add(path)
 		if err != nil {
 			asanaLogger.Log.Fatalf("Failed to watch directory: %s", err)
 		}
@@ -134,18 +187,6 @@ func AutoBuild(files []string, isgenerate bool) {
 		icmd.Run()
 	}
 
-	if isgenerate {
-		asanaLogger.Log.Info("Generating the docs...")
-		icmd := exec.Command("asana", "generate", "docs")
-		icmd.Env = append(os.Environ(), "GOGC=off")
-		err = icmd.Run()
-		if err != nil {
-			utils.Notify("", "Failed to generate the docs.")
-			asanaLogger.Log.Errorf("Failed to generate the docs.")
-			return
-		}
-		asanaLogger.Log.Success("Docs generated!")
-	}
 	appName := appname
 	if err == nil {
 
@@ -172,9 +213,39 @@ func AutoBuild(files []string, isgenerate bool) {
 	}
 
 	asanaLogger.Log.Success("Built Successfully!")
+
+	// The docs are generated in-process once the build succeeded, so that
+	// the syntax errors are reported by the build. Between two builds, only
+	// the files which changed are parsed again.
+	if isgenerate {
+		asanaLogger.Log.Info("Generating the docs...")
+		if err := swaggergen.RegenerateDocs(currpath); err != nil {
+			utils.Notify(err.Error(), "Failed to generate the docs.")
+			asanaLogger.Log.Errorf("Failed to generate the docs: %s", err)
+		} else {
+			asanaLogger.Log.Success("Docs generated!")
+		}
+	}
 	Restart(appName)
 }
 
+// Rebale bales the directories of the Asanafile again, so that the
+// embedded files are up to date in the next build
+func Rebale() {
+	state.Lock()
+	defer state.Unlock()
+
+	changed, err := bale.Update()
+	if err != nil {
+		utils.Notify(err.Error(), "Bale Failed")
+		asanaLogger.Log.Errorf("Failed to bale the resources: %s", err)
+		return
+	}
+	if changed {
+		asanaLogger.Log.Success("Baled resources successfully!")
+	}
+}
+
 // Kill kills the running command process
 func Kill() {
 	defer func() {
@@ -242,7 +313,7 @@ func Start(appname string) {
 }
 
 func ifStaticFile(filename string) bool {
-	for _, s := range watchExtsStatic {
+	for _, s := range config.Conf.WatchExtsStatic {
 		if strings.HasSuffix(filename, s) {
 			return true
 		}
@@ -269,10 +340,42 @@ func shouldIgnoreFile(filename string) bool {
 // shouldWatchFileWithExtension returns true if the name of the file
 // hash a suffix that should be watched.
 func shouldWatchFileWithExtension(name string) bool {
-	for _, s := range watchExts {
+	for _, s := range config.Conf.WatchExts {
 		if strings.HasSuffix(name, s) {
 			return true
 		}
 	}
 	return false
 }
+
+// isBaleFile returns true if the file is in one of the baled directories
+func isBaleFile(name string) bool {
+	for _, d := range config.Conf.Bale.Dirs {
+		if isInPath(name, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBaleOutput returns true if the file is written when baling: the bale
+// package and bale.go of the unpack mode
+func isBaleOutput(name string) bool {
+	if len(config.Conf.Bale.Dirs) == 0 {
+		return false
+	}
+	return isInPath(name, "bale") || isInPath(name, "bale.go")
+}
+
+// isInPath returns true if name is p or in the directory p
+func isInPath(name, p string) bool {
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	absP, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	return absName == absP || strings.HasPrefix(absName, absP+string(filepath.Separator))
+}
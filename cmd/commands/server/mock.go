@@ -0,0 +1,642 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package apiapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const maxExampleDepth = 5
+
+var httpMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// mockServer serves the operations of a Swagger 2.0 or OpenAPI 3 document,
+// as generated by generate docs, with example responses
+type mockServer struct {
+	doc    map[string]interface{}
+	routes []*mockRoute
+}
+
+type mockRoute struct {
+	path       string // path of the document, e.g. /v1/user/{id}
+	pattern    *regexp.Regexp
+	names      []string // names of the path params, in order
+	operations map[string]map[string]interface{}
+	params     []interface{} // params of the path, shared by its operations
+}
+
+// newMockServer loads the document at fpath, in JSON or YAML
+func newMockServer(fpath string) (*mockServer, error) {
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if strings.HasSuffix(fpath, ".yml") || strings.HasSuffix(fpath, ".yaml") {
+		err = yaml.Unmarshal(data, &doc)
+		doc = yamlToJSON(doc)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %s", fpath, err)
+	}
+	m := &mockServer{}
+	m.doc, _ = doc.(map[string]interface{})
+	paths, _ := m.doc["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no path found in '%s'", fpath)
+	}
+
+	base := strings.TrimRight(m.basePath(), "/")
+	paramRegexp := regexp.MustCompile(`{([^}]+)}`)
+	for p, item := range paths {
+		item, _ := item.(map[string]interface{})
+		r := &mockRoute{path: p, operations: make(map[string]map[string]interface{})}
+		r.params, _ = item["parameters"].([]interface{})
+		for _, method := range httpMethods {
+			if op, ok := item[method].(map[string]interface{}); ok {
+				r.operations[strings.ToUpper(method)] = op
+			}
+		}
+		// the paths match with or without a trailing slash
+		pattern := regexp.QuoteMeta(strings.TrimRight(base+p, "/"))
+		for _, match := range paramRegexp.FindAllStringSubmatch(p, -1) {
+			r.names = append(r.names, match[1])
+			pattern = strings.Replace(pattern, regexp.QuoteMeta(match[0]), "([^/]+)", 1)
+		}
+		r.pattern = regexp.MustCompile("^" + pattern + "/?$")
+		m.routes = append(m.routes, r)
+	}
+	// the paths with fewer params are matched first, e.g. /user/me before /user/{id}
+	sort.Slice(m.routes, func(i, j int) bool {
+		if len(m.routes[i].names) != len(m.routes[j].names) {
+			return len(m.routes[i].names) < len(m.routes[j].names)
+		}
+		return m.routes[i].path < m.routes[j].path
+	})
+	return m, nil
+}
+
+// yamlToJSON converts the maps decoded by yaml to the ones of encoding/json
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = yamlToJSON(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = yamlToJSON(value)
+		}
+	}
+	return v
+}
+
+func (m *mockServer) isOpenAPI3() bool {
+	_, ok := m.doc["openapi"]
+	return ok
+}
+
+// basePath returns the path the operations are served under, from the
+// basePath of Swagger 2.0 or the first server of OpenAPI 3
+func (m *mockServer) basePath() string {
+	if !m.isOpenAPI3() {
+		base, _ := m.doc["basePath"].(string)
+		return base
+	}
+	servers, _ := m.doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	u, err := url.Parse(fmt.Sprint(server["url"]))
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// resolve follows the $ref of an object of the document
+func (m *mockServer) resolve(v interface{}) map[string]interface{} {
+	obj, _ := v.(map[string]interface{})
+	for i := 0; i < maxExampleDepth; i++ {
+		ref, ok := obj["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			break
+		}
+		var target interface{} = m.doc
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			parent, _ := target.(map[string]interface{})
+			target = parent[key]
+		}
+		obj, _ = target.(map[string]interface{})
+	}
+	return obj
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	m.serve(rw, req)
+	asanaLogger.Log.Infof("%s %s %d %s", req.Method, req.URL.RequestURI(), rw.status, time.Since(start))
+}
+
+func (m *mockServer) serve(w http.ResponseWriter, req *http.Request) {
+	// allow the frontend served by another origin to call the mock
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Methods", strings.ToUpper(strings.Join(httpMethods, ", ")))
+
+	// the first route of the path with an operation for the method is
+	// served, e.g. GET /user/{uid} after DELETE /user/{id}
+	var matched *mockRoute
+	var pathValues []string
+	var op map[string]interface{}
+	allowed := make(map[string]bool)
+	for _, r := range m.routes {
+		match := r.pattern.FindStringSubmatch(req.URL.Path)
+		if match == nil {
+			continue
+		}
+		if o, ok := r.operations[req.Method]; ok {
+			matched, pathValues, op = r, match[1:], o
+			break
+		}
+		for method := range r.operations {
+			allowed[method] = true
+		}
+	}
+	if matched == nil && len(allowed) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "no operation for " + req.URL.Path})
+		return
+	}
+	if matched == nil {
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var methods []string
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"message": req.Method + " is not allowed on " + req.URL.Path})
+		return
+	}
+
+	path := make(map[string]string)
+	for i, name := range matched.names {
+		path[name], _ = url.PathUnescape(pathValues[i])
+	}
+	if errs := m.validate(req, path, matched, op); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "invalid request", "errors": errs})
+		return
+	}
+	status, example, hasBody := m.response(op)
+	if !hasBody || req.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, example)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// validate checks the params of a request against the ones of the
+// operation, and returns the errors
+func (m *mockServer) validate(req *http.Request, path map[string]string, r *mockRoute, op map[string]interface{}) []string {
+	var errs []string
+	params := make(map[string]map[string]interface{})
+	var order []string
+	list, _ := op["parameters"].([]interface{})
+	for _, p := range append(append([]interface{}{}, r.params...), list...) {
+		para := m.resolve(p)
+		if para == nil {
+			continue
+		}
+		key := fmt.Sprint(para["in"], ":", para["name"])
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = para // the params of the operation override the ones of the path
+	}
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		_ = req.ParseMultipartForm(32 << 20)
+	} else {
+		_ = req.ParseForm()
+	}
+	for _, key := range order {
+		para := params[key]
+		name, _ := para["name"].(string)
+		in, _ := para["in"].(string)
+		required, _ := para["required"].(bool)
+		if in == "body" {
+			errs = append(errs, m.validateBody(req, para["schema"], required)...)
+			continue
+		}
+		schema := para
+		if s, ok := para["schema"].(map[string]interface{}); ok {
+			schema = m.resolve(s)
+		}
+
+		var values []string
+		switch in {
+		case "path":
+			if v, ok := path[name]; ok {
+				values = []string{v}
+			}
+			required = true
+		case "query":
+			values = req.URL.Query()[name]
+		case "header":
+			values = req.Header[http.CanonicalHeaderKey(name)]
+		case "cookie":
+			if c, err := req.Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		case "formData":
+			if req.MultipartForm != nil {
+				if _, ok := req.MultipartForm.File[name]; ok {
+					continue
+				}
+			}
+			values = req.PostForm[name]
+		}
+		if len(values) == 0 {
+			if required {
+				errs = append(errs, fmt.Sprintf("%s param '%s' is required", in, name))
+			}
+			continue
+		}
+		if schema["type"] == "array" {
+			if len(values) == 1 && para["collectionFormat"] != "multi" {
+				values = strings.Split(values[0], ",")
+			}
+			schema = m.resolve(schema["items"])
+		} else {
+			values = values[:1]
+		}
+		for _, v := range values {
+			if err := checkValue(v, schema); err != "" {
+				errs = append(errs, fmt.Sprintf("%s param '%s' %s", in, name, err))
+			}
+		}
+	}
+
+	if body, ok := op["requestBody"]; ok {
+		body := m.resolve(body)
+		required, _ := body["required"].(bool)
+		content, _ := body["content"].(map[string]interface{})
+		if media, ok := content["application/json"].(map[string]interface{}); ok {
+			errs = append(errs, m.validateBody(req, media["schema"], required)...)
+		}
+	}
+	return errs
+}
+
+// checkValue checks the string value of a param against its schema
+func checkValue(v string, schema map[string]interface{}) string {
+	var err error
+	switch schema["type"] {
+	case "integer":
+		_, err = strconv.ParseInt(v, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(v, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(v)
+	}
+	if err != nil {
+		return fmt.Sprintf("must be of type %s, got '%s'", schema["type"], v)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		for _, e := range enum {
+			if fmt.Sprint(e) == v {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %v, got '%s'", enum, v)
+	}
+	return ""
+}
+
+func (m *mockServer) validateBody(req *http.Request, schema interface{}, required bool) []string {
+	data, _ := ioutil.ReadAll(req.Body)
+	if len(strings.TrimSpace(string(data))) == 0 {
+		if required {
+			return []string{"body is required"}
+		}
+		return nil
+	}
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return []string{"body is not valid JSON: " + err.Error()}
+	}
+	return m.checkSchema("body", body, schema, 0)
+}
+
+// checkSchema checks a JSON value against a schema: its type, the required
+// properties and the enums
+func (m *mockServer) checkSchema(at string, v interface{}, schema interface{}, depth int) []string {
+	s := m.resolve(schema)
+	if s == nil || depth > maxExampleDepth {
+		return nil
+	}
+	if v == nil {
+		if nullable, _ := s["nullable"].(bool); nullable {
+			return nil
+		}
+		if nullable, _ := s["x-nullable"].(bool); nullable {
+			return nil
+		}
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		if list, ok := s[key].([]interface{}); ok && len(list) > 0 {
+			if key == "allOf" {
+				var errs []string
+				for _, sub := range list {
+					errs = append(errs, m.checkSchema(at, v, sub, depth+1)...)
+				}
+				return errs
+			}
+			var errs []string
+			for _, sub := range list {
+				if errs = m.checkSchema(at, v, sub, depth+1); len(errs) == 0 {
+					return nil
+				}
+			}
+			return errs
+		}
+	}
+
+	types := []string{}
+	switch t := s["type"].(type) {
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, tt := range t {
+			types = append(types, fmt.Sprint(tt))
+		}
+	}
+	if len(types) > 0 {
+		ok := false
+		for _, t := range types {
+			ok = ok || jsonType(v, t)
+		}
+		if !ok {
+			return []string{fmt.Sprintf("%s must be of type %s", at, strings.Join(types, " or "))}
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			found = found || fmt.Sprint(e) == fmt.Sprint(v)
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s must be one of %v", at, enum)}
+		}
+	}
+
+	var errs []string
+	switch v := v.(type) {
+	case []interface{}:
+		for i, item := range v {
+			errs = append(errs, m.checkSchema(fmt.Sprintf("%s[%d]", at, i), item, s["items"], depth+1)...)
+		}
+	case map[string]interface{}:
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[fmt.Sprint(r)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s.%s is required", at, r))
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		var names []string
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p, ok := props[name]; ok {
+				errs = append(errs, m.checkSchema(at+"."+name, v[name], p, depth+1)...)
+			} else if additional, ok := s["additionalProperties"].(map[string]interface{}); ok {
+				errs = append(errs, m.checkSchema(at+"."+name, v[name], additional, depth+1)...)
+			}
+		}
+	}
+	return errs
+}
+
+func jsonType(v interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+// response returns the status and the example of the success response of
+// an operation, the lowest 2xx one
+func (m *mockServer) response(op map[string]interface{}) (int, interface{}, bool) {
+	responses, _ := op["responses"].(map[string]interface{})
+	status, code := 0, ""
+	for c := range responses {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 200 || n > 299 {
+			continue
+		}
+		if status == 0 || n < status {
+			status, code = n, c
+		}
+	}
+	if status == 0 {
+		return http.StatusOK, nil, false
+	}
+	resp := m.resolve(responses[code])
+	if status == http.StatusNoContent {
+		return status, nil, false
+	}
+
+	if !m.isOpenAPI3() {
+		if examples, ok := resp["examples"].(map[string]interface{}); ok {
+			if example, ok := examples["application/json"]; ok {
+				return status, example, true
+			}
+		}
+		schema, ok := resp["schema"]
+		if !ok {
+			return status, nil, false
+		}
+		return status, m.example(schema, 0), true
+	}
+	content, _ := resp["content"].(map[string]interface{})
+	media, ok := content["application/json"].(map[string]interface{})
+	if !ok {
+		return status, nil, false
+	}
+	if example, ok := media["example"]; ok {
+		return status, example, true
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok {
+		var names []string
+		for name := range examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := m.resolve(examples[name])["value"]; ok {
+				return status, value, true
+			}
+		}
+	}
+	return status, m.example(media["schema"], 0), true
+}
+
+// example synthesizes an example value from a schema
+func (m *mockServer) example(schema interface{}, depth int) interface{} {
+	s := m.resolve(schema)
+	if s == nil || depth > maxExampleDepth {
+		return nil
+	}
+	for _, key := range []string{"example", "default"} {
+		if v, ok := s[key]; ok {
+			return v
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if list, ok := s["allOf"].([]interface{}); ok {
+		obj := make(map[string]interface{})
+		for _, sub := range list {
+			if props, ok := m.example(sub, depth+1).(map[string]interface{}); ok {
+				for name, v := range props {
+					obj[name] = v
+				}
+			}
+		}
+		return obj
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if list, ok := s[key].([]interface{}); ok && len(list) > 0 {
+			return m.example(list[0], depth+1)
+		}
+	}
+
+	typ, _ := s["type"].(string)
+	if types, ok := s["type"].([]interface{}); ok {
+		// OpenAPI 3.1 type lists, e.g. ["string", "null"]
+		for _, t := range types {
+			if t != "null" {
+				typ = fmt.Sprint(t)
+				break
+			}
+		}
+	}
+	format, _ := s["format"].(string)
+	switch typ {
+	case "array":
+		return []interface{}{m.example(s["items"], depth+1)}
+	case "object", "":
+		obj := make(map[string]interface{})
+		props, _ := s["properties"].(map[string]interface{})
+		for name, p := range props {
+			obj[name] = m.example(p, depth+1)
+		}
+		if additional, ok := s["additionalProperties"].(map[string]interface{}); ok {
+			obj["key"] = m.example(additional, depth+1)
+		}
+		return obj
+	case "integer":
+		if min, ok := s["minimum"].(float64); ok {
+			return int64(min) + 1
+		}
+		return 1
+	case "number":
+		if min, ok := s["minimum"].(float64); ok {
+			return min + 1
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		switch format {
+		case "datetime", "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "date":
+			return "2006-01-02"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "https://example.com"
+		case "byte":
+			return ""
+		}
+		return "string"
+	}
+	return nil
+}
@@ -32,21 +32,31 @@ var CmdServer = &commands.Command{
 	Short:     "serving static content over HTTP on port",
 	Long: `
   The command 'server' creates a Asana API application.
+
+  ▶ {{"To serve a mock of the API from the generated docs:"|bold}}
+
+     $ asanacli server -mock=swagger/swagger.json
+
+     Every documented path and method is routed. The params are validated against
+     their @Param definitions, and an example response is returned from the @Success
+     schema, so that the frontend can be developed before the backend exists.
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    createAPI,
 }
 
 var (
-	a utils.DocValue
-	p utils.DocValue
-	f utils.DocValue
+	a   utils.DocValue
+	p   utils.DocValue
+	f   utils.DocValue
+	doc utils.DocValue
 )
 
 func init() {
 	CmdServer.Flag.Var(&a, "a", "Listen address")
 	CmdServer.Flag.Var(&p, "p", "Listen port")
 	CmdServer.Flag.Var(&f, "f", "Static files fold")
+	CmdServer.Flag.Var(&doc, "mock", "Swagger or OpenAPI document to serve a mock of the API from")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdServer)
 }
 
@@ -63,6 +73,18 @@ func createAPI(cmd *commands.Command, args []string) int {
 	if p == "" {
 		p = "8080"
 	}
+	if doc != "" {
+		mock, err := newMockServer(string(doc))
+		if err != nil {
+			asanaLogger.Log.Fatalf("Could not load the mock: %s", err)
+		}
+		asanaLogger.Log.Infof("Start mock server on http://%s:%s, docs %s", a, p, doc)
+		err = http.ListenAndServe(string(a)+":"+string(p), mock)
+		if err != nil {
+			asanaLogger.Log.Error(err.Error())
+		}
+		return 0
+	}
 	if f == "" {
 		cwd, _ := os.Getwd()
 		f = utils.DocValue(cwd)
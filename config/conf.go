@@ -35,9 +35,11 @@ var Conf = struct {
 	Envs               []string
 	Bale               bale
 	Database           database
+	Docs               docs
+	Pack               pack
 	EnableReload       bool              `json:"enable_reload" yaml:"enable_reload"`
 	EnableNotification bool              `json:"enable_notification" yaml:"enable_notification"`
-	Scripts            map[string]string `json:"scripts" yaml:"scripts"`
+	Scripts            map[string]script `json:"scripts" yaml:"scripts"`
 }{
 	WatchExts:       []string{".go"},
 	WatchExtsStatic: []string{".html", ".tpl", ".js", ".css"},
@@ -55,7 +57,7 @@ var Conf = struct {
 		Driver: "mysql",
 	},
 	EnableNotification: true,
-	Scripts:            map[string]string{},
+	Scripts:            map[string]script{},
 }
 
 // dirStruct describes the application's directory structure
@@ -68,16 +70,78 @@ type dirStruct struct {
 
 // bale
 type bale struct {
-	Import string
-	Dirs   []string
-	IngExt []string `json:"ignore_ext" yaml:"ignore_ext"`
+	Import   string
+	Dirs     []string
+	IngExt   []string `json:"ignore_ext" yaml:"ignore_ext"`
+	Mode     string   // "embed" for a go:embed package, the files are unpacked to disk otherwise
+	Compress []string // pre-compressed variants of the embed mode: gzip and/or br
 }
 
 // database holds the database connection information
 type database struct {
-	Driver string
-	Conn   string
-	Dir    string
+	Driver   string
+	Conn     string
+	Dir      string
+	Nullable string            // Go type of nullable columns in appcode: "" (tag only), "pointer" or "sql"
+	Types    map[string]goType // Go type overrides, keyed by SQL type or table.column
+}
+
+// goType is a Go type and the package to import for it,
+// e.g. decimal.Decimal from github.com/shopspring/decimal
+type goType struct {
+	Type   string
+	Import string
+}
+
+// docs holds the documentation generation settings
+type docs struct {
+	Router string // router package directory or entry file, relative to the application
+}
+
+// pack describes the content of the archives of pack
+type pack struct {
+	Include []string          // gitignore-style patterns of the files to pack, all of them by default
+	Exclude []string          // gitignore-style patterns of the files not to pack
+	Rename  map[string]string // file or directory:its path in the archive
+	Modes   map[string]string // gitignore-style pattern:octal mode of the matching files
+
+	// .deb and .rpm packages
+	InstallDir  string `json:"install_dir" yaml:"install_dir"` // defaults to /opt/<application>
+	Maintainer  string // defaults to the git user
+	Description string
+	Unit        string // systemd unit file to install instead of the generated one
+}
+
+// script is a script of rs. It can also be declared as its command alone.
+type script struct {
+	Command     string
+	Description string
+	Env         []string // KEY=VALUE
+	Dir         string   // working directory, relative to the application
+	Deps        []string // scripts to run before, in parallel
+	Watch       []string // directories whose changes run the script again
+}
+
+// UnmarshalJSON reads a script or its command
+func (s *script) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		*s = script{Command: command}
+		return nil
+	}
+	type plain script
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// UnmarshalYAML reads a script or its command
+func (s *script) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var command string
+	if err := unmarshal(&command); err == nil {
+		*s = script{Command: command}
+		return nil
+	}
+	type plain script
+	return unmarshal((*plain)(s))
 }
 
 // LoadConfig loads the asana tool configuration.
@@ -22,3 +22,7 @@ var Level utils.DocValue
 var Tables utils.DocValue
 var Fields utils.DocValue
 var DDL utils.DocValue
+var OpenAPI utils.DocValue
+var Lang utils.DocValue
+var Output utils.DocValue
+var Format utils.DocValue
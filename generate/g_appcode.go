@@ -17,6 +17,7 @@ package generate
 import (
 	"database/sql"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -24,7 +25,6 @@ import (
 	"strings"
 
 	asanaLogger "github.com/goasana/asanacli/logger"
-	"github.com/goasana/asanacli/logger/colors"
 	"github.com/goasana/asanacli/utils"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -102,6 +102,7 @@ var typeMappingMysql = map[string]string{
 	"binary":             "string", // binary
 	"varbinary":          "string",
 	"year":               "int16",
+	"json":               "string", // json
 }
 
 // typeMappingPostgres maps SQL data type to corresponding Go data type
@@ -140,12 +141,14 @@ var typeMappingPostgres = map[string]string{
 
 // Table represent a table in a database
 type Table struct {
-	Name          string
-	Pk            string
-	Uk            []string
-	Fk            map[string]*ForeignKey
-	Columns       []*Column
-	ImportTimePkg bool
+	Name     string
+	Pk       string
+	Uk       []string
+	Fk       map[string]*ForeignKey
+	Columns  []*Column
+	Imports  map[string]string // package name: import path of the Go types from the Asanafile
+	Expands  []string          // relation fields which can be loaded with ?expand=
+	Children []*ChildRoute     // nested routes of the tables referencing this one
 }
 
 // Column reprsents a column for a table
@@ -183,6 +186,7 @@ type OrmTag struct {
 	RelFk       bool
 	ReverseMany bool
 	RelM2M      bool
+	RelThrough  string //full path of the join model of a many-to-many relation
 	Comment     string //column comment
 }
 
@@ -244,12 +248,18 @@ func (tag *OrmTag) String() string {
 	if tag.RelM2M {
 		ormOptions = append(ormOptions, "rel(m2m)")
 	}
+	if tag.RelThrough != "" {
+		ormOptions = append(ormOptions, fmt.Sprintf("rel_through(%s)", tag.RelThrough))
+	}
 	if tag.Pk {
 		ormOptions = append(ormOptions, "pk")
 	}
 	if tag.Unique {
 		ormOptions = append(ormOptions, "unique")
 	}
+	if tag.Index {
+		ormOptions = append(ormOptions, "index")
+	}
 	if tag.Default != "" {
 		ormOptions = append(ormOptions, fmt.Sprintf("default(%s)", tag.Default))
 	}
@@ -318,6 +328,7 @@ func gen(dbms, connStr string, mode byte, selectedTableNames map[string]bool, ap
 		mvcPath.RouterPath = path.Join(apppath, "routers")
 		createPaths(mode, mvcPath)
 		pkgPath := getPackagePath(apppath)
+		resolveRelations(tables, pkgPath)
 		writeSourceFiles(pkgPath, tables, mode, mvcPath)
 	} else {
 		asanaLogger.Log.Fatalf("Generating app code from '%s' database is not supported yet.", dbms)
@@ -353,6 +364,7 @@ func getTableObjects(tableNames []string, db *sql.DB, dbTransformer DbTransforme
 		tb := new(Table)
 		tb.Name = tableName
 		tb.Fk = make(map[string]*ForeignKey)
+		tb.Imports = make(map[string]string)
 		dbTransformer.GetConstraints(db, tb, blackList)
 		tables = append(tables, tb)
 	}
@@ -495,8 +507,6 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 					} else if columnDefault == "CURRENT_TIMESTAMP" {
 						tag.AutoNowAdd = true
 					}
-					// need to import time package
-					table.ImportTimePkg = true
 				}
 				if isSQLDecimal(dataType) {
 					tag.Digits, tag.Decimals = extractDecimal(columnType)
@@ -507,6 +517,7 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 				if isSQLBitType(dataType) {
 					tag.Size = extractColSize(columnType)
 				}
+				col.Type = columnGoType(table, colName, col.Type, tag.Null, dataType)
 			}
 		}
 		col.Tag = tag
@@ -615,7 +626,8 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			END AS column_type,
 			is_nullable,
 			column_default,
-			'' AS extra
+			'' AS extra,
+			udt_name
 		FROM
 			information_schema.columns
 		WHERE
@@ -629,12 +641,12 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 
 	for colDefRows.Next() {
 		// datatype as bytes so that SQL <null> values can be retrieved
-		var colNameBytes, dataTypeBytes, columnTypeBytes, isNullableBytes, columnDefaultBytes, extraBytes []byte
-		if err := colDefRows.Scan(&colNameBytes, &dataTypeBytes, &columnTypeBytes, &isNullableBytes, &columnDefaultBytes, &extraBytes); err != nil {
+		var colNameBytes, dataTypeBytes, columnTypeBytes, isNullableBytes, columnDefaultBytes, extraBytes, udtNameBytes []byte
+		if err := colDefRows.Scan(&colNameBytes, &dataTypeBytes, &columnTypeBytes, &isNullableBytes, &columnDefaultBytes, &extraBytes, &udtNameBytes); err != nil {
 			asanaLogger.Log.Fatalf("Could not query INFORMATION_SCHEMA for column information: %s", err)
 		}
-		colName, dataType, columnType, isNullable, columnDefault, extra :=
-			string(colNameBytes), string(dataTypeBytes), string(columnTypeBytes), string(isNullableBytes), string(columnDefaultBytes), string(extraBytes)
+		colName, dataType, columnType, isNullable, columnDefault, extra, udtName :=
+			string(colNameBytes), string(dataTypeBytes), string(columnTypeBytes), string(isNullableBytes), string(columnDefaultBytes), string(extraBytes), string(udtNameBytes)
 		// Create a column
 		col := new(Column)
 		col.Name = utils.CamelCase(colName)
@@ -685,8 +697,6 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 					} else if columnDefault == "CURRENT_TIMESTAMP" {
 						tag.AutoNowAdd = true
 					}
-					// need to import time package
-					table.ImportTimePkg = true
 				}
 				if isSQLDecimal(dataType) {
 					tag.Digits, tag.Decimals = extractDecimal(columnType)
@@ -697,6 +707,12 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 				if isSQLStrangeType(dataType) {
 					tag.Type = dataType
 				}
+				// arrays can be overridden by element type, e.g. _int4
+				sqlTypes := []string{dataType}
+				if dataType == "ARRAY" {
+					sqlTypes = []string{udtName, dataType}
+				}
+				col.Type = columnGoType(table, colName, col.Type, tag.Null, sqlTypes...)
 			}
 		}
 		col.Tag = tag
@@ -726,8 +742,8 @@ func createPaths(mode byte, paths *MvcPath) {
 }
 
 // writeSourceFiles generates source files for model/controller/router
-// It will wipe the following directories and recreate them:./models, ./controllers, ./routers
-// Newly geneated files will be inside these folders.
+// inside ./models, ./controllers and ./routers. The *_gen.go files and the
+// generated region of the router are rewritten, hand-written code is kept.
 func writeSourceFiles(pkgPath string, tables []*Table, mode byte, paths *MvcPath) {
 	if (OModel & mode) == OModel {
 		asanaLogger.Log.Info("Creating model files...")
@@ -745,31 +761,12 @@ func writeSourceFiles(pkgPath string, tables []*Table, mode byte, paths *MvcPath
 
 // writeModelFiles generates model files
 func writeModelFiles(tables []*Table, mPath string) {
-	w := colors.NewColorWriter(os.Stdout)
-
 	for _, tb := range tables {
 		filename := getFileName(tb.Name)
-		fpath := path.Join(mPath, filename+".go")
-		var f *os.File
-		var err error
-		if utils.IsExist(fpath) {
-			asanaLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
-			if utils.AskForConfirmation() {
-				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
-				if err != nil {
-					asanaLogger.Log.Warnf("%s", err)
-					continue
-				}
-			} else {
-				asanaLogger.Log.Warnf("Skipped create file '%s'", fpath)
-				continue
-			}
-		} else {
-			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
-			if err != nil {
-				asanaLogger.Log.Warnf("%s", err)
-				continue
-			}
+		genPath := path.Join(mPath, filename+"_gen.go")
+		userPath := path.Join(mPath, filename+".go")
+		if !checkLegacyFile(userPath, genPath) {
+			continue
 		}
 		var template string
 		if tb.Pk == "" {
@@ -780,71 +777,54 @@ func writeModelFiles(tables []*Table, mPath string) {
 		fileStr := strings.Replace(template, "{{modelStruct}}", tb.String(), 1)
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
 		fileStr = strings.Replace(fileStr, "{{tableName}}", tb.Name, -1)
+		fileStr = strings.Replace(fileStr, "{{expands}}", expandsString(tb), 1)
 
-		// If table contains time field, import time.Time package
-		timePkg := ""
-		importTimePkg := ""
-		if tb.ImportTimePkg {
-			timePkg = "\"time\"\n"
-			importTimePkg = "import \"time\"\n"
-		}
-		fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
-		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
-		if _, err := f.WriteString(fileStr); err != nil {
-			asanaLogger.Log.Fatalf("Could not write model file to '%s': %s", fpath, err)
-		}
-		utils.CloseFile(f)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
-		utils.FormatSourceCode(fpath)
+		// import the packages of the column types, e.g. time
+		fileStr = strings.Replace(fileStr, "{{typePkgs}}", tb.importSpecs(), -1)
+		fileStr = strings.Replace(fileStr, "{{importTypePkgs}}", tb.importDecl(), -1)
+		writeGeneratedFile(genPath, fileStr)
+
+		userStr := strings.Replace(ModelUserTPL, "{{modelName}}", utils.CamelCase(tb.Name), -1)
+		userStr = strings.Replace(userStr, "{{fileName}}", filename, -1)
+		writeUserFile(userPath, userStr)
 	}
 }
 
 // writeControllerFiles generates controller files
 func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
-	w := colors.NewColorWriter(os.Stdout)
-
 	for _, tb := range tables {
 		if tb.Pk == "" {
 			continue
 		}
 		filename := getFileName(tb.Name)
-		fpath := path.Join(cPath, filename+".go")
-		var f *os.File
-		var err error
-		if utils.IsExist(fpath) {
-			asanaLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
-			if utils.AskForConfirmation() {
-				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
-				if err != nil {
-					asanaLogger.Log.Warnf("%s", err)
-					continue
-				}
-			} else {
-				asanaLogger.Log.Warnf("Skipped create file '%s'", fpath)
-				continue
-			}
-		} else {
-			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
-			if err != nil {
-				asanaLogger.Log.Warnf("%s", err)
-				continue
-			}
+		genPath := path.Join(cPath, filename+"_gen.go")
+		userPath := path.Join(cPath, filename+".go")
+		if !checkLegacyFile(userPath, genPath) {
+			continue
 		}
-		fileStr := strings.Replace(CtrlTPL, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
-		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
-		if _, err := f.WriteString(fileStr); err != nil {
-			asanaLogger.Log.Fatalf("Could not write controller file to '%s': %s", fpath, err)
+		var childMappings, childRoutes string
+		for _, child := range tb.Children {
+			childName := utils.CamelCase(child.Table.Name)
+			childMappings += fmt.Sprintf("\tc.Mapping(\"Get%s\", c.Get%s)\n", childName, childName)
+			childRoute := strings.Replace(ChildRouteTPL, "{{childName}}", childName, -1)
+			childRoute = strings.Replace(childRoute, "{{childTable}}", child.Table.Name, -1)
+			childRoutes += strings.Replace(childRoute, "{{fkColumn}}", child.Column, -1)
 		}
-		utils.CloseFile(f)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
-		utils.FormatSourceCode(fpath)
+		fileStr := strings.Replace(CtrlTPL, "{{childMappings}}", childMappings, 1)
+		fileStr = strings.Replace(fileStr, "{{childRoutes}}", childRoutes, 1)
+		fileStr = strings.Replace(fileStr, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
+		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
+		writeGeneratedFile(genPath, fileStr)
+
+		userStr := strings.Replace(CtrlUserTPL, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
+		userStr = strings.Replace(userStr, "{{fileName}}", filename, -1)
+		writeUserFile(userPath, userStr)
 	}
 }
 
-// writeRouterFile generates router file
+// writeRouterFile generates router file. Only the generated region of an
+// existing router file is replaced.
 func writeRouterFile(tables []*Table, rPath string, pkgPath string) {
-	w := colors.NewColorWriter(os.Stdout)
-
 	var nameSpaces []string
 	for _, tb := range tables {
 		if tb.Pk == "" {
@@ -859,33 +839,25 @@ func writeRouterFile(tables []*Table, rPath string, pkgPath string) {
 	fpath := filepath.Join(rPath, "router.go")
 	routerStr := strings.Replace(RouterTPL, "{{nameSpaces}}", strings.Join(nameSpaces, ""), 1)
 	routerStr = strings.Replace(routerStr, "{{pkgPath}}", pkgPath, 1)
-	var f *os.File
-	var err error
+	action := "create"
 	if utils.IsExist(fpath) {
-		asanaLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
-		if utils.AskForConfirmation() {
-			f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
-			if err != nil {
-				asanaLogger.Log.Warnf("%s", err)
-				return
-			}
-		} else {
-			asanaLogger.Log.Warnf("Skipped create file '%s'", fpath)
-			return
-		}
-	} else {
-		f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
+		src, err := ioutil.ReadFile(fpath)
 		if err != nil {
 			asanaLogger.Log.Warnf("%s", err)
 			return
 		}
+		if merged, ok := replaceGeneratedRegion(string(src), strings.Join(nameSpaces, "")); ok {
+			routerStr = merged
+		} else {
+			asanaLogger.Log.Warnf("'%s' already exists and has no generated region. Do you want to overwrite it? [Yes|No] ", fpath)
+			if !utils.AskForConfirmation() {
+				asanaLogger.Log.Warnf("Skipped create file '%s'", fpath)
+				return
+			}
+		}
+		action = "update"
 	}
-	if _, err := f.WriteString(routerStr); err != nil {
-		asanaLogger.Log.Fatalf("Could not write router file to '%s': %s", fpath, err)
-	}
-	utils.CloseFile(f)
-	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
-	utils.FormatSourceCode(fpath)
+	writeSourceFile(fpath, routerStr, action)
 }
 
 func isSQLTemporalType(t string) bool {
@@ -979,8 +951,20 @@ func getPackagePath(curpath string) (packpath string) {
 }
 
 const (
+	ModelUserTPL = `package models
+
+// Add your own {{modelName}} code here. Unlike {{fileName}}_gen.go, this
+// file is never overwritten by asanacli generate appcode.
+`
+
+	CtrlUserTPL = `package controllers
+
+// Add your own {{ctrlName}}Controller methods here. Unlike {{fileName}}_gen.go,
+// this file is never overwritten by asanacli generate appcode.
+`
+
 	StructModelTPL = `package models
-{{importTimePkg}}
+{{importTypePkgs}}
 {{modelStruct}}
 `
 
@@ -991,7 +975,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	{{timePkg}}
+	{{typePkgs}}
 	"github.com/goasana/asana/orm"
 )
 
@@ -1005,6 +989,12 @@ func init() {
 	orm.RegisterModel(new({{modelName}}))
 }
 
+// {{modelName}}Expands lists the related entities of {{modelName}} which
+// can be loaded by Expand{{modelName}}
+var {{modelName}}Expands = map[string]bool{
+{{expands}}
+}
+
 // Add{{modelName}} insert a new {{modelName}} into database and returns
 // last inserted Id on success.
 func Add{{modelName}}(m *{{modelName}}) (id int64, err error) {
@@ -1024,10 +1014,25 @@ func Get{{modelName}}ById(id int) (v *{{modelName}}, err error) {
 	return nil, err
 }
 
+// Expand{{modelName}} loads the related entities of v listed in expand.
+// Returns error if one of them is not a relation of {{modelName}}
+func Expand{{modelName}}(v *{{modelName}}, expand []string) (err error) {
+	o := orm.NewOrm()
+	for _, name := range expand {
+		if !{{modelName}}Expands[name] {
+			return fmt.Errorf("Error: '%s' cannot be expanded", name)
+		}
+		if _, err = o.LoadRelated(v, name); err != nil {
+			return
+		}
+	}
+	return
+}
+
 // GetAll{{modelName}} retrieves all {{modelName}} matches certain condition. Returns empty list if
 // no records exist
 func GetAll{{modelName}}(query map[string]string, fields []string, sortby []string, order []string,
-	offset int64, limit int64) (ml []interface{}, err error) {
+	offset int64, limit int64, expand []string) (ml []interface{}, err error) {
 	o := orm.NewOrm()
 	qs := o.QueryTable(new({{modelName}}))
 	// query k=v
@@ -1082,6 +1087,11 @@ func GetAll{{modelName}}(query map[string]string, fields []string, sortby []stri
 	var l []{{modelName}}
 	qs = qs.OrderBy(sortFields...)
 	if _, err = qs.Limit(limit, offset).All(&l, fields...); err == nil {
+		for i := range l {
+			if err = Expand{{modelName}}(&l[i], expand); err != nil {
+				return nil, err
+			}
+		}
 		if len(fields) == 0 {
 			for _, v := range l {
 				ml = append(ml, v)
@@ -1156,7 +1166,7 @@ func (c *{{ctrlName}}Controller) URLMapping() {
 	c.Mapping("GetAll", c.GetAll)
 	c.Mapping("Put", c.Put)
 	c.Mapping("Delete", c.Delete)
-}
+{{childMappings}}}
 
 // Post ...
 // @Title Post
@@ -1184,6 +1194,7 @@ func (c *{{ctrlName}}Controller) Post() {
 // @Title Get One
 // @Description get {{ctrlName}} by id
 // @Param	id		path 	string	true		"The key for staticblock"
+// @Param	expand	query	string	false	"Related entities to load. e.g. rel1,rel2 ..."
 // @Success 200 {object} models.{{ctrlName}}
 // @Failure 403 :id is empty
 // @router /:id [get]
@@ -1191,6 +1202,11 @@ func (c *{{ctrlName}}Controller) GetOne() {
 	idStr := c.Ctx.Input.Param(":id")
 	id, _ := strconv.Atoi(idStr)
 	v, err := models.Get{{ctrlName}}ById(id)
+	if err == nil {
+		if expand := c.GetString("expand"); expand != "" {
+			err = models.Expand{{ctrlName}}(v, strings.Split(expand, ","))
+		}
+	}
 	if err != nil {
 		c.Data["json"] = err.Error()
 	} else {
@@ -1208,6 +1224,7 @@ func (c *{{ctrlName}}Controller) GetOne() {
 // @Param	order	query	string	false	"Order corresponding to each sortby field, if single value, apply to all sortby fields. e.g. desc,asc ..."
 // @Param	limit	query	string	false	"Limit the size of result set. Must be an integer"
 // @Param	offset	query	string	false	"Start position of result set. Must be an integer"
+// @Param	expand	query	string	false	"Related entities to load. e.g. rel1,rel2 ..."
 // @Success 200 {object} models.{{ctrlName}}
 // @Failure 403
 // @router / [get]
@@ -1215,6 +1232,7 @@ func (c *{{ctrlName}}Controller) GetAll() {
 	var fields []string
 	var sortby []string
 	var order []string
+	var expand []string
 	var query = make(map[string]string)
 	var limit int64 = 10
 	var offset int64
@@ -1239,6 +1257,10 @@ func (c *{{ctrlName}}Controller) GetAll() {
 	if v := c.GetString("order"); v != "" {
 		order = strings.Split(v, ",")
 	}
+	// expand: rel1,rel2
+	if v := c.GetString("expand"); v != "" {
+		expand = strings.Split(v, ",")
+	}
 	// query: k:v,k:v
 	if v := c.GetString("query"); v != "" {
 		for _, cond := range strings.Split(v, ",") {
@@ -1253,7 +1275,7 @@ func (c *{{ctrlName}}Controller) GetAll() {
 		}
 	}
 
-	l, err := models.GetAll{{ctrlName}}(query, fields, sortby, order, offset, limit)
+	l, err := models.GetAll{{ctrlName}}(query, fields, sortby, order, offset, limit, expand)
 	if err != nil {
 		c.Data["json"] = err.Error()
 	} else {
@@ -1303,6 +1325,45 @@ func (c *{{ctrlName}}Controller) Delete() {
 	}
 	c.ServeJSON()
 }
+{{childRoutes}}`
+	ChildRouteTPL = `
+// Get{{childName}} ...
+// @Title Get {{childName}}
+// @Description get the {{childName}} of a {{ctrlName}}
+// @Param	id		path 	string	true		"The key of the {{ctrlName}}"
+// @Param	limit	query	string	false	"Limit the size of result set. Must be an integer"
+// @Param	offset	query	string	false	"Start position of result set. Must be an integer"
+// @Param	expand	query	string	false	"Related entities to load. e.g. rel1,rel2 ..."
+// @Success 200 {object} models.{{childName}}
+// @Failure 403 :id is empty
+// @router /:id/{{childTable}} [get]
+func (c *{{ctrlName}}Controller) Get{{childName}}() {
+	var expand []string
+	var limit int64 = 10
+	var offset int64
+
+	// limit: 10 (default is 10)
+	if v, err := c.GetInt64("limit"); err == nil {
+		limit = v
+	}
+	// offset: 0 (default is 0)
+	if v, err := c.GetInt64("offset"); err == nil {
+		offset = v
+	}
+	// expand: rel1,rel2
+	if v := c.GetString("expand"); v != "" {
+		expand = strings.Split(v, ",")
+	}
+
+	query := map[string]string{"{{fkColumn}}": c.Ctx.Input.Param(":id")}
+	l, err := models.GetAll{{childName}}(query, nil, nil, nil, offset, limit, expand)
+	if err != nil {
+		c.Data["json"] = err.Error()
+	} else {
+		c.Data["json"] = l
+	}
+	c.ServeJSON()
+}
 `
 	RouterTPL = `// @APIVersion 1.0.0
 // @Title asana Test API
@@ -1321,7 +1382,9 @@ import (
 
 func init() {
 	ns := asana.NewNamespace("/v1",
+		// asanacli:generated begin
 		{{nameSpaces}}
+		// asanacli:generated end
 	)
 	asana.AddNamespace(ns)
 }
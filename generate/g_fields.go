@@ -0,0 +1,334 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asanacli/utils"
+)
+
+// The -fields option is a comma separated list of field specifications:
+//
+//	name:type[:argument][:modifier...]
+//
+// e.g. email:string:255:unique,price:decimal:10,2:nullable,user:fk:users
+//
+// Supported types and their optional/required argument:
+//
+//	string[:size]  text  auto  pk  bool  datetime  date  time  timestamp
+//	int int8 int16 int32 int64 uint uint8 uint16 uint32 uint64
+//	float float32 float64  decimal[:digits,decimals]  json  jsonb
+//	enum:value|value|...  fk:table
+//
+// Supported modifiers: nullable (or null), unique, index, default=value
+//
+// A comma followed by a digit belongs to the current field, so decimal
+// precisions like 10,2 do not split the field.
+
+const (
+	defaultStringSize = "128"
+	defaultDigits     = "10"
+	defaultDecimals   = "2"
+)
+
+var (
+	sizeRegex      = regexp.MustCompile(`^[0-9]+$`)
+	precisionRegex = regexp.MustCompile(`^([0-9]+)(?:,([0-9]+))?$`)
+	fieldNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Field is a field definition parsed from the -fields option
+type Field struct {
+	Name     string
+	Type     string
+	Size     string
+	Digits   string
+	Decimals string
+	Enum     []string
+	RefTable string
+	Nullable bool
+	Unique   bool
+	Index    bool
+	Default  string
+}
+
+// FieldError reports an invalid token of a field definition
+type FieldError struct {
+	Field  string
+	Token  string
+	Column int
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid token '%s' at column %d of field '%s': %s", e.Token, e.Column, e.Field, e.Reason)
+}
+
+// ParseFields parses the -fields option into field definitions
+func ParseFields(fields string) ([]*Field, error) {
+	if strings.TrimSpace(fields) == "" {
+		return nil, errors.New("fields cannot be empty")
+	}
+
+	var fds []*Field
+	names := make(map[string]bool)
+	for _, spec := range splitFields(fields) {
+		fd, err := parseField(spec.text, spec.column)
+		if err != nil {
+			return nil, err
+		}
+		if names[strings.ToLower(fd.Name)] {
+			return nil, &FieldError{spec.text, fd.Name, spec.column, "duplicate field name"}
+		}
+		names[strings.ToLower(fd.Name)] = true
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+type fieldSpec struct {
+	text   string
+	column int
+}
+
+// splitFields splits the fields option on the commas which are not
+// followed by a digit.
+func splitFields(fields string) []fieldSpec {
+	var specs []fieldSpec
+	start := 0
+	for i := 0; i <= len(fields); i++ {
+		if i < len(fields) && (fields[i] != ',' || (i+1 < len(fields) && fields[i+1] >= '0' && fields[i+1] <= '9')) {
+			continue
+		}
+		specs = append(specs, fieldSpec{fields[start:i], start + 1})
+		start = i + 1
+	}
+	return specs
+}
+
+func parseField(spec string, column int) (*Field, error) {
+	tokens := strings.Split(spec, ":")
+	// columns of each token, relative to the whole fields option
+	cols := make([]int, len(tokens))
+	pos := column
+	for i, t := range tokens {
+		cols[i] = pos
+		pos += len(t) + 1
+	}
+	fail := func(i int, reason string) error {
+		return &FieldError{Field: spec, Token: tokens[i], Column: cols[i], Reason: reason}
+	}
+
+	fd := &Field{Name: strings.TrimSpace(tokens[0])}
+	if !fieldNameRegex.MatchString(fd.Name) {
+		return nil, fail(0, "field name must be a valid identifier")
+	}
+	if len(tokens) < 2 {
+		return nil, fail(0, "missing type, should be name:type")
+	}
+	fd.Type = strings.ToLower(strings.TrimSpace(tokens[1]))
+
+	i := 2
+	arg := func() (string, bool) {
+		if i < len(tokens) && !isFieldModifier(tokens[i]) {
+			i++
+			return strings.TrimSpace(tokens[i-1]), true
+		}
+		return "", false
+	}
+	switch fd.Type {
+	case "string":
+		fd.Size = defaultStringSize
+		if size, ok := arg(); ok {
+			if !sizeRegex.MatchString(size) {
+				return nil, fail(i-1, "string size must be a number")
+			}
+			fd.Size = size
+		}
+	case "decimal":
+		fd.Digits, fd.Decimals = defaultDigits, defaultDecimals
+		if prec, ok := arg(); ok {
+			m := precisionRegex.FindStringSubmatch(prec)
+			if m == nil {
+				return nil, fail(i-1, "decimal precision must be digits or digits,decimals")
+			}
+			fd.Digits = m[1]
+			if m[2] != "" {
+				fd.Decimals = m[2]
+			}
+		}
+	case "enum":
+		values, ok := arg()
+		if !ok || values == "" {
+			return nil, fail(1, "enum requires its values, e.g. enum:draft|published")
+		}
+		for _, v := range strings.Split(values, "|") {
+			if v = strings.TrimSpace(v); v == "" {
+				return nil, fail(i-1, "enum values cannot be empty")
+			}
+			fd.Enum = append(fd.Enum, v)
+		}
+		fd.Size = fmt.Sprint(maxLen(fd.Enum))
+	case "fk":
+		ref, ok := arg()
+		if !ok || !fieldNameRegex.MatchString(ref) {
+			return nil, fail(1, "fk requires the referenced table, e.g. fk:users")
+		}
+		fd.RefTable = ref
+	case "text", "auto", "pk", "bool", "datetime", "date", "time", "timestamp",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float", "float32", "float64", "json", "jsonb":
+	default:
+		return nil, fail(1, "unknown type")
+	}
+
+	for ; i < len(tokens); i++ {
+		mod := strings.TrimSpace(tokens[i])
+		switch {
+		case mod == "nullable" || mod == "null":
+			fd.Nullable = true
+		case mod == "unique":
+			fd.Unique = true
+		case mod == "index":
+			fd.Index = true
+		case strings.HasPrefix(mod, "default="):
+			fd.Default = mod[len("default="):]
+			if fd.Default == "" {
+				return nil, fail(i, "default value cannot be empty")
+			}
+			if err := checkDefault(fd); err != nil {
+				return nil, fail(i, err.Error())
+			}
+		default:
+			return nil, fail(i, "unknown modifier, expected nullable, unique, index or default=value")
+		}
+	}
+	if (fd.Type == "auto" || fd.Type == "pk") && fd.Nullable {
+		return nil, fail(1, "a primary key cannot be nullable")
+	}
+	return fd, nil
+}
+
+// checkDefault verifies that the default value matches the field type,
+// since numeric defaults are written unquoted in the generated SQL.
+func checkDefault(fd *Field) error {
+	var err error
+	switch fd.Type {
+	case "int", "int8", "int16", "int32", "int64", "auto", "pk", "fk":
+		_, err = strconv.ParseInt(fd.Default, 10, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		_, err = strconv.ParseUint(fd.Default, 10, 64)
+	case "float", "float32", "float64", "decimal":
+		_, err = strconv.ParseFloat(fd.Default, 64)
+	case "bool":
+		_, err = strconv.ParseBool(fd.Default)
+	}
+	if err != nil {
+		return fmt.Errorf("default value is not a valid %s", fd.Type)
+	}
+	return nil
+}
+
+func isFieldModifier(token string) bool {
+	token = strings.TrimSpace(token)
+	return token == "nullable" || token == "null" || token == "unique" ||
+		token == "index" || strings.HasPrefix(token, "default=")
+}
+
+func maxLen(values []string) int {
+	l := 0
+	for _, v := range values {
+		if len(v) > l {
+			l = len(v)
+		}
+	}
+	return l
+}
+
+// IsPk reports whether the field is the primary key of its table
+func (fd *Field) IsPk() bool {
+	return fd.Type == "auto" || fd.Type == "pk"
+}
+
+// IsTime reports whether the field maps to time.Time
+func (fd *Field) IsTime() bool {
+	switch fd.Type {
+	case "datetime", "date", "time", "timestamp":
+		return true
+	}
+	return false
+}
+
+// ColumnName returns the database column of the field
+func (fd *Field) ColumnName() string {
+	if fd.Type == "fk" {
+		return utils.SnakeString(fd.Name) + "_id"
+	}
+	return utils.SnakeString(fd.Name)
+}
+
+// GoType returns the Go type of the field
+func (fd *Field) GoType() string {
+	switch fd.Type {
+	case "string", "text", "enum", "json", "jsonb":
+		return "string"
+	case "auto", "pk":
+		return "int64"
+	case "datetime", "date", "time", "timestamp":
+		return "time.Time"
+	case "float", "decimal":
+		return "float64"
+	case "fk":
+		return "*" + utils.CamelCase(fd.RefTable)
+	}
+	return fd.Type
+}
+
+// OrmTag returns the ORM tag of the field
+func (fd *Field) OrmTag() *OrmTag {
+	tag := &OrmTag{
+		Null:    fd.Nullable,
+		Unique:  fd.Unique,
+		Index:   fd.Index,
+		Default: fd.Default,
+	}
+	switch fd.Type {
+	case "string", "enum":
+		tag.Size = fd.Size
+	case "text":
+		tag.Type = "longtext"
+	case "auto":
+		tag.Auto = true
+	case "pk":
+		tag.Pk = true
+	case "datetime", "date", "time":
+		tag.Type = fd.Type
+	case "timestamp":
+		tag.Type = "datetime"
+	case "decimal":
+		tag.Digits, tag.Decimals = fd.Digits, fd.Decimals
+	case "json", "jsonb":
+		tag.Type = fd.Type
+	case "fk":
+		tag.RelFk = true
+	}
+	return tag
+}
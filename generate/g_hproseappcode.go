@@ -379,15 +379,9 @@ func writeHproseModelFiles(tables []*Table, mPath string, selectedTables map[str
 		}
 		fileStr := strings.Replace(template, "{{modelStruct}}", tb.String(), 1)
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
-		// if table contains time field, import time.Time package
-		timePkg := ""
-		importTimePkg := ""
-		if tb.ImportTimePkg {
-			timePkg = "\"time\"\n"
-			importTimePkg = "import \"time\"\n"
-		}
-		fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
-		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
+		// import the packages of the column types, e.g. time
+		fileStr = strings.Replace(fileStr, "{{typePkgs}}", tb.importSpecs(), -1)
+		fileStr = strings.Replace(fileStr, "{{importTypePkgs}}", tb.importDecl(), -1)
 		if _, err := f.WriteString(fileStr); err != nil {
 			asanaLogger.Log.Fatalf("Could not write model file to '%s'", fpath)
 		}
@@ -408,7 +402,7 @@ const (
 
 `
 	HproseStructModelTPL = `package models
-{{importTimePkg}}
+{{importTypePkgs}}
 {{modelStruct}}
 `
 
@@ -419,7 +413,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	{{timePkg}}
+	{{typePkgs}}
 	"github.com/goasana/asana/orm"
 )
 
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,7 +41,7 @@ type DBDriver interface {
 type mysqlDriver struct{}
 
 func (m mysqlDriver) GenerateCreateUp(tableName string) string {
-	upsql := `m.SQL("CREATE TABLE ` + tableName + "(" + m.generateSQLFromFields(Fields.String()) + `)");`
+	upsql := `m.SQL(` + strconv.Quote("CREATE TABLE "+tableName+"("+m.generateSQLFromFields(tableName, Fields.String())+")") + `);`
 	return upsql
 }
 
@@ -49,67 +50,90 @@ func (m mysqlDriver) GenerateCreateDown(tableName string) string {
 	return downsql
 }
 
-func (m mysqlDriver) generateSQLFromFields(fields string) string {
-	sql, tags := "", ""
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			asanaLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
+func (m mysqlDriver) generateSQLFromFields(tableName, fields string) string {
+	fds, err := ParseFields(fields)
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not generate the migration SQL: %s", err)
+	}
+	var cols, keys []string
+	if !hasPkField(fds) {
+		cols = append(cols, "`id` int(11) NOT NULL AUTO_INCREMENT")
+		keys = append(keys, "PRIMARY KEY (`id`)")
+	}
+	for _, fd := range fds {
+		name := fd.ColumnName()
+		col := "`" + name + "` " + m.getSQLType(fd) + sqlNullable(fd)
+		if fd.Default != "" {
+			col += " DEFAULT " + sqlDefault(fd)
+		}
+		if fd.Type == "auto" {
+			col += " AUTO_INCREMENT"
+		}
+		cols = append(cols, col)
+
+		if fd.IsPk() {
+			keys = append(keys, fmt.Sprintf("PRIMARY KEY (`%s`)", name))
 		}
-		typ, tag := m.getSQLType(kv[1])
-		if typ == "" {
-			asanaLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
+		if fd.Unique {
+			keys = append(keys, fmt.Sprintf("UNIQUE KEY `uk_%s_%s` (`%s`)", tableName, name, name))
 		}
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			sql += "`id` int(11) NOT NULL AUTO_INCREMENT,"
-			tags = tags + "PRIMARY KEY (`id`),"
+		if fd.Index {
+			keys = append(keys, fmt.Sprintf("KEY `idx_%s_%s` (`%s`)", tableName, name, name))
 		}
-		sql += "`" + utils.SnakeString(kv[0]) + "` " + typ + ","
-		if tag != "" {
-			tags = tags + fmt.Sprintf(tag, "`"+utils.SnakeString(kv[0])+"`") + ","
+		if fd.Type == "fk" {
+			keys = append(keys, fmt.Sprintf("CONSTRAINT `fk_%s_%s` FOREIGN KEY (`%s`) REFERENCES `%s` (`id`)", tableName, name, name, fd.RefTable))
 		}
 	}
-	sql = strings.TrimRight(sql+tags, ",")
-	return sql
+	return strings.Join(append(cols, keys...), ",")
 }
 
-func (m mysqlDriver) getSQLType(ktype string) (tp, tag string) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
+func (m mysqlDriver) getSQLType(fd *Field) string {
+	switch fd.Type {
 	case "string":
-		if len(kv) == 2 {
-			return "varchar(" + kv[1] + ") NOT NULL", ""
-		}
-		return "varchar(128) NOT NULL", ""
+		return "varchar(" + fd.Size + ")"
 	case "text":
-		return "longtext  NOT NULL", ""
-	case "auto":
-		return "int(11) NOT NULL AUTO_INCREMENT", ""
-	case "pk":
-		return "int(11) NOT NULL", "PRIMARY KEY (%s)"
-	case "datetime":
-		return "datetime NOT NULL", ""
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		return "int(11) DEFAULT NULL", ""
+		return "longtext"
+	case "auto", "pk", "fk", "int", "int32":
+		return "int(11)"
+	case "int8":
+		return "tinyint"
+	case "int16":
+		return "smallint"
+	case "int64":
+		return "bigint"
+	case "uint", "uint32":
+		return "int(11) unsigned"
+	case "uint8":
+		return "tinyint unsigned"
+	case "uint16":
+		return "smallint unsigned"
+	case "uint64":
+		return "bigint unsigned"
 	case "bool":
-		return "tinyint(1) NOT NULL", ""
-	case "float32", "float64":
-		return "float NOT NULL", ""
-	case "float":
-		return "float NOT NULL", ""
+		return "tinyint(1)"
+	case "float32":
+		return "float"
+	case "float", "float64":
+		return "double"
+	case "decimal":
+		return "decimal(" + fd.Digits + "," + fd.Decimals + ")"
+	case "json", "jsonb":
+		return "json"
+	case "enum":
+		return "enum(" + strings.Join(sqlQuoteAll(fd.Enum), ",") + ")"
 	}
-	return "", ""
+	// datetime, date, time and timestamp
+	return fd.Type
 }
 
 type postgresqlDriver struct{}
 
 func (m postgresqlDriver) GenerateCreateUp(tableName string) string {
-	upsql := `m.SQL("CREATE TABLE ` + tableName + "(" + m.generateSQLFromFields(Fields.String()) + `)");`
+	columns, indexes := m.generateSQLFromFields(tableName, Fields.String())
+	upsql := `m.SQL(` + strconv.Quote("CREATE TABLE "+tableName+"("+columns+")") + `);`
+	for _, index := range indexes {
+		upsql += "\n" + `m.SQL(` + strconv.Quote(index) + `);`
+	}
 	return upsql
 }
 
@@ -118,60 +142,111 @@ func (m postgresqlDriver) GenerateCreateDown(tableName string) string {
 	return downsql
 }
 
-func (m postgresqlDriver) generateSQLFromFields(fields string) string {
-	sql, tags := "", ""
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			asanaLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
+// generateSQLFromFields returns the column definitions of the table and
+// the statements creating its indexes.
+func (m postgresqlDriver) generateSQLFromFields(tableName, fields string) (string, []string) {
+	fds, err := ParseFields(fields)
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not generate the migration SQL: %s", err)
+	}
+	var cols, indexes []string
+	if !hasPkField(fds) {
+		cols = append(cols, "id serial primary key")
+	}
+	for _, fd := range fds {
+		name := fd.ColumnName()
+		col := name + " " + m.getSQLType(fd)
+		if fd.Type != "auto" {
+			col += sqlNullable(fd)
 		}
-		typ, tag := m.getSQLType(kv[1])
-		if typ == "" {
-			asanaLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
+		if fd.Default != "" {
+			col += " DEFAULT " + sqlDefault(fd)
 		}
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			sql += "id serial primary key,"
+		if fd.IsPk() {
+			col += " primary key"
 		}
-		sql += utils.SnakeString(kv[0]) + " " + typ + ","
-		if tag != "" {
-			tags = tags + fmt.Sprintf(tag, utils.SnakeString(kv[0])) + ","
+		if fd.Unique {
+			col += " UNIQUE"
+		}
+		switch fd.Type {
+		case "fk":
+			col += " REFERENCES " + fd.RefTable + "(id)"
+		case "enum":
+			col += " CHECK (" + name + " IN (" + strings.Join(sqlQuoteAll(fd.Enum), ",") + "))"
+		}
+		cols = append(cols, col)
+
+		if fd.Index {
+			indexes = append(indexes, fmt.Sprintf("CREATE INDEX idx_%s_%s ON %s (%s)", tableName, name, tableName, name))
 		}
 	}
-	if tags != "" {
-		sql = strings.TrimRight(sql+" "+tags, ",")
-	} else {
-		sql = strings.TrimRight(sql, ",")
-	}
-	return sql
+	return strings.Join(cols, ","), indexes
 }
 
-func (m postgresqlDriver) getSQLType(ktype string) (tp, tag string) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
-	case "string":
-		if len(kv) == 2 {
-			return "char(" + kv[1] + ") NOT NULL", ""
-		}
-		return "TEXT NOT NULL", ""
+func (m postgresqlDriver) getSQLType(fd *Field) string {
+	switch fd.Type {
+	case "string", "enum":
+		return "varchar(" + fd.Size + ")"
 	case "text":
-		return "TEXT NOT NULL", ""
-	case "auto", "pk":
-		return "serial primary key", ""
-	case "datetime":
-		return "TIMESTAMP WITHOUT TIME ZONE NOT NULL", ""
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		return "integer DEFAULT NULL", ""
+		return "TEXT"
+	case "auto":
+		return "serial"
+	case "pk", "fk", "int", "int32", "uint", "uint16":
+		return "integer"
+	case "int8", "int16", "uint8":
+		return "smallint"
+	case "int64", "uint32", "uint64":
+		return "bigint"
 	case "bool":
-		return "boolean NOT NULL", ""
-	case "float32", "float64", "float":
-		return "numeric NOT NULL", ""
+		return "boolean"
+	case "float32":
+		return "real"
+	case "float", "float64":
+		return "double precision"
+	case "decimal":
+		return "numeric(" + fd.Digits + "," + fd.Decimals + ")"
+	case "datetime", "timestamp":
+		return "TIMESTAMP WITHOUT TIME ZONE"
+	case "date":
+		return "DATE"
+	case "time":
+		return "TIME"
+	}
+	// json and jsonb
+	return fd.Type
+}
+
+func sqlNullable(fd *Field) string {
+	if fd.Nullable {
+		return " NULL"
+	}
+	return " NOT NULL"
+}
+
+// sqlDefault returns the SQL literal of the default value of a field
+func sqlDefault(fd *Field) string {
+	switch fd.Type {
+	case "string", "text", "enum", "json", "jsonb":
+		return sqlQuote(fd.Default)
+	case "datetime", "date", "time", "timestamp":
+		if v := strings.ToUpper(fd.Default); v == "CURRENT_TIMESTAMP" || v == "NOW()" {
+			return v
+		}
+		return sqlQuote(fd.Default)
+	}
+	return fd.Default
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func sqlQuoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = sqlQuote(v)
 	}
-	return "", ""
+	return quoted
 }
 
 func NewDBDriver() DBDriver {
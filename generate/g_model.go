@@ -15,7 +15,6 @@
 package generate
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -74,67 +73,40 @@ func GenerateModel(mname, fields, currpath string) {
 }
 
 func getStruct(structname, fields string) (string, bool, error) {
-	if fields == "" {
-		return "", false, errors.New("fields cannot be empty")
+	fds, err := ParseFields(fields)
+	if err != nil {
+		return "", false, err
 	}
 
 	hastime := false
 	structStr := "type " + structname + " struct{\n"
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			return "", false, errors.New("the fields format is wrong. Should be key:type,key:type " + v)
-		}
-
-		typ, tag, hastimeinner := getType(kv[1])
-		if typ == "" {
-			return "", false, errors.New("the fields format is wrong. Should be key:type,key:type " + v)
-		}
-
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			structStr = structStr + "Id     int64     `orm:\"auto\"`\n"
-		}
-
-		if hastimeinner {
+	if !hasPkField(fds) {
+		structStr = structStr + "Id     int64     `orm:\"auto\"`\n"
+	}
+	for _, fd := range fds {
+		if fd.IsTime() {
 			hastime = true
 		}
-		structStr = structStr + utils.CamelString(kv[0]) + "       " + typ + "     " + tag + "\n"
+		col := &Column{
+			Name: utils.CamelString(fd.Name),
+			Type: fd.GoType(),
+			Tag:  fd.OrmTag(),
+		}
+		structStr = structStr + col.String() + "\n"
 	}
 	structStr += "}\n"
 	return structStr, hastime, nil
 }
 
-// fields support type
-// http://asana.me/docs/mvc/model/models.md#mysql
-func getType(ktype string) (kt, tag string, hasTime bool) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
-	case "string":
-		if len(kv) == 2 {
-			return "string", "`orm:\"size(" + kv[1] + ")\"`", false
+// hasPkField reports whether the fields already define the primary key,
+// otherwise an auto increment Id is added.
+func hasPkField(fds []*Field) bool {
+	for _, fd := range fds {
+		if fd.IsPk() || strings.ToLower(fd.Name) == "id" {
+			return true
 		}
-		return "string", "`orm:\"size(128)\"`", false
-	case "text":
-		return "string", "`orm:\"type(longtext)\"`", false
-	case "auto":
-		return "int64", "`orm:\"auto\"`", false
-	case "pk":
-		return "int64", "`orm:\"pk\"`", false
-	case "datetime":
-		return "time.Time", "`orm:\"type(datetime)\"`", true
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		fallthrough
-	case "bool":
-		fallthrough
-	case "float32", "float64":
-		return kv[0], "", false
-	case "float":
-		return "float64", "", false
 	}
-	return "", "", false
+	return false
 }
 
 var modelTpl = `package {{packageName}}
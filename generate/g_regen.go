@@ -0,0 +1,95 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/logger/colors"
+	"github.com/goasana/asanacli/utils"
+)
+
+// Appcode is split so that it can be regenerated after each migration:
+// the *_gen.go files are rewritten every time, the matching user files
+// are only created once, and the router only has its generated region
+// replaced.
+const (
+	generatedHeader = "// Code generated by asanacli. DO NOT EDIT.\n\n"
+	generatedBegin  = "// asanacli:generated begin"
+	generatedEnd    = "// asanacli:generated end"
+)
+
+// writeGeneratedFile writes a file which is regenerated on each run
+func writeGeneratedFile(fpath, content string) {
+	action := "create"
+	if utils.IsExist(fpath) {
+		action = "update"
+	}
+	writeSourceFile(fpath, generatedHeader+content, action)
+}
+
+// writeUserFile creates a file meant to be edited by the user, unless
+// it already exists
+func writeUserFile(fpath, content string) {
+	if utils.IsExist(fpath) {
+		return
+	}
+	writeSourceFile(fpath, content, "create")
+}
+
+func writeSourceFile(fpath, content, action string) {
+	w := colors.NewColorWriter(os.Stdout)
+	if err := ioutil.WriteFile(fpath, []byte(content), 0666); err != nil {
+		asanaLogger.Log.Fatalf("Could not write file '%s': %s", fpath, err)
+	}
+	fmt.Fprintf(w, "\t%s%s%s%s\t %s%s\n", "\x1b[32m", "\x1b[1m", action, "\x1b[21m", fpath, "\x1b[0m")
+	utils.FormatSourceCode(fpath)
+}
+
+// checkLegacyFile reports whether the files of a table can be generated.
+// A user file without its generated part comes from a previous version
+// which generated everything in a single file: it has to be replaced,
+// otherwise both files would declare the same code.
+func checkLegacyFile(userPath, genPath string) bool {
+	if utils.IsExist(genPath) || !utils.IsExist(userPath) {
+		return true
+	}
+	asanaLogger.Log.Warnf("'%s' was generated without a separate '%s'. Do you want to overwrite it? [Yes|No] ", userPath, genPath)
+	if !utils.AskForConfirmation() {
+		asanaLogger.Log.Warnf("Skipped create file '%s'", genPath)
+		return false
+	}
+	if err := os.Remove(userPath); err != nil {
+		asanaLogger.Log.Warnf("%s", err)
+		return false
+	}
+	return true
+}
+
+// replaceGeneratedRegion replaces the content between the generated
+// region markers of src, keeping the code written around them.
+func replaceGeneratedRegion(src, content string) (string, bool) {
+	begin := strings.Index(src, generatedBegin)
+	end := strings.Index(src, generatedEnd)
+	if begin == -1 || end < begin {
+		return "", false
+	}
+	begin += len(generatedBegin)
+	return src[:begin] + "\n" + content + "\n" + src[end:], true
+}
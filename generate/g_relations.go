@@ -0,0 +1,137 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/utils"
+)
+
+// ChildRoute is a nested route listing the rows of a child table
+// which reference a row of its parent, e.g. /users/:id/orders
+type ChildRoute struct {
+	Table  *Table
+	Column string // foreign key column of the child table
+}
+
+// resolveRelations adds the reverse side of the foreign keys to the
+// referenced tables, and turns join tables into many-to-many relations.
+// Only tables which are generated and have a primary key are considered,
+// because the ORM cannot register the others.
+func resolveRelations(tables []*Table, pkgPath string) {
+	byName := make(map[string]*Table)
+	for _, tb := range tables {
+		if tb.Pk != "" {
+			byName[tb.Name] = tb
+		}
+	}
+	for _, tb := range byName {
+		for _, col := range tb.Columns {
+			if col.Tag.RelFk {
+				tb.Expands = append(tb.Expands, col.Name)
+			}
+		}
+	}
+
+	for _, tb := range tables {
+		if tb.Pk == "" {
+			continue
+		}
+		if a, b, ok := joinedTables(tb, byName); ok {
+			through := pkgPath + "/models." + utils.CamelCase(tb.Name)
+			addRelation(a, b, &OrmTag{RelM2M: true, RelThrough: through})
+			addRelation(b, a, &OrmTag{ReverseMany: true})
+			continue
+		}
+
+		refs := make(map[string]int)
+		for _, fk := range tb.Fk {
+			refs[fk.RefTable]++
+		}
+		for _, col := range tb.Columns {
+			fk, isFk := tb.Fk[col.Tag.Column]
+			if !isFk || !col.Tag.RelFk {
+				continue
+			}
+			parent, ok := byName[fk.RefTable]
+			if !ok {
+				continue
+			}
+			if refs[fk.RefTable] > 1 {
+				asanaLogger.Log.Warnf("Skipping reverse relation from '%s' to '%s': '%s' has more than one foreign key to '%s'",
+					parent.Name, tb.Name, tb.Name, parent.Name)
+				continue
+			}
+			if addRelation(parent, tb, &OrmTag{ReverseMany: true}) {
+				parent.Children = append(parent.Children, &ChildRoute{Table: tb, Column: fk.Name})
+			}
+		}
+	}
+}
+
+// joinedTables returns the tables joined by tb if tb only consists of
+// its primary key and two foreign keys to distinct tables.
+func joinedTables(tb *Table, byName map[string]*Table) (a *Table, b *Table, ok bool) {
+	if len(tb.Fk) != 2 || len(tb.Columns) != 3 {
+		return nil, nil, false
+	}
+	var joined []*Table
+	for _, col := range tb.Columns {
+		if col.Tag.Column == tb.Pk {
+			continue
+		}
+		fk, isFk := tb.Fk[col.Tag.Column]
+		if !isFk || !col.Tag.RelFk {
+			return nil, nil, false
+		}
+		ref, found := byName[fk.RefTable]
+		if !found {
+			return nil, nil, false
+		}
+		joined = append(joined, ref)
+	}
+	if len(joined) != 2 || joined[0] == joined[1] {
+		return nil, nil, false
+	}
+	return joined[0], joined[1], true
+}
+
+// addRelation adds to tb a field holding the related rows of ref.
+// Returns false if tb already has a field with the same name.
+func addRelation(tb, ref *Table, tag *OrmTag) bool {
+	name := utils.CamelCase(ref.Name)
+	for _, col := range tb.Columns {
+		if col.Name == name {
+			asanaLogger.Log.Warnf("Skipping relation '%s' of '%s': a field with the same name already exists", name, tb.Name)
+			return false
+		}
+	}
+	tb.Columns = append(tb.Columns, &Column{Name: name, Type: "[]*" + name, Tag: tag})
+	tb.Expands = append(tb.Expands, name)
+	return true
+}
+
+// expandsString returns the entries of the map listing the fields of a
+// model which can be expanded
+func expandsString(tb *Table) string {
+	var entries []string
+	for _, name := range tb.Expands {
+		entries = append(entries, fmt.Sprintf("%q: true,", name))
+	}
+	return strings.Join(entries, "\n")
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/goasana/asanacli/config"
+)
+
+// typeImports maps the package of the well-known Go types to its import
+// path, so that the overrides of the Asanafile may omit it, e.g. uuid.UUID
+var typeImports = map[string]string{
+	"time": "time",
+	"json": "encoding/json",
+	"sql":  "database/sql",
+	"uuid": "github.com/google/uuid",
+	"pq":   "github.com/lib/pq",
+}
+
+// sqlNullTypes maps a Go type to the database/sql type of its nullable
+// columns, among the ones the ORM supports
+var sqlNullTypes = map[string]string{
+	"string":    "sql.NullString",
+	"bool":      "sql.NullBool",
+	"int":       "sql.NullInt64",
+	"int8":      "sql.NullInt64",
+	"int16":     "sql.NullInt64",
+	"int32":     "sql.NullInt64",
+	"int64":     "sql.NullInt64",
+	"float32":   "sql.NullFloat64",
+	"float64":   "sql.NullFloat64",
+	"time.Time": "*time.Time",
+}
+
+// columnGoType returns the Go type of a column. The overrides of the
+// Asanafile have priority, first for the table.column then for the SQL
+// types, in order. Otherwise nullable columns are represented as configured.
+func columnGoType(table *Table, colName, goType string, nullable bool, sqlTypes ...string) string {
+	for _, key := range append([]string{table.Name + "." + colName}, sqlTypes...) {
+		if t, ok := config.Conf.Database.Types[key]; ok && t.Type != "" {
+			if t.Import != "" {
+				table.Imports[typePackage(t.Type)] = t.Import
+			}
+			return t.Type
+		}
+	}
+	if !nullable {
+		return goType
+	}
+	switch config.Conf.Database.Nullable {
+	case "pointer":
+		if !strings.HasPrefix(goType, "[]") {
+			return "*" + goType
+		}
+	case "sql":
+		if t, ok := sqlNullTypes[goType]; ok {
+			return t
+		}
+	}
+	return goType
+}
+
+// typePackage returns the package name qualifying a Go type, e.g. decimal
+// for *decimal.Decimal
+func typePackage(goType string) string {
+	goType = strings.TrimLeft(goType, "*[]")
+	if i := strings.Index(goType, "."); i > 0 {
+		return goType[:i]
+	}
+	return ""
+}
+
+// importPaths returns the sorted import paths of the column types of tb
+func (tb *Table) importPaths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, col := range tb.Columns {
+		pkg := typePackage(col.Type)
+		if pkg == "" {
+			continue
+		}
+		p, ok := tb.Imports[pkg]
+		if !ok {
+			p, ok = typeImports[pkg]
+		}
+		if ok && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// importSpecs returns the import specs of the column types of tb, to be
+// added to an existing import declaration
+func (tb *Table) importSpecs() string {
+	var specs string
+	for _, p := range tb.importPaths() {
+		specs += "\"" + p + "\"\n"
+	}
+	return specs
+}
+
+// importDecl returns the import declaration of the column types of tb
+func (tb *Table) importDecl() string {
+	if len(tb.importPaths()) == 0 {
+		return ""
+	}
+	return "import (\n" + tb.importSpecs() + ")\n"
+}
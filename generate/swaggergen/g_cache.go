@@ -0,0 +1,235 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goasana/asana/swagger"
+	asanaLogger "github.com/goasana/asanacli/logger"
+)
+
+// When the docs are generated several times by the same process, e.g. by
+// run with -gendoc, the parse cache keeps the parsed files keyed by path
+// with the hash of their content, and the model schemas with the hashes of
+// the packages they were resolved from. Only the files which changed are
+// parsed again, and only the models of the packages which changed are
+// analysed again. The annotations and the routes are analysed on each
+// generation, from the cached files.
+type parseCache struct {
+	fset   *token.FileSet
+	files  map[string]*cachedFile
+	models map[string]*cachedModel
+	hashes map[string]string //package name:hash of its files, for the current generation
+}
+
+type cachedFile struct {
+	hash string
+	file *ast.File
+}
+
+type cachedModel struct {
+	deps      map[string]string //package name:hash of its files
+	schema    swagger.Schema
+	realTypes []string
+	extras    map[string]map[string]interface{}
+}
+
+var cache *parseCache
+var modelDeps map[string]bool //packages the model being parsed is resolved from
+
+// EnableParseCache keeps the parsed files and models between the docs
+// generations of the process
+func EnableParseCache() {
+	cache = &parseCache{
+		fset:   token.NewFileSet(),
+		files:  make(map[string]*cachedFile),
+		models: make(map[string]*cachedModel),
+	}
+}
+
+// RegenerateDocs generates the docs for a given path in the current process,
+// reusing what did not change since the previous generation. Unlike
+// GenerateDocs, the annotations which cannot be documented are reported, and
+// they and the other failures are returned as an error instead of exiting.
+func RegenerateDocs(curpath string) error {
+	if cache == nil {
+		EnableParseCache()
+	}
+	resetState()
+	cache.hashes = make(map[string]string)
+	ParsePackagesFromDir(curpath)
+
+	if l := lintAnnotations(curpath); l.fatals > 0 {
+		for _, issue := range l.issues {
+			asanaLogger.Log.Errorf("%s", issue)
+		}
+		return fmt.Errorf("%d annotation(s) cannot be documented", l.fatals)
+	}
+	return generateDocs(curpath)
+}
+
+// newFileSet returns the file set of the parse cache, as the positions of
+// the cached files refer to it, or a new one
+func newFileSet() *token.FileSet {
+	if cache != nil {
+		return cache.fset
+	}
+	return token.NewFileSet()
+}
+
+// parseDir parses a directory as parser.ParseDir, with the comments. With
+// the parse cache the files which did not change are not parsed again, and
+// fset has to be the one of newFileSet.
+func parseDir(fset *token.FileSet, dir string, filter func(os.FileInfo) bool) (map[string]*ast.Package, error) {
+	if cache == nil {
+		return parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(map[string]*ast.Package)
+	var first error
+	for _, info := range infos {
+		if filter != nil && !filter(info) {
+			continue
+		}
+		fpath := filepath.Join(dir, info.Name())
+		f, err := cache.parseFile(fpath)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &ast.Package{Name: f.Name.Name, Files: make(map[string]*ast.File)}
+			pkgs[f.Name.Name] = pkg
+		}
+		pkg.Files[fpath] = f
+	}
+	return pkgs, first
+}
+
+func (c *parseCache) parseFile(fpath string) (*ast.File, error) {
+	src, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(src)
+	hash := hex.EncodeToString(sum[:])
+	if cf, ok := c.files[fpath]; ok && cf.hash == hash {
+		return cf.file, nil
+	}
+	f, err := parser.ParseFile(c.fset, fpath, src, parser.ParseComments)
+	if err != nil {
+		delete(c.files, fpath)
+		return nil, err
+	}
+	c.files[fpath] = &cachedFile{hash: hash, file: f}
+	return f, nil
+}
+
+// packageHash returns the hash of the files of the parsed packages with a
+// given name
+func (c *parseCache) packageHash(name string) string {
+	if hash, ok := c.hashes[name]; ok {
+		return hash
+	}
+	var hashes []string
+	for _, pkg := range astPkgs {
+		if pkg.Name != name {
+			continue
+		}
+		for fpath := range pkg.Files {
+			if cf, ok := c.files[fpath]; ok {
+				hashes = append(hashes, fpath+":"+cf.hash)
+			}
+		}
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+	c.hashes[name] = hex.EncodeToString(sum[:])
+	return c.hashes[name]
+}
+
+// trackModelDeps starts recording the packages a model is resolved from
+func trackModelDeps() {
+	if cache != nil {
+		modelDeps = make(map[string]bool)
+	}
+}
+
+func dependOn(packageName string) {
+	if modelDeps != nil {
+		modelDeps[packageName] = true
+	}
+}
+
+// cachedSchema returns the schema of a model analysed by a previous
+// generation, unless a package it is resolved from changed
+func cachedSchema(defName string) (m swagger.Schema, realTypes []string, ok bool) {
+	if cache == nil {
+		return
+	}
+	cm, ok := cache.models[defName]
+	if !ok {
+		return
+	}
+	for pkg, hash := range cm.deps {
+		if cache.packageHash(pkg) != hash {
+			delete(cache.models, defName)
+			return m, nil, false
+		}
+	}
+	if len(rootapi.Definitions) == 0 {
+		rootapi.Definitions = make(map[string]swagger.Schema)
+	}
+	rootapi.Definitions[defName] = cm.schema
+	if cm.extras != nil {
+		propertyExtras[defName] = cm.extras
+	}
+	return cm.schema, cm.realTypes, true
+}
+
+// cacheSchema keeps the schema of a model for the next generations
+func cacheSchema(defName string, m swagger.Schema, realTypes []string) {
+	if cache == nil {
+		return
+	}
+	deps := make(map[string]string)
+	for pkg := range modelDeps {
+		deps[pkg] = cache.packageHash(pkg)
+	}
+	modelDeps = nil
+	cache.models[defName] = &cachedModel{
+		deps:      deps,
+		schema:    m,
+		realTypes: realTypes,
+		extras:    propertyExtras[defName],
+	}
+}
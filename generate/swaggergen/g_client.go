@@ -0,0 +1,945 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/goasana/asana/swagger"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/logger/colors"
+	bu "github.com/goasana/asanacli/utils"
+)
+
+// clientModel is a type of the client, generated from a definition
+type clientModel struct {
+	Name        string
+	Description string
+	Type        string // underlying type of the models which are not objects
+	Enum        []clientEnum
+	Fields      []clientField
+}
+
+type clientEnum struct {
+	Name  string
+	Value string // literal of the value
+}
+
+type clientField struct {
+	Name        string
+	JSONName    string
+	Type        string
+	Description string
+	Optional    bool
+}
+
+// clientOperation is a method of the client, generated from an operation
+type clientOperation struct {
+	Name     string
+	Method   string
+	Path     string
+	Summary  string
+	Params   []clientParam
+	Result   string // type of the success response, "" if it has no content
+	Required bool   // whether a param is required
+}
+
+type clientParam struct {
+	Name        string // field of the request
+	Key         string // name of the param in the HTTP request
+	In          string
+	Setter      string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// clientGen writes the types of a language
+type clientGen struct {
+	lang      string
+	pkg       string
+	refPrefix string            // qualifies the models in the client file
+	names     map[string]string //definition name:type name
+	imports   map[string]bool
+}
+
+// GenerateClient generates a typed client of the API in outDir, for the
+// lang go or ts. There is a method per operation taking a request with its
+// params, and a type per model.
+func GenerateClient(curpath, lang, outDir string) {
+	if lang == "" {
+		lang = "go"
+	}
+	if lang != "go" && lang != "ts" {
+		asanaLogger.Log.Fatal("Invalid lang value. Must be either \"go\" or \"ts\"")
+	}
+	if outDir == "" {
+		outDir = "client"
+	}
+	if !filepath.IsAbs(outDir) {
+		outDir = filepath.Join(curpath, outDir)
+	}
+	if err := analyseRouter(routerEntry(curpath), curpath); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+
+	v, err := toGeneric(rootapi)
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not convert the documentation: %s", err)
+	}
+	doc, _ := v.(map[string]interface{})
+	defs, _ := doc["definitions"].(map[string]interface{})
+	if defs != nil {
+		if err := applyPropertyExtras(defs, false); err != nil {
+			asanaLogger.Log.Fatalf("%s", err)
+		}
+	}
+
+	g := &clientGen{
+		lang:    lang,
+		pkg:     packageIdent(filepath.Base(outDir)),
+		names:   clientTypeNames(defs),
+		imports: make(map[string]bool),
+	}
+	models := g.models(defs)
+	modelImports := g.importList()
+	if lang == "ts" {
+		g.refPrefix = "models."
+	}
+	g.imports = make(map[string]bool)
+	operations := g.operations()
+	if len(operations) == 0 {
+		asanaLogger.Log.Warn("No annotated routes found, no client generated")
+		return
+	}
+
+	data := map[string]interface{}{
+		"Package":      g.pkg,
+		"Models":       models,
+		"ModelImports": modelImports,
+		"Operations":   operations,
+		"Imports":      g.importList(),
+	}
+	files := map[string]string{
+		"client.go":     goClientTpl,
+		"models.go":     goModelsTpl,
+		"operations.go": goOperationsTpl,
+	}
+	if lang == "ts" {
+		files = map[string]string{
+			"models.ts": tsModelsTpl,
+			"client.ts": tsClientTpl,
+		}
+	}
+
+	_ = os.MkdirAll(outDir, 0755)
+	w := colors.NewColorWriter(os.Stdout)
+	var fnames []string
+	for fname := range files {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+	for _, fname := range fnames {
+		var buf bytes.Buffer
+		t := template.Must(template.New(fname).Funcs(template.FuncMap{"lowerFirst": lowerFirst}).Parse(files[fname]))
+		if err := t.Execute(&buf, data); err != nil {
+			asanaLogger.Log.Fatalf("Could not generate '%s': %s", fname, err)
+		}
+		fpath := filepath.Join(outDir, fname)
+		action := "create"
+		if bu.IsExist(fpath) {
+			action = "update"
+		}
+		if err := ioutil.WriteFile(fpath, buf.Bytes(), 0644); err != nil {
+			asanaLogger.Log.Fatalf("Could not write file '%s': %s", fpath, err)
+		}
+		fmt.Fprintf(w, "\t%s%s%s%s\t %s%s\n", "\x1b[32m", "\x1b[1m", action, "\x1b[21m", fpath, "\x1b[0m")
+		if lang == "go" {
+			bu.FormatSourceCode(fpath)
+		}
+	}
+}
+
+// clientTypeNames names the types of the definitions, e.g. User for
+// models.User. The package is kept when two definitions have the same name.
+func clientTypeNames(defs map[string]interface{}) map[string]string {
+	names := make(map[string]string)
+	count := make(map[string]int)
+	for defName := range defs {
+		names[defName] = typeIdent(defName[strings.Index(defName, ".")+1:])
+		count[names[defName]]++
+	}
+	for defName, name := range names {
+		if count[name] > 1 {
+			names[defName] = typeIdent(defName)
+		}
+	}
+	return names
+}
+
+// typeIdent returns an exported identifier from a name, e.g. PageModelsUser
+// for Page_models.User
+func typeIdent(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+	ident := strings.Join(parts, "")
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "T" + ident
+	}
+	return ident
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// packageIdent returns a package name from a directory name
+func packageIdent(name string) string {
+	ident := strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, name))
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "client" + ident
+	}
+	return ident
+}
+
+func (g *clientGen) importList() []string {
+	var list []string
+	for imp := range g.imports {
+		list = append(list, imp)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// typeOf returns the type of a schema
+func (g *clientGen) typeOf(schema interface{}) string {
+	s, _ := schema.(map[string]interface{})
+	if ref, ok := s["$ref"].(string); ok {
+		name, ok := g.names[strings.TrimPrefix(ref, "#/definitions/")]
+		if !ok {
+			return g.anyType()
+		}
+		return g.refPrefix + name
+	}
+	format, _ := s["format"].(string)
+	switch s["type"] {
+	case "string":
+		if g.lang == "go" {
+			switch format {
+			case "datetime", "date-time":
+				g.imports["time"] = true
+				return "time.Time"
+			case "byte", "binary":
+				return "[]byte"
+			}
+		}
+		return "string"
+	case "integer":
+		if g.lang == "ts" {
+			return "number"
+		}
+		if format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if g.lang == "ts" {
+			return "number"
+		}
+		if format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		if g.lang == "ts" {
+			return "boolean"
+		}
+		return "bool"
+	case "file":
+		if g.lang == "ts" {
+			return "Blob"
+		}
+		return "[]byte"
+	case astTypeArray:
+		if g.lang == "ts" {
+			return g.typeOf(s["items"]) + "[]"
+		}
+		return "[]" + g.typeOf(s["items"])
+	case astTypeObject:
+		if values, ok := s["additionalProperties"]; ok {
+			if g.lang == "ts" {
+				return "Record<string, " + g.typeOf(values) + ">"
+			}
+			return "map[string]" + g.typeOf(values)
+		}
+	}
+	return g.anyType()
+}
+
+func (g *clientGen) anyType() string {
+	if g.lang == "ts" {
+		return "unknown"
+	}
+	return "interface{}"
+}
+
+// fieldType returns the type of a property. In Go the models are referred
+// to by pointer, which also allows recursive models.
+func (g *clientGen) fieldType(schema interface{}) string {
+	s, _ := schema.(map[string]interface{})
+	t := g.typeOf(schema)
+	nullable, _ := s["x-nullable"].(bool)
+	if g.lang == "ts" {
+		if nullable {
+			t += " | null"
+		}
+		return t
+	}
+	if _, isRef := s["$ref"]; isRef && t != g.anyType() {
+		return "*" + t
+	}
+	if nullable && !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") {
+		return "*" + t
+	}
+	return t
+}
+
+// models returns the models of the definitions, sorted by name
+func (g *clientGen) models(defs map[string]interface{}) []clientModel {
+	var models []clientModel
+	for defName, def := range defs {
+		s, _ := def.(map[string]interface{})
+		m := clientModel{Name: g.names[defName]}
+		m.Description, _ = s["description"].(string)
+		props, isStruct := s["properties"].(map[string]interface{})
+		if !isStruct {
+			if s["type"] == astTypeObject {
+				if _, ok := s["additionalProperties"]; !ok {
+					props, isStruct = map[string]interface{}{}, true
+				}
+			}
+		}
+		if !isStruct {
+			m.Type = g.typeOf(s)
+			m.Enum = g.enum(m.Name, s)
+			models = append(models, m)
+			continue
+		}
+		required := make(map[string]bool)
+		if list, ok := s["required"].([]interface{}); ok {
+			for _, r := range list {
+				required[fmt.Sprint(r)] = true
+			}
+		}
+		var propNames []string
+		for name := range props {
+			propNames = append(propNames, name)
+		}
+		sort.Strings(propNames)
+		for _, name := range propNames {
+			p, _ := props[name].(map[string]interface{})
+			f := clientField{
+				Name:     typeIdent(name),
+				JSONName: name,
+				Optional: !required[name],
+			}
+			f.Description, _ = p["description"].(string)
+			f.Type = g.fieldType(p)
+			m.Fields = append(m.Fields, f)
+		}
+		models = append(models, m)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+
+	// the constants may still collide, e.g. StatusA of Status and of StatusA
+	names := make(map[string]int)
+	for _, m := range models {
+		names[m.Name] = 1
+	}
+	for _, m := range models {
+		for i := range m.Enum {
+			e := &m.Enum[i]
+			if n := names[e.Name]; n > 0 {
+				names[e.Name]++
+				e.Name += strconv.Itoa(n + 1)
+			} else {
+				names[e.Name] = 1
+			}
+		}
+	}
+	return models
+}
+
+// enum returns the values of an enum model, named after the constants
+// they were declared with if known. The names are prefixed with the one of
+// the model, e.g. StatusActive, as the constants share the package.
+func (g *clientGen) enum(modelName string, s map[string]interface{}) []clientEnum {
+	values, _ := s["enum"].([]interface{})
+	names, _ := s["x-enum-varnames"].([]interface{})
+	var enum []clientEnum
+	for i, v := range values {
+		e := clientEnum{Name: fmt.Sprintf("%s%d", modelName, i)}
+		if i < len(names) {
+			e.Name = typeIdent(fmt.Sprint(names[i]))
+			if !strings.HasPrefix(e.Name, modelName) {
+				e.Name = modelName + e.Name
+			}
+		}
+		if str, ok := v.(string); ok {
+			e.Value = strconv.Quote(str)
+		} else {
+			e.Value = fmt.Sprint(v)
+		}
+		enum = append(enum, e)
+	}
+	return enum
+}
+
+// operations returns the operations of the documented routes, sorted by
+// path and method
+func (g *clientGen) operations() []clientOperation {
+	var paths []string
+	for p := range rootapi.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var ops []clientOperation
+	names := make(map[string]int)
+	for _, p := range paths {
+		for _, mo := range itemOperations(rootapi.Paths[p]) {
+			op := g.operation(p, mo.method, mo.op)
+			if n := names[op.Name]; n > 0 {
+				names[op.Name]++
+				op.Name += strconv.Itoa(n + 1)
+			} else {
+				names[op.Name] = 1
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func (g *clientGen) operation(swaggerPath, method string, op *swagger.Operation) clientOperation {
+	name := operationFuncs[op]
+	if controller, ok := pathControllers[swaggerPath]; ok && name != "" {
+		name = strings.TrimSuffix(controller, "Controller") + name
+	} else {
+		name = typeIdent(strings.ToLower(method) + " " + swaggerPath)
+	}
+	co := clientOperation{
+		Name:    typeIdent(name),
+		Method:  method,
+		Path:    rootapi.BasePath + swaggerPath,
+		Summary: op.Summary,
+	}
+	if co.Summary == "" {
+		co.Summary = op.Description
+	}
+
+	fields := make(map[string]bool)
+	for _, para := range op.Parameters {
+		cp := clientParam{
+			Name:        typeIdent(para.Name),
+			Key:         para.Name,
+			In:          para.In,
+			Description: para.Description,
+			Required:    para.Required || para.In == "path",
+		}
+		if para.In == "body" {
+			cp.Name = "Body"
+		}
+		if fields[cp.Name] {
+			cp.Name += typeIdent(para.In)
+		}
+		fields[cp.Name] = true
+		if g.lang == "ts" {
+			cp.Name = lowerFirst(cp.Name)
+		}
+
+		switch para.In {
+		case "path":
+			cp.Setter = "Path"
+		case "header":
+			cp.Setter = "Header"
+		case "cookie":
+			cp.Setter = "Cookie"
+		case "formData":
+			cp.Setter = "Form"
+		case "body":
+			cp.Setter = "Body"
+		default:
+			cp.Setter = "Query"
+		}
+		var schema interface{}
+		var err error
+		if para.Schema != nil {
+			schema, err = toGeneric(para.Schema)
+		} else {
+			schema, err = toGeneric(paramTypeSchema(para))
+		}
+		if err != nil {
+			asanaLogger.Log.Fatalf("Could not convert the parameter '%s' of %s: %s", para.Name, co.Name, err)
+		}
+		cp.Type = g.typeOf(schema)
+		if g.lang == "go" && !cp.Required && !strings.HasPrefix(cp.Type, "[]") &&
+			!strings.HasPrefix(cp.Type, "map[") && cp.Type != g.anyType() {
+			cp.Type = "*" + cp.Type
+		}
+		co.Required = co.Required || cp.Required
+		co.Params = append(co.Params, cp)
+	}
+
+	status := strconv.Itoa(successStatus(op))
+	if rs, ok := op.Responses[status]; ok && rs.Schema != nil {
+		schema, err := toGeneric(rs.Schema)
+		if err != nil {
+			asanaLogger.Log.Fatalf("Could not convert the result of %s: %s", co.Name, err)
+		}
+		co.Result = g.typeOf(schema)
+		if _, isRef := schema.(map[string]interface{})["$ref"]; isRef && g.lang == "go" {
+			co.Result = "*" + co.Result
+		}
+	}
+	return co
+}
+
+// paramTypeSchema returns the schema of a non body parameter
+func paramTypeSchema(para swagger.Parameter) map[string]interface{} {
+	s := map[string]interface{}{"type": para.Type, "format": para.Format}
+	if para.Items != nil {
+		s["items"] = map[string]interface{}{"type": para.Items.Type, "format": para.Items.Format}
+	}
+	return s
+}
+
+const goClientTpl = `// Code generated by asanacli. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Client calls the API
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Header is sent with every request, e.g. Authorization
+	Header http.Header
+}
+
+// NewClient returns a client of the API served at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		HTTPClient: http.DefaultClient,
+		Header:     make(http.Header),
+	}
+}
+
+// Error is returned for the responses with an error status
+type Error struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	form   url.Values
+	files  map[string][]byte
+	body   interface{}
+}
+
+func newRequest(method, path string) *request {
+	return &request{
+		method: method,
+		path:   path,
+		query:  make(url.Values),
+		header: make(http.Header),
+		form:   make(url.Values),
+		files:  make(map[string][]byte),
+	}
+}
+
+func (r *request) setPath(key string, v interface{}) {
+	for _, s := range values(v) {
+		r.path = strings.Replace(r.path, "{"+key+"}", url.PathEscape(s), -1)
+	}
+}
+
+func (r *request) setQuery(key string, v interface{}) {
+	for _, s := range values(v) {
+		r.query.Add(key, s)
+	}
+}
+
+func (r *request) setHeader(key string, v interface{}) {
+	for _, s := range values(v) {
+		r.header.Add(key, s)
+	}
+}
+
+func (r *request) setCookie(key string, v interface{}) {
+	for _, s := range values(v) {
+		r.header.Add("Cookie", (&http.Cookie{Name: key, Value: s}).String())
+	}
+}
+
+func (r *request) setForm(key string, v interface{}) {
+	if b, ok := v.([]byte); ok {
+		if b != nil {
+			r.files[key] = b
+		}
+		return
+	}
+	for _, s := range values(v) {
+		r.form.Add(key, s)
+	}
+}
+
+func (r *request) setBody(key string, v interface{}) {
+	if values(v) != nil {
+		r.body = v
+	}
+}
+
+// values returns the string values of a param, none for a nil pointer
+func values(v interface{}) []string {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		var list []string
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, fmt.Sprint(rv.Index(i).Interface()))
+		}
+		return list
+	}
+	return []string{fmt.Sprint(rv.Interface())}
+}
+
+func (r *request) encodeBody() (io.Reader, string, error) {
+	switch {
+	case r.body != nil:
+		b, err := json.Marshal(r.body)
+		return bytes.NewReader(b), "application/json", err
+	case len(r.files) > 0:
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for key, vs := range r.form {
+			for _, v := range vs {
+				if err := mw.WriteField(key, v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+		for key, b := range r.files {
+			fw, err := mw.CreateFormFile(key, key)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := fw.Write(b); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, mw.FormDataContentType(), nil
+	case len(r.form) > 0:
+		return strings.NewReader(r.form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	return nil, "", nil
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, r *request, out interface{}) error {
+	u := strings.TrimRight(c.BaseURL, "/") + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	body, contentType, err := r.encodeBody()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(r.method, u, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for key, vs := range c.Header {
+		for _, v := range vs {
+			req.Header.Add(key, v)
+		}
+	}
+	for key, vs := range r.header {
+		for _, v := range vs {
+			req.Header.Add(key, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &Error{StatusCode: resp.StatusCode, Body: data}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+`
+
+const goModelsTpl = `// Code generated by asanacli. DO NOT EDIT.
+
+package {{.Package}}
+{{if .ModelImports}}
+import (
+{{range .ModelImports}}	"{{.}}"
+{{end}})
+{{end}}
+{{range .Models}}
+{{if .Description}}// {{.Name}} {{.Description}}
+{{else}}// {{.Name}} is a model of the API
+{{end}}{{if .Type}}type {{.Name}} {{.Type}}
+{{$model := .}}{{if .Enum}}
+const (
+{{range .Enum}}	{{.Name}} {{$model.Name}} = {{.Value}}
+{{end}})
+{{end}}{{else}}type {{.Name}} struct {
+{{range .Fields}}{{if .Description}}	// {{.Description}}
+{{end}}	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSONName}}{{if .Optional}},omitempty{{end}}"` + "`" + `
+{{end}}}
+{{end}}{{end}}`
+
+const goOperationsTpl = `// Code generated by asanacli. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{range .Imports}}	"{{.}}"
+{{end}})
+{{range .Operations}}
+// {{.Name}}Request holds the params of {{.Name}}
+type {{.Name}}Request struct {
+{{range .Params}}{{if .Description}}	// {{.Description}}
+{{end}}	{{.Name}} {{.Type}} // {{.In}}
+{{end}}}
+
+// {{.Name}} calls {{.Method}} {{.Path}}{{if .Summary}}: {{.Summary}}{{end}}
+func (c *Client) {{.Name}}(ctx context.Context, req *{{.Name}}Request) ({{if .Result}}{{.Result}}, {{end}}error) {
+	if req == nil {
+		req = &{{.Name}}Request{}
+	}
+	r := newRequest("{{.Method}}", "{{.Path}}")
+{{range .Params}}	r.set{{.Setter}}("{{.Key}}", req.{{.Name}})
+{{end}}{{if .Result}}	var out {{.Result}}
+	err := c.do(ctx, r, &out)
+	return out, err
+{{else}}	return c.do(ctx, r, nil)
+{{end}}}
+{{end}}`
+
+const tsModelsTpl = `// Code generated by asanacli. DO NOT EDIT.
+{{range .Models}}
+{{if .Description}}/** {{.Description}} */
+{{end}}{{if .Enum}}export type {{.Name}} = {{range $i, $e := .Enum}}{{if $i}} | {{end}}{{$e.Value}}{{end}}
+{{else if .Type}}export type {{.Name}} = {{.Type}}
+{{else}}export interface {{.Name}} {
+{{range .Fields}}{{if .Description}}  /** {{.Description}} */
+{{end}}  {{printf "%q" .JSONName}}{{if .Optional}}?{{end}}: {{.Type}}
+{{end}}}
+{{end}}{{end}}`
+
+const tsClientTpl = `// Code generated by asanacli. DO NOT EDIT.
+
+import * as models from './models'
+
+export class ApiError extends Error {
+  constructor(public status: number, public body: string) {
+    super(` + "`${status}: ${body}`" + `)
+  }
+}
+
+export interface ClientOptions {
+  baseUrl: string
+  /** sent with every request, e.g. Authorization */
+  headers?: Record<string, string>
+  fetch?: typeof fetch
+}
+
+type Param = unknown
+
+class Request {
+  query = new URLSearchParams()
+  headers: Record<string, string> = {}
+  form?: FormData
+  body?: unknown
+
+  constructor(public method: string, public path: string) {}
+
+  private static values(v: Param): string[] {
+    if (v === undefined || v === null) {
+      return []
+    }
+    return (Array.isArray(v) ? v : [v]).map(String)
+  }
+
+  setPath(key: string, v: Param) {
+    for (const s of Request.values(v)) {
+      this.path = this.path.replace('{' + key + '}', encodeURIComponent(s))
+    }
+  }
+
+  setQuery(key: string, v: Param) {
+    for (const s of Request.values(v)) {
+      this.query.append(key, s)
+    }
+  }
+
+  setHeader(key: string, v: Param) {
+    for (const s of Request.values(v)) {
+      this.headers[key] = s
+    }
+  }
+
+  setCookie(key: string, v: Param) {
+    for (const s of Request.values(v)) {
+      const cookie = key + '=' + encodeURIComponent(s)
+      this.headers['Cookie'] = this.headers['Cookie'] ? this.headers['Cookie'] + '; ' + cookie : cookie
+    }
+  }
+
+  setForm(key: string, v: Param) {
+    if (v === undefined || v === null) {
+      return
+    }
+    this.form = this.form || new FormData()
+    if (v instanceof Blob) {
+      this.form.append(key, v)
+      return
+    }
+    for (const s of Request.values(v)) {
+      this.form.append(key, s)
+    }
+  }
+
+  setBody(key: string, v: Param) {
+    if (v !== undefined) {
+      this.body = v
+    }
+  }
+}
+
+export class Client {
+  constructor(private options: ClientOptions) {}
+
+  private async send<T>(r: Request): Promise<T> {
+    const query = r.query.toString()
+    const url = this.options.baseUrl.replace(/\/+$/, '') + r.path + (query ? '?' + query : '')
+    const headers: Record<string, string> = { Accept: 'application/json', ...this.options.headers, ...r.headers }
+    let body: BodyInit | undefined
+    if (r.body !== undefined) {
+      headers['Content-Type'] = 'application/json'
+      body = JSON.stringify(r.body)
+    } else if (r.form) {
+      body = r.form
+    }
+    const doFetch = this.options.fetch || fetch
+    const resp = await doFetch(url, { method: r.method, headers, body })
+    const text = await resp.text()
+    if (!resp.ok) {
+      throw new ApiError(resp.status, text)
+    }
+    return (text ? JSON.parse(text) : undefined) as T
+  }
+{{range .Operations}}
+  /** {{.Method}} {{.Path}}{{if .Summary}}: {{.Summary}}{{end}} */
+  async {{lowerFirst .Name}}(req: {{.Name}}Request{{if not .Required}} = {}{{end}}): Promise<{{if .Result}}{{.Result}}{{else}}void{{end}}> {
+    const r = new Request('{{.Method}}', '{{.Path}}')
+{{range .Params}}    r.set{{.Setter}}('{{.Key}}', req.{{.Name}})
+{{end}}    return this.send<{{if .Result}}{{.Result}}{{else}}void{{end}}>(r)
+  }
+{{end}}}
+{{range .Operations}}
+/** The params of {{lowerFirst .Name}} */
+export interface {{.Name}}Request {
+{{range .Params}}{{if .Description}}  /** {{.Description}} */
+{{end}}  {{.Name}}{{if not .Required}}?{{end}}: {{.Type}}
+{{end}}}
+{{end}}`
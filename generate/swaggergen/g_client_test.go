@@ -0,0 +1,43 @@
+package swaggergen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientEnumNames(t *testing.T) {
+	defs := map[string]interface{}{
+		"models.Status": map[string]interface{}{
+			"type":            "string",
+			"enum":            []interface{}{"active", "inactive", "a"},
+			"x-enum-varnames": []interface{}{"Active", "StatusInactive", "A"},
+		},
+		"models.Role": map[string]interface{}{
+			"type":            "string",
+			"enum":            []interface{}{"active", "admin"},
+			"x-enum-varnames": []interface{}{"Active", "Admin"},
+		},
+		"models.Level": map[string]interface{}{
+			"type": "integer",
+			"enum": []interface{}{1, 2},
+		},
+		"models.StatusA": map[string]interface{}{
+			"type": "string",
+		},
+	}
+	g := &clientGen{lang: "go", names: clientTypeNames(defs), imports: make(map[string]bool)}
+	got := make(map[string][]string)
+	for _, m := range g.models(defs) {
+		for _, e := range m.Enum {
+			got[m.Name] = append(got[m.Name], e.Name+" = "+e.Value)
+		}
+	}
+	want := map[string][]string{
+		"Level":  {"Level0 = 1", "Level1 = 2"},
+		"Role":   {`RoleActive = "active"`, `RoleAdmin = "admin"`},
+		"Status": {`StatusActive = "active"`, `StatusInactive = "inactive"`, `StatusA2 = "a"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
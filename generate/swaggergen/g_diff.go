@@ -0,0 +1,509 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DocChange is a change of the API between two docs
+type DocChange struct {
+	Breaking  bool   `json:"breaking"`
+	Operation string `json:"operation,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (c DocChange) String() string {
+	if c.Operation == "" {
+		return c.Message
+	}
+	return c.Operation + ": " + c.Message
+}
+
+// apiDoc is a Swagger 2.0 or OpenAPI 3 document, decoded as generic JSON
+type apiDoc struct {
+	doc        map[string]interface{}
+	operations map[string]*apiOperation // key of the operation:operation
+}
+
+type apiOperation struct {
+	name      string // e.g. GET /v1/user/{id}
+	params    map[string]map[string]interface{}
+	body      interface{} // schema of the body
+	hasBody   bool
+	required  bool                   // whether the body is required
+	responses map[string]interface{} // status:schema, nil if the response has no content
+}
+
+const (
+	inRequest  = "request"
+	inResponse = "response"
+)
+
+var pathParamRegexp = regexp.MustCompile(`{[^}]+}`)
+
+// DiffDocs compares two docs generated by generate docs, in JSON or YAML,
+// and returns the changes, the breaking ones first
+func DiffDocs(oldPath, newPath string) ([]DocChange, error) {
+	oldDoc, err := loadAPIDoc(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := loadAPIDoc(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []DocChange{}
+	var keys []string
+	for key := range oldDoc.operations {
+		keys = append(keys, key)
+	}
+	for key := range newDoc.operations {
+		if _, ok := oldDoc.operations[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldOp, inOld := oldDoc.operations[key]
+		newOp, inNew := newDoc.operations[key]
+		switch {
+		case !inNew:
+			changes = append(changes, DocChange{Breaking: true, Operation: oldOp.name, Message: "endpoint removed"})
+		case !inOld:
+			changes = append(changes, DocChange{Operation: newOp.name, Message: "endpoint added"})
+		default:
+			d := &docDiff{old: oldDoc, new: newDoc, operation: newOp.name}
+			d.operations(oldOp, newOp)
+			changes = append(changes, d.changes...)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Breaking && !changes[j].Breaking })
+	return changes, nil
+}
+
+func loadAPIDoc(fpath string) (*apiDoc, error) {
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if strings.HasSuffix(fpath, ".yml") || strings.HasSuffix(fpath, ".yaml") {
+		err = yaml.Unmarshal(data, &v)
+		v = yamlToGeneric(v)
+	} else {
+		err = json.Unmarshal(data, &v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %s", fpath, err)
+	}
+	a := &apiDoc{operations: make(map[string]*apiOperation)}
+	a.doc, _ = v.(map[string]interface{})
+	if a.doc == nil || (a.doc["swagger"] == nil && a.doc["openapi"] == nil) {
+		return nil, fmt.Errorf("'%s' is not a Swagger or OpenAPI document", fpath)
+	}
+
+	base := strings.TrimRight(a.basePath(), "/")
+	paths, _ := a.doc["paths"].(map[string]interface{})
+	for p, item := range paths {
+		item, _ := item.(map[string]interface{})
+		shared, _ := item["parameters"].([]interface{})
+		for _, method := range []string{"get", "post", "put", "patch", "delete", "head", "options"} {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := strings.ToUpper(method) + " " + base + p
+			// the operations are matched whatever the names of their path params
+			key := strings.ToUpper(method) + " " + pathParamRegexp.ReplaceAllString(base+p, "{}")
+			a.operations[key] = a.operation(name, shared, op)
+		}
+	}
+	return a, nil
+}
+
+// yamlToGeneric converts the maps decoded by yaml to the ones of encoding/json
+func yamlToGeneric(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = yamlToGeneric(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = yamlToGeneric(value)
+		}
+	}
+	return v
+}
+
+func (a *apiDoc) isOpenAPI3() bool {
+	return a.doc["openapi"] != nil
+}
+
+func (a *apiDoc) basePath() string {
+	if !a.isOpenAPI3() {
+		base, _ := a.doc["basePath"].(string)
+		return base
+	}
+	servers, _ := a.doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	u, _ := server["url"].(string)
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+		if j := strings.Index(u, "/"); j >= 0 {
+			return u[j:]
+		}
+		return ""
+	}
+	return u
+}
+
+// resolve follows the $ref of an object of the document
+func (a *apiDoc) resolve(v interface{}) map[string]interface{} {
+	obj, _ := v.(map[string]interface{})
+	for i := 0; i < 10; i++ {
+		ref, ok := obj["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			break
+		}
+		var target interface{} = a.doc
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			parent, _ := target.(map[string]interface{})
+			target = parent[key]
+		}
+		obj, _ = target.(map[string]interface{})
+	}
+	return obj
+}
+
+func (a *apiDoc) operation(name string, shared []interface{}, op map[string]interface{}) *apiOperation {
+	o := &apiOperation{
+		name:      name,
+		params:    make(map[string]map[string]interface{}),
+		responses: make(map[string]interface{}),
+	}
+	list, _ := op["parameters"].([]interface{})
+	for _, p := range append(append([]interface{}{}, shared...), list...) {
+		para := a.resolve(p)
+		if para == nil {
+			continue
+		}
+		if para["in"] == "body" {
+			o.body, o.hasBody = para["schema"], true
+			o.required, _ = para["required"].(bool)
+			continue
+		}
+		key := fmt.Sprintf("%s:%s", para["in"], para["name"])
+		if para["in"] == "path" {
+			// the path params are matched by position, as they may be renamed
+			for i, p := range pathParamRegexp.FindAllString(name, -1) {
+				if p == "{"+fmt.Sprint(para["name"])+"}" {
+					key = fmt.Sprintf("path:%d", i)
+				}
+			}
+		}
+		o.params[key] = para
+	}
+	if body, ok := op["requestBody"]; ok {
+		body := a.resolve(body)
+		o.required, _ = body["required"].(bool)
+		o.body, o.hasBody = mediaSchema(body)
+	}
+
+	responses, _ := op["responses"].(map[string]interface{})
+	for code, resp := range responses {
+		resp := a.resolve(resp)
+		if a.isOpenAPI3() {
+			o.responses[code], _ = mediaSchema(resp)
+		} else {
+			o.responses[code] = resp["schema"]
+		}
+	}
+	return o
+}
+
+// mediaSchema returns the JSON schema of an OpenAPI 3 request body or response
+func mediaSchema(v map[string]interface{}) (interface{}, bool) {
+	content, _ := v["content"].(map[string]interface{})
+	if media, ok := content["application/json"].(map[string]interface{}); ok {
+		return media["schema"], true
+	}
+	for _, media := range content {
+		media, _ := media.(map[string]interface{})
+		return media["schema"], true
+	}
+	return nil, false
+}
+
+// docDiff compares the operations with the same key of two docs
+type docDiff struct {
+	old, new  *apiDoc
+	operation string
+	changes   []DocChange
+	visited   map[string]bool
+}
+
+func (d *docDiff) add(breaking bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, DocChange{Breaking: breaking, Operation: d.operation, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *docDiff) operations(o, n *apiOperation) {
+	var keys []string
+	for key := range o.params {
+		keys = append(keys, key)
+	}
+	for key := range n.params {
+		if _, ok := o.params[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		op, inOld := o.params[key]
+		np, inNew := n.params[key]
+		oldRequired, _ := op["required"].(bool)
+		newRequired, _ := np["required"].(bool)
+		label := paramLabel(np)
+		switch {
+		case !inNew:
+			label = paramLabel(op)
+			d.add(false, "%s param removed", label)
+		case !inOld && newRequired:
+			d.add(true, "required %s param added", label)
+		case !inOld:
+			d.add(false, "optional %s param added", label)
+		default:
+			if newRequired && !oldRequired {
+				d.add(true, "%s param became required", label)
+			} else if oldRequired && !newRequired {
+				d.add(false, "%s param became optional", label)
+			}
+			d.schemas(label+" param", paramJSONSchema(op), paramJSONSchema(np), inRequest)
+		}
+	}
+
+	switch {
+	case o.hasBody && !n.hasBody:
+		d.add(false, "request body removed")
+	case !o.hasBody && n.hasBody && n.required:
+		d.add(true, "required request body added")
+	case !o.hasBody && n.hasBody:
+		d.add(false, "optional request body added")
+	case o.hasBody && n.hasBody:
+		if n.required && !o.required {
+			d.add(true, "request body became required")
+		}
+		d.schemas("request body", o.body, n.body, inRequest)
+	}
+
+	var codes []string
+	for code := range o.responses {
+		codes = append(codes, code)
+	}
+	for code := range n.responses {
+		if _, ok := o.responses[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		oldSchema, inOld := o.responses[code]
+		newSchema, inNew := n.responses[code]
+		success := strings.HasPrefix(code, "2")
+		switch {
+		case !inNew:
+			d.add(success, "%s response removed", code)
+		case !inOld:
+			d.add(false, "%s response added", code)
+		case oldSchema != nil && newSchema == nil:
+			d.add(success, "%s response content removed", code)
+		case oldSchema != nil:
+			d.schemas(code+" response", oldSchema, newSchema, inResponse)
+		}
+	}
+}
+
+func paramLabel(para map[string]interface{}) string {
+	return fmt.Sprintf("%s '%s'", para["in"], para["name"])
+}
+
+// paramJSONSchema returns the schema of a non body param: the param itself in
+// Swagger 2.0, its schema in OpenAPI 3
+func paramJSONSchema(para map[string]interface{}) interface{} {
+	if s, ok := para["schema"]; ok {
+		return s
+	}
+	return para
+}
+
+// schemas compares two schemas. Where the schema is sent by the clients,
+// in a request, what they sent has to stay valid. Where it is received, in
+// a response, what they read has to be still there.
+func (d *docDiff) schemas(at string, oldSchema, newSchema interface{}, direction string) {
+	o, n := d.old.resolve(oldSchema), d.new.resolve(newSchema)
+	if o == nil || n == nil {
+		return
+	}
+	// the models may be recursive
+	oldObj, _ := oldSchema.(map[string]interface{})
+	newObj, _ := newSchema.(map[string]interface{})
+	oldRef, _ := oldObj["$ref"].(string)
+	newRef, _ := newObj["$ref"].(string)
+	if oldRef != "" || newRef != "" {
+		key := direction + ":" + oldRef + ":" + newRef
+		if d.visited[key] {
+			return
+		}
+		if d.visited == nil {
+			d.visited = make(map[string]bool)
+		}
+		d.visited[key] = true
+		defer delete(d.visited, key)
+	}
+
+	oldType, newType := schemaType(o), schemaType(n)
+	if oldType != newType {
+		widened := direction == inRequest && oldType == "integer" && newType == "number" ||
+			direction == inResponse && oldType == "number" && newType == "integer"
+		d.add(!widened, "%s type changed from %s to %s", at, oldType, newType)
+		return
+	}
+	oldFormat, _ := o["format"].(string)
+	newFormat, _ := n["format"].(string)
+	if oldFormat != newFormat && oldFormat != "" && newFormat != "" {
+		d.add(true, "%s format changed from %s to %s", at, oldFormat, newFormat)
+	}
+	d.enums(at, o, n, direction)
+
+	if oldType == "array" {
+		d.schemas(at+"[]", o["items"], n["items"], direction)
+		return
+	}
+	oldProps, _ := o["properties"].(map[string]interface{})
+	newProps, _ := n["properties"].(map[string]interface{})
+	oldRequired, newRequired := requiredSet(o), requiredSet(n)
+	var names []string
+	for name := range oldProps {
+		names = append(names, name)
+	}
+	for name := range newProps {
+		if _, ok := oldProps[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		op, inOld := oldProps[name]
+		np, inNew := newProps[name]
+		field := at + " field '" + name + "'"
+		switch {
+		case !inNew:
+			d.add(direction == inResponse, "%s removed", field)
+		case !inOld && direction == inRequest && newRequired[name]:
+			d.add(true, "required %s added", field)
+		case !inOld:
+			d.add(false, "%s added", field)
+		default:
+			if direction == inRequest && newRequired[name] && !oldRequired[name] {
+				d.add(true, "%s became required", field)
+			}
+			if direction == inResponse && oldRequired[name] && !newRequired[name] {
+				d.add(true, "%s became optional", field)
+			}
+			d.schemas(field, op, np, direction)
+		}
+	}
+	if oldValues, ok := o["additionalProperties"].(map[string]interface{}); ok {
+		if newValues, ok := n["additionalProperties"].(map[string]interface{}); ok {
+			d.schemas(at+"{}", oldValues, newValues, direction)
+		}
+	}
+}
+
+// enums compares the values of two enums. The clients cannot send the
+// values which are removed, nor expect the ones which are added.
+func (d *docDiff) enums(at string, o, n map[string]interface{}, direction string) {
+	oldEnum, _ := o["enum"].([]interface{})
+	newEnum, _ := n["enum"].([]interface{})
+	if len(oldEnum) == 0 || len(newEnum) == 0 {
+		if len(oldEnum) == 0 && len(newEnum) > 0 {
+			d.add(direction == inRequest, "%s restricted to %v", at, newEnum)
+		}
+		return
+	}
+	oldValues, newValues := enumSet(oldEnum), enumSet(newEnum)
+	for _, v := range oldEnum {
+		if !newValues[fmt.Sprint(v)] {
+			d.add(direction == inRequest, "%s value %v removed", at, v)
+		}
+	}
+	for _, v := range newEnum {
+		if !oldValues[fmt.Sprint(v)] {
+			d.add(direction == inResponse, "%s value %v added", at, v)
+		}
+	}
+}
+
+func enumSet(enum []interface{}) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range enum {
+		set[fmt.Sprint(v)] = true
+	}
+	return set
+}
+
+func requiredSet(s map[string]interface{}) map[string]bool {
+	set := make(map[string]bool)
+	required, _ := s["required"].([]interface{})
+	for _, name := range required {
+		set[fmt.Sprint(name)] = true
+	}
+	return set
+}
+
+// schemaType returns the type of a schema, without null for the nullable
+// ones of OpenAPI 3.1
+func schemaType(s map[string]interface{}) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		var types []string
+		for _, tt := range t {
+			if tt != "null" {
+				types = append(types, fmt.Sprint(tt))
+			}
+		}
+		return strings.Join(types, "|")
+	}
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
@@ -0,0 +1,380 @@
+package swaggergen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// swaggerDoc returns a Swagger 2.0 doc with the given paths and definitions
+func swaggerDoc(paths, definitions string) string {
+	if definitions == "" {
+		definitions = "{}"
+	}
+	return fmt.Sprintf(`{"swagger": "2.0", "basePath": "/v1", "paths": %s, "definitions": %s}`, paths, definitions)
+}
+
+const userDefinitions = `{
+	"User": {
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": {"type": "integer", "format": "int64"},
+			"name": {"type": "string"},
+			"age": {"type": "integer"},
+			"role": {"type": "string", "enum": ["admin", "user"]}
+		}
+	}
+}`
+
+const userPaths = `{
+	"/user/{id}": {
+		"get": {
+			"parameters": [
+				{"in": "path", "name": "id", "required": true, "type": "integer"},
+				{"in": "query", "name": "fields", "required": false, "type": "string"}
+			],
+			"responses": {
+				"200": {"description": "", "schema": {"$ref": "#/definitions/User"}},
+				"404": {"description": ""}
+			}
+		},
+		"put": {
+			"parameters": [
+				{"in": "path", "name": "id", "required": true, "type": "integer"},
+				{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}
+			],
+			"responses": {"200": {"description": ""}}
+		}
+	}
+}`
+
+func TestDiffDocs(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      string
+		defs       string
+		breaking   []string // the messages of the breaking changes
+		compatible []string // the messages of the other ones
+	}{
+		{
+			name:  "no change",
+			paths: userPaths,
+			defs:  userDefinitions,
+		},
+		{
+			name: "endpoint removed",
+			paths: `{"/user/{id}": {"get": {
+				"parameters": [
+					{"in": "path", "name": "id", "required": true, "type": "integer"},
+					{"in": "query", "name": "fields", "required": false, "type": "string"}
+				],
+				"responses": {
+					"200": {"description": "", "schema": {"$ref": "#/definitions/User"}},
+					"404": {"description": ""}
+				}
+			}}}`,
+			defs:     userDefinitions,
+			breaking: []string{"endpoint removed"},
+		},
+		{
+			name: "endpoint added with a renamed path param",
+			paths: `{"/user/{uid}": {
+				"get": {
+					"parameters": [
+						{"in": "path", "name": "uid", "required": true, "type": "integer"},
+						{"in": "query", "name": "fields", "required": false, "type": "string"}
+					],
+					"responses": {
+						"200": {"description": "", "schema": {"$ref": "#/definitions/User"}},
+						"404": {"description": ""}
+					}
+				},
+				"put": {
+					"parameters": [
+						{"in": "path", "name": "uid", "required": true, "type": "integer"},
+						{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}
+					],
+					"responses": {"200": {"description": ""}}
+				},
+				"delete": {
+					"parameters": [{"in": "path", "name": "uid", "required": true, "type": "integer"}],
+					"responses": {"204": {"description": ""}}
+				}
+			}}`,
+			defs:       userDefinitions,
+			compatible: []string{"endpoint added"},
+		},
+		{
+			name: "params",
+			paths: `{"/user/{id}": {
+				"get": {
+					"parameters": [
+						{"in": "path", "name": "id", "required": true, "type": "integer"},
+						{"in": "query", "name": "fields", "required": true, "type": "string"},
+						{"in": "query", "name": "lang", "required": true, "type": "string"},
+						{"in": "header", "name": "X-Trace", "required": false, "type": "string"}
+					],
+					"responses": {
+						"200": {"description": "", "schema": {"$ref": "#/definitions/User"}},
+						"404": {"description": ""}
+					}
+				},
+				"put": {
+					"parameters": [
+						{"in": "path", "name": "id", "required": true, "type": "number"},
+						{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}
+					],
+					"responses": {"200": {"description": ""}}
+				}
+			}}`,
+			defs: userDefinitions,
+			breaking: []string{
+				"query 'fields' param became required",
+				"required query 'lang' param added",
+			},
+			compatible: []string{
+				"optional header 'X-Trace' param added",
+				"path 'id' param type changed from integer to number",
+			},
+		},
+		{
+			name: "param removed and type narrowed",
+			paths: `{"/user/{id}": {
+				"get": {
+					"parameters": [{"in": "path", "name": "id", "required": true, "type": "string"}],
+					"responses": {
+						"200": {"description": "", "schema": {"$ref": "#/definitions/User"}},
+						"404": {"description": ""}
+					}
+				},
+				"put": {
+					"parameters": [
+						{"in": "path", "name": "id", "required": true, "type": "integer"},
+						{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}
+					],
+					"responses": {"200": {"description": ""}}
+				}
+			}}`,
+			defs:       userDefinitions,
+			breaking:   []string{"path 'id' param type changed from integer to string"},
+			compatible: []string{"query 'fields' param removed"},
+		},
+		{
+			name: "responses",
+			paths: `{"/user/{id}": {
+				"get": {
+					"parameters": [
+						{"in": "path", "name": "id", "required": true, "type": "integer"},
+						{"in": "query", "name": "fields", "required": false, "type": "string"}
+					],
+					"responses": {
+						"200": {"description": ""},
+						"400": {"description": ""}
+					}
+				},
+				"put": {
+					"parameters": [
+						{"in": "path", "name": "id", "required": true, "type": "integer"},
+						{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}
+					],
+					"responses": {"201": {"description": ""}}
+				}
+			}}`,
+			defs: userDefinitions,
+			breaking: []string{
+				"200 response content removed",
+				"200 response removed",
+			},
+			compatible: []string{
+				"400 response added",
+				"404 response removed",
+				"201 response added",
+			},
+		},
+		{
+			name:  "fields",
+			paths: userPaths,
+			defs: `{
+				"User": {
+					"type": "object",
+					"required": ["id", "email"],
+					"properties": {
+						"id": {"type": "integer", "format": "int32"},
+						"email": {"type": "string"},
+						"age": {"type": "number"},
+						"role": {"type": "string", "enum": ["admin", "user"]},
+						"nick": {"type": "string"}
+					}
+				}
+			}`,
+			breaking: []string{
+				"200 response field 'id' format changed from int64 to int32",
+				"200 response field 'name' removed",
+				"200 response field 'age' type changed from integer to number",
+				"required request body field 'email' added",
+				"request body field 'id' format changed from int64 to int32",
+			},
+			compatible: []string{
+				"200 response field 'email' added",
+				"200 response field 'nick' added",
+				"request body field 'age' type changed from integer to number",
+				"request body field 'name' removed",
+				"request body field 'nick' added",
+			},
+		},
+		{
+			name:  "enums",
+			paths: userPaths,
+			defs: `{
+				"User": {
+					"type": "object",
+					"required": ["id", "name"],
+					"properties": {
+						"id": {"type": "integer", "format": "int64"},
+						"name": {"type": "string", "enum": ["a", "b"]},
+						"age": {"type": "integer"},
+						"role": {"type": "string", "enum": ["user", "guest"]}
+					}
+				}
+			}`,
+			breaking: []string{
+				"200 response field 'role' value guest added",
+				"request body field 'name' restricted to [a b]",
+				"request body field 'role' value admin removed",
+			},
+			compatible: []string{
+				"200 response field 'name' restricted to [a b]",
+				"200 response field 'role' value admin removed",
+				"request body field 'role' value guest added",
+			},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldPath := filepath.Join(dir, "old.json")
+	if err := ioutil.WriteFile(oldPath, []byte(swaggerDoc(userPaths, userDefinitions)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newPath := filepath.Join(dir, "new.json")
+			if err := ioutil.WriteFile(newPath, []byte(swaggerDoc(tt.paths, tt.defs)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			changes, err := DiffDocs(oldPath, newPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			breaking, compatible := map[string]int{}, map[string]int{}
+			for i, c := range changes {
+				if c.Breaking {
+					if i > 0 && !changes[i-1].Breaking {
+						t.Errorf("breaking change after the other ones: %s", c)
+					}
+					breaking[c.Message]++
+				} else {
+					compatible[c.Message]++
+				}
+			}
+			if want := messageSet(tt.breaking); !reflect.DeepEqual(breaking, want) {
+				t.Errorf("breaking changes: got %v, want %v", breaking, want)
+			}
+			if want := messageSet(tt.compatible); !reflect.DeepEqual(compatible, want) {
+				t.Errorf("non-breaking changes: got %v, want %v", compatible, want)
+			}
+		})
+	}
+}
+
+func messageSet(messages []string) map[string]int {
+	set := map[string]int{}
+	for _, m := range messages {
+		set[m]++
+	}
+	return set
+}
+
+func TestDiffDocsOpenAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldDoc := `openapi: 3.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /user:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name:
+                  type: string
+      responses:
+        "200":
+          description: ""
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id:
+                    type: integer
+`
+	newDoc := `{
+	"openapi": "3.0.0",
+	"servers": [{"url": "/v1"}],
+	"paths": {"/user": {"post": {
+		"requestBody": {"required": true, "content": {"application/json": {"schema": {
+			"type": "object",
+			"properties": {"name": {"type": "string"}}
+		}}}},
+		"responses": {"200": {"description": "", "content": {"application/json": {"schema": {
+			"type": "object",
+			"properties": {"id": {"type": "number"}}
+		}}}}}
+	}}}
+}`
+	oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.json")
+	if err := ioutil.WriteFile(oldPath, []byte(oldDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newPath, []byte(newDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := DiffDocs(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DocChange{
+		{Breaking: true, Operation: "POST /v1/user", Message: "request body became required"},
+		{Breaking: true, Operation: "POST /v1/user", Message: "200 response field 'id' type changed from integer to number"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %v, want %v", changes, want)
+	}
+
+	if _, err := DiffDocs(oldPath, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("no error for a missing doc")
+	}
+	if err := ioutil.WriteFile(newPath, []byte(`{"paths": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DiffDocs(oldPath, newPath); err == nil {
+		t.Error("no error for a doc which is neither Swagger nor OpenAPI")
+	}
+}
@@ -15,11 +15,9 @@
 package swaggergen
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
 	"os"
 	"path"
@@ -27,13 +25,10 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 
-	yaml "gopkg.in/yaml.v2"
-
 	"github.com/goasana/asana/swagger"
 	"github.com/goasana/asana/utils"
 	asanaLogger "github.com/goasana/asanacli/logger"
@@ -61,6 +56,15 @@ var controllerList map[string]map[string]*swagger.Item //controllername Paths it
 var modelsList map[string]map[string]swagger.Schema
 var rootapi swagger.Swagger
 var astPkgs []*ast.Package
+var pathControllers map[string]string                             //swagger path:controller type name
+var operationFuncs map[*swagger.Operation]string                  //operation:controller method name
+var controllerOperations map[string]map[string]*swagger.Operation //controllername:method name:operation
+var unresolvedRoutes []LintIssue                                  //position and reason of the routes docs could not resolve
+
+// OpenAPI 3 information which has no Swagger 2.0 equivalent
+var openAPIServers []openAPIServer
+var responseOneOf map[*swagger.Operation]map[string][]swagger.Schema //operation:response code:alternative schemas
+var propertyExtras map[string]map[string]map[string]interface{}      //definition:property:schema keywords
 
 // refer to builtin.go
 var basicTypes = map[string]string{
@@ -94,12 +98,27 @@ var stdlibObject = map[string]string{
 }
 
 func init() {
+	resetState()
+	genericModels = make(map[string]genericModel)
+}
+
+// resetState clears what a docs generation collects, so that the docs can
+// be generated again by the same process
+func resetState() {
+	rootapi = swagger.Swagger{}
+	unresolvedRoutes = nil
+	openAPIServers = nil
 	pkgCache = make(map[string]struct{})
 	controllerComments = make(map[string]string)
 	importlist = make(map[string]string)
 	controllerList = make(map[string]map[string]*swagger.Item)
 	modelsList = make(map[string]map[string]swagger.Schema)
 	astPkgs = make([]*ast.Package, 0)
+	pathControllers = make(map[string]string)
+	operationFuncs = make(map[*swagger.Operation]string)
+	controllerOperations = make(map[string]map[string]*swagger.Operation)
+	responseOneOf = make(map[*swagger.Operation]map[string][]swagger.Schema)
+	propertyExtras = make(map[string]map[string]map[string]interface{})
 }
 
 // ParsePackagesFromDir parses packages from a given directory
@@ -139,11 +158,10 @@ func ParsePackagesFromDir(dirpath string) {
 }
 
 func parsePackageFromDir(path string) error {
-	fileSet := token.NewFileSet()
-	folderPkgs, err := parser.ParseDir(fileSet, path, func(info os.FileInfo) bool {
+	folderPkgs, err := parseDir(newFileSet(), path, func(info os.FileInfo) bool {
 		name := info.Name()
 		return !info.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
-	}, parser.ParseComments)
+	})
 	if err != nil {
 		return err
 	}
@@ -157,17 +175,79 @@ func parsePackageFromDir(path string) error {
 
 // GenerateDocs generates documentations for a given path.
 func GenerateDocs(curpath string) {
-	fset := token.NewFileSet()
+	if err := generateDocs(curpath); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+}
+
+// generateDocs does the work of GenerateDocs, returning the errors
+func generateDocs(curpath string) error {
+	if err := analyseRouter(routerEntry(curpath), curpath); err != nil {
+		return err
+	}
+
+	v, err := toGeneric(rootapi)
+	if err != nil {
+		return fmt.Errorf("could not convert the documentation: %s", err)
+	}
+	doc, _ := v.(map[string]interface{})
+	if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+		if err := applyPropertyExtras(defs, false); err != nil {
+			return err
+		}
+	}
+	return writeDocs(curpath, "swagger", doc)
+}
+
+// analyseRouter parses the router package, given as a directory or as an
+// entry file, and fills rootapi with the API information, the paths and the
+// models of the included controllers.
+func analyseRouter(routerPath, curpath string) error {
+	if err := analyseRoutes(routerPath, curpath); err != nil {
+		return err
+	}
+	for _, u := range unresolvedRoutes {
+		asanaLogger.Log.Warnf("Could not resolve the route at %s", u)
+	}
+	return nil
+}
 
-	f, err := parser.ParseFile(fset, filepath.Join(curpath, "routers", "router.go"), nil, parser.ParseComments)
+// analyseRoutes does the work of analyseRouter, leaving the routes which
+// could not be resolved in unresolvedRoutes
+func analyseRoutes(routerPath, curpath string) error {
+	fset := newFileSet()
+	files, entries, err := parseRouterPackage(fset, routerPath)
 	if err != nil {
-		asanaLogger.Log.Fatalf("Error while parsing router.go: %s", err)
+		return err
 	}
 
 	rootapi.Infos = swagger.Information{}
 	rootapi.SwaggerVersion = "2.0"
 
-	// Analyse API comments
+	for _, f := range entries {
+		if err := analyseAPIComments(f); err != nil {
+			return fmt.Errorf("%s: %s", fset.Position(f.Package).Filename, err)
+		}
+	}
+	// Analyse controller package
+	for _, f := range files {
+		for _, im := range f.Imports {
+			localName := ""
+			if im.Name != nil {
+				localName = im.Name.Name
+			}
+			if err := analyseControllerPkg(path.Join(curpath, "vendor"), localName, im.Path.Value); err != nil {
+				return err
+			}
+		}
+	}
+	newRouterAnalyser(fset, files).analyse(entries)
+	return nil
+}
+
+// analyseAPIComments fills rootapi with the API information of the
+// comments of a router file
+func analyseAPIComments(f *ast.File) error {
 	if f.Comments != nil {
 		for _, c := range f.Comments {
 			for _, s := range strings.Split(c.Text(), "\n") {
@@ -201,6 +281,13 @@ func GenerateDocs(curpath string) {
 					rootapi.Schemes = strings.Split(strings.TrimSpace(s[len("@Schemes"):]), ",")
 				} else if strings.HasPrefix(s, "@Host") {
 					rootapi.Host = strings.TrimSpace(s[len("@Host"):])
+				} else if strings.HasPrefix(s, "@Server") {
+					p := strings.SplitN(strings.TrimSpace(s[len("@Server"):]), " ", 2)
+					server := openAPIServer{URL: p[0]}
+					if len(p) > 1 {
+						server.Description = strings.Trim(p[1], `" `)
+					}
+					openAPIServers = append(openAPIServers, server)
 				} else if strings.HasPrefix(s, "@SecurityDefinition") {
 					if len(rootapi.SecurityDefinitions) == 0 {
 						rootapi.SecurityDefinitions = make(map[string]swagger.Security)
@@ -208,16 +295,16 @@ func GenerateDocs(curpath string) {
 					var out swagger.Security
 					p := getparams(strings.TrimSpace(s[len("@SecurityDefinition"):]))
 					if len(p) < 2 {
-						asanaLogger.Log.Fatalf("Not enough params for security: %d\n", len(p))
+						return fmt.Errorf("not enough params for security: %d", len(p))
 					}
 					out.Type = p[1]
 					switch out.Type {
 					case "oauth2":
 						if len(p) < 6 {
-							asanaLogger.Log.Fatalf("Not enough params for oauth2: %d\n", len(p))
+							return fmt.Errorf("not enough params for oauth2: %d", len(p))
 						}
 						if !(p[3] == "implicit" || p[3] == "password" || p[3] == "application" || p[3] == "accessCode") {
-							asanaLogger.Log.Fatalf("Unknown flow type: %s. Possible values are `implicit`, `password`, `application` or `accessCode`.\n", p[1])
+							return fmt.Errorf("unknown flow type: %s. Possible values are `implicit`, `password`, `application` or `accessCode`", p[3])
 						}
 						out.AuthorizationURL = p[2]
 						out.Flow = p[3]
@@ -230,10 +317,10 @@ func GenerateDocs(curpath string) {
 						}
 					case "apiKey":
 						if len(p) < 4 {
-							asanaLogger.Log.Fatalf("Not enough params for apiKey: %d\n", len(p))
+							return fmt.Errorf("not enough params for apiKey: %d", len(p))
 						}
 						if !(p[3] == "header" || p[3] == "query") {
-							asanaLogger.Log.Fatalf("Unknown in type: %s. Possible values are `query` or `header`.\n", p[4])
+							return fmt.Errorf("unknown in type: %s. Possible values are `query` or `header`", p[3])
 						}
 						out.Name = p[2]
 						out.In = p[3]
@@ -245,186 +332,32 @@ func GenerateDocs(curpath string) {
 							out.Description = strings.Trim(p[2], `" `)
 						}
 					default:
-						asanaLogger.Log.Fatalf("Unknown security type: %s. Possible values are `oauth2`, `apiKey` or `basic`.\n", p[1])
+						return fmt.Errorf("unknown security type: %s. Possible values are `oauth2`, `apiKey` or `basic`", p[1])
 					}
 					rootapi.SecurityDefinitions[p[0]] = out
 				} else if strings.HasPrefix(s, "@Security") {
 					if len(rootapi.Security) == 0 {
 						rootapi.Security = make([]map[string][]string, 0)
 					}
-					rootapi.Security = append(rootapi.Security, getSecurity(s))
-				}
-			}
-		}
-	}
-	// Analyse controller package
-	for _, im := range f.Imports {
-		localName := ""
-		if im.Name != nil {
-			localName = im.Name.Name
-		}
-		analyseControllerPkg(path.Join(curpath, "vendor"), localName, im.Path.Value)
-	}
-	for _, d := range f.Decls {
-		switch specDecl := d.(type) {
-		case *ast.FuncDecl:
-			for _, l := range specDecl.Body.List {
-				switch stmt := l.(type) {
-				case *ast.AssignStmt:
-					for _, l := range stmt.Rhs {
-						if v, ok := l.(*ast.CallExpr); ok {
-							// Analyze NewNamespace, it will return version and the subfunction
-							selExpr, selOK := v.Fun.(*ast.SelectorExpr)
-							if !selOK || selExpr.Sel.Name != "NewNamespace" {
-								continue
-							}
-							version, params := analyseNewNamespace(v)
-							if rootapi.BasePath == "" && version != "" {
-								rootapi.BasePath = version
-							}
-							for _, p := range params {
-								switch pp := p.(type) {
-								case *ast.CallExpr:
-									var controllerName string
-									if selname := pp.Fun.(*ast.SelectorExpr).Sel.String(); selname == "NSNamespace" {
-										s, params := analyseNewNamespace(pp)
-										for _, sp := range params {
-											switch pp := sp.(type) {
-											case *ast.CallExpr:
-												if pp.Fun.(*ast.SelectorExpr).Sel.String() == "NSInclude" {
-													controllerName = analyseNSInclude(s, pp)
-													if v, ok := controllerComments[controllerName]; ok {
-														rootapi.Tags = append(rootapi.Tags, swagger.Tag{
-															Name:        strings.Trim(s, "/"),
-															Description: v,
-														})
-													}
-												}
-											}
-										}
-									} else if selname == "NSInclude" {
-										controllerName = analyseNSInclude("", pp)
-										if v, ok := controllerComments[controllerName]; ok {
-											rootapi.Tags = append(rootapi.Tags, swagger.Tag{
-												Name:        controllerName, // if the NSInclude has no prefix, we use the controllername as the tag
-												Description: v,
-											})
-										}
-									}
-								}
-							}
-						}
-
+					security, err := getSecurity(s)
+					if err != nil {
+						return err
 					}
+					rootapi.Security = append(rootapi.Security, security)
 				}
 			}
 		}
 	}
-	_ = os.Mkdir(path.Join(curpath, "swagger"), 0755)
-	fd, err := os.Create(path.Join(curpath, "swagger", "swagger.json"))
-	if err != nil {
-		panic(err)
-	}
-	fdyml, err := os.Create(path.Join(curpath, "swagger", "swagger.yml"))
-	if err != nil {
-		panic(err)
-	}
-	defer fdyml.Close()
-	defer fd.Close()
-	dt, err := json.MarshalIndent(rootapi, "", "    ")
-	dtyml, erryml := yaml.Marshal(rootapi)
-	if err != nil || erryml != nil {
-		panic(err)
-	}
-	_, err = fd.Write(dt)
-	_, erryml = fdyml.Write(dtyml)
-	if err != nil || erryml != nil {
-		panic(err)
-	}
-}
-
-// analyseNewNamespace returns version and the others params
-func analyseNewNamespace(ce *ast.CallExpr) (first string, others []ast.Expr) {
-	for i, p := range ce.Args {
-		if i == 0 {
-			switch pp := p.(type) {
-			case *ast.BasicLit:
-				first = strings.Trim(pp.Value, `"`)
-			}
-			continue
-		}
-		others = append(others, p)
-	}
-	return
-}
-
-func analyseNSInclude(baseurl string, ce *ast.CallExpr) string {
-	cname := ""
-	for _, p := range ce.Args {
-		var x *ast.SelectorExpr
-		var p1 interface{} = p
-		if ident, ok := p1.(*ast.Ident); ok {
-			if assign, ok := ident.Obj.Decl.(*ast.AssignStmt); ok {
-				if len(assign.Rhs) > 0 {
-					p1 = assign.Rhs[0].(*ast.UnaryExpr)
-				}
-			}
-		}
-		if _, ok := p1.(*ast.UnaryExpr); ok {
-			x = p1.(*ast.UnaryExpr).X.(*ast.CompositeLit).Type.(*ast.SelectorExpr)
-		} else {
-			asanaLogger.Log.Warnf("Couldn't determine type\n")
-			continue
-		}
-		if v, ok := importlist[fmt.Sprint(x.X)]; ok {
-			cname = v + x.Sel.Name
-		}
-		if apis, ok := controllerList[cname]; ok {
-			for rt, item := range apis {
-				tag := cname
-				if baseurl != "" {
-					rt = baseurl + rt
-					tag = strings.Trim(baseurl, "/")
-				}
-				if item.Get != nil {
-					item.Get.Tags = []string{tag}
-				}
-				if item.Post != nil {
-					item.Post.Tags = []string{tag}
-				}
-				if item.Put != nil {
-					item.Put.Tags = []string{tag}
-				}
-				if item.Patch != nil {
-					item.Patch.Tags = []string{tag}
-				}
-				if item.Head != nil {
-					item.Head.Tags = []string{tag}
-				}
-				if item.Delete != nil {
-					item.Delete.Tags = []string{tag}
-				}
-				if item.Options != nil {
-					item.Options.Tags = []string{tag}
-				}
-				if len(rootapi.Paths) == 0 {
-					rootapi.Paths = make(map[string]*swagger.Item)
-				}
-				rt = urlReplace(rt)
-				rootapi.Paths[rt] = item
-			}
-		}
-	}
-	return cname
+	return nil
 }
 
-func analyseControllerPkg(vendorPath, localName, pkgpath string) {
+func analyseControllerPkg(vendorPath, localName, pkgpath string) error {
 	pkgpath = strings.Trim(pkgpath, "\"")
 	if isSystemPackage(pkgpath) {
-		return
+		return nil
 	}
 	if pkgpath == "github.com/goasana/asana" {
-		return
+		return nil
 	}
 	if localName != "" {
 		importlist[localName] = pkgpath
@@ -432,41 +365,22 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 		pps := strings.Split(pkgpath, "/")
 		importlist[pps[len(pps)-1]] = pkgpath
 	}
-	gopaths := bu.GetGOPATHs()
-	if len(gopaths) == 0 {
-		asanaLogger.Log.Fatal("GOPATH environment variable is not set or empty")
-	}
-	pkgRealpath := ""
-
-	wg, _ := filepath.EvalSymlinks(filepath.Join(vendorPath, pkgpath))
-	if utils.FileExists(wg) {
-		pkgRealpath = wg
-	} else {
-		wgopath := gopaths
-		for _, wg := range wgopath {
-			wg, _ = filepath.EvalSymlinks(filepath.Join(wg, "src", pkgpath))
-			if utils.FileExists(wg) {
-				pkgRealpath = wg
-				break
-			}
-		}
-	}
+	pkgRealpath := packageDir(vendorPath, pkgpath)
 	if pkgRealpath != "" {
 		if _, ok := pkgCache[pkgpath]; ok {
-			return
+			return nil
 		}
 		pkgCache[pkgpath] = struct{}{}
 	} else {
-		asanaLogger.Log.Fatalf("Package '%s' does not exist in the GOPATH or vendor path", pkgpath)
+		return fmt.Errorf("package '%s' does not exist in the GOPATH or vendor path", pkgpath)
 	}
 
-	fileSet := token.NewFileSet()
-	astPkgs, err := parser.ParseDir(fileSet, pkgRealpath, func(info os.FileInfo) bool {
+	astPkgs, err := parseDir(newFileSet(), pkgRealpath, func(info os.FileInfo) bool {
 		name := info.Name()
 		return !info.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
-	}, parser.ParseComments)
+	})
 	if err != nil {
-		asanaLogger.Log.Fatalf("Error while parsing dir at '%s': %s", pkgpath, err)
+		return fmt.Errorf("error while parsing dir at '%s': %s", pkgpath, err)
 	}
 	for _, pkg := range astPkgs {
 		for _, fl := range pkg.Files {
@@ -476,7 +390,9 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 					if specDecl.Recv != nil && len(specDecl.Recv.List) > 0 {
 						if t, ok := specDecl.Recv.List[0].Type.(*ast.StarExpr); ok {
 							// Parse controller method
-							_ = parserComments(specDecl, fmt.Sprint(t.X), pkgpath)
+							if err := parserComments(specDecl, fmt.Sprint(t.X), pkgpath); err != nil {
+								return err
+							}
 						}
 					}
 				case *ast.GenDecl:
@@ -496,6 +412,33 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 			}
 		}
 	}
+	return nil
+}
+
+// packageDir returns the directory of a package, looked up in the application
+// module, the vendor directory and the GOPATH. Returns "" if it is not found.
+func packageDir(vendorPath, pkgpath string) string {
+	gopaths := bu.GetGOPATHs()
+	if len(gopaths) == 0 {
+		asanaLogger.Log.Fatal("GOPATH environment variable is not set or empty")
+	}
+
+	// packages of the application module are resolved from go.mod
+	appPath := filepath.Dir(vendorPath)
+	if mod := modulePath(appPath); mod != "" && strings.HasPrefix(pkgpath, mod+"/") {
+		return filepath.Join(appPath, filepath.FromSlash(strings.TrimPrefix(pkgpath, mod+"/")))
+	}
+	wg, _ := filepath.EvalSymlinks(filepath.Join(vendorPath, pkgpath))
+	if utils.FileExists(wg) {
+		return wg
+	}
+	for _, wg := range gopaths {
+		wg, _ = filepath.EvalSymlinks(filepath.Join(wg, "src", pkgpath))
+		if utils.FileExists(wg) {
+			return wg
+		}
+	}
+	return ""
 }
 
 func isSystemPackage(pkgpath string) bool {
@@ -572,33 +515,32 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 					ss = strings.TrimSpace(ss[pos:])
 					schemaName, pos := peekNextSplitString(ss)
 					if schemaName == "" {
-						asanaLogger.Log.Fatalf("[%s.%s] Schema must follow {object} or {array}", controllerName, funcName)
+						return fmt.Errorf("[%s.%s] schema must follow {object} or {array}", controllerName, funcName)
 					}
 					if strings.HasPrefix(schemaName, "[]") {
 						schemaName = schemaName[2:]
 						isArray = true
 					}
-					schema := swagger.Schema{}
-					if sType, ok := basicTypes[schemaName]; ok {
-						typeFormat := strings.Split(sType, ":")
-						schema.Type = typeFormat[0]
-						schema.Format = typeFormat[1]
-					} else {
-						m, mod, realTypes := getModel(schemaName)
-						schema.Ref = "#/definitions/" + m
-						if _, ok := modelsList[pkgpath+controllerName]; !ok {
-							modelsList[pkgpath+controllerName] = make(map[string]swagger.Schema)
+					// alternative schemas are separated by |, e.g. models.Cat|models.Dog.
+					// Swagger 2.0 only keeps the first one, OpenAPI 3 uses oneOf.
+					var alternatives []swagger.Schema
+					for _, name := range strings.Split(schemaName, "|") {
+						schema := responseSchema(name, pkgpath, controllerName)
+						if isArray {
+							item := schema
+							schema = swagger.Schema{
+								Type:  astTypeArray,
+								Items: &item,
+							}
 						}
-						modelsList[pkgpath+controllerName][schemaName] = mod
-						appendModels(pkgpath, controllerName, realTypes)
+						alternatives = append(alternatives, schema)
 					}
-					if isArray {
-						rs.Schema = &swagger.Schema{
-							Type:  astTypeArray,
-							Items: &schema,
+					rs.Schema = &alternatives[0]
+					if len(alternatives) > 1 {
+						if _, ok := responseOneOf[&opts]; !ok {
+							responseOneOf[&opts] = make(map[string][]swagger.Schema)
 						}
-					} else {
-						rs.Schema = &schema
+						responseOneOf[&opts][respCode] = alternatives
 					}
 					rs.Description = strings.TrimSpace(ss[pos:])
 				} else {
@@ -609,7 +551,7 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 				para := swagger.Parameter{}
 				p := getparams(strings.TrimSpace(t[len("@Param "):]))
 				if len(p) < 4 {
-					asanaLogger.Log.Fatal(controllerName + "_" + funcName + "'s comments @Param should have at least 4 params")
+					return fmt.Errorf("%s_%s's comments @Param should have at least 4 params", controllerName, funcName)
 				}
 				paramNames := strings.SplitN(p[0], "=>", 2)
 				para.Name = paramNames[0]
@@ -631,10 +573,12 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 					fallthrough
 				case "formData":
 					fallthrough
+				case "cookie":
+					fallthrough
 				case "body":
 					break
 				default:
-					asanaLogger.Log.Warnf("[%s.%s] Unknown param location: %s. Possible values are `query`, `header`, `path`, `formData` or `body`.\n", controllerName, funcName, p[1])
+					asanaLogger.Log.Warnf("[%s.%s] Unknown param location: %s. Possible values are `query`, `header`, `path`, `formData`, `cookie` or `body`.\n", controllerName, funcName, p[1])
 				}
 				para.In = p[1]
 				pp := strings.Split(p[2], ".")
@@ -726,7 +670,11 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 				if len(opts.Security) == 0 {
 					opts.Security = make([]map[string][]string, 0)
 				}
-				opts.Security = append(opts.Security, getSecurity(t))
+				security, err := getSecurity(t)
+				if err != nil {
+					return fmt.Errorf("[%s.%s] %s", controllerName, funcName, err)
+				}
+				opts.Security = append(opts.Security, security)
 			}
 		}
 	}
@@ -757,28 +705,61 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 			item = &swagger.Item{}
 		}
 		for _, hm := range strings.Split(HTTPMethod, ",") {
-			switch hm {
-			case "GET":
-				item.Get = &opts
-			case "POST":
-				item.Post = &opts
-			case "PUT":
-				item.Put = &opts
-			case "PATCH":
-				item.Patch = &opts
-			case "DELETE":
-				item.Delete = &opts
-			case "HEAD":
-				item.Head = &opts
-			case "OPTIONS":
-				item.Options = &opts
-			}
+			setOperation(item, hm, &opts)
 		}
 		controllerList[pkgpath+controllerName][routerPath] = item
 	}
+	if routerPath != "" || comments != nil {
+		// keep the operation for the routes registered by asana.Router
+		operationFuncs[&opts] = funcName
+		if _, ok := controllerOperations[pkgpath+controllerName]; !ok {
+			controllerOperations[pkgpath+controllerName] = make(map[string]*swagger.Operation)
+		}
+		controllerOperations[pkgpath+controllerName][funcName] = &opts
+	}
 	return nil
 }
 
+// setOperation sets the operation of an HTTP method of a path item
+func setOperation(item *swagger.Item, method string, op *swagger.Operation) {
+	switch method {
+	case "GET":
+		item.Get = op
+	case "POST":
+		item.Post = op
+	case "PUT":
+		item.Put = op
+	case "PATCH":
+		item.Patch = op
+	case "DELETE":
+		item.Delete = op
+	case "HEAD":
+		item.Head = op
+	case "OPTIONS":
+		item.Options = op
+	}
+}
+
+// responseSchema returns the schema of a basic type or a model of a @Success
+// annotation, and registers the model of the controller
+func responseSchema(schemaName, pkgpath, controllerName string) swagger.Schema {
+	schema := swagger.Schema{}
+	if sType, ok := basicTypes[schemaName]; ok {
+		typeFormat := strings.Split(sType, ":")
+		schema.Type = typeFormat[0]
+		schema.Format = typeFormat[1]
+		return schema
+	}
+	m, mod, realTypes := getModel(schemaName)
+	schema.Ref = "#/definitions/" + m
+	if _, ok := modelsList[pkgpath+controllerName]; !ok {
+		modelsList[pkgpath+controllerName] = make(map[string]swagger.Schema)
+	}
+	modelsList[pkgpath+controllerName][schemaName] = mod
+	appendModels(pkgpath, controllerName, realTypes)
+	return schema
+}
+
 func setParamType(para *swagger.Parameter, typ string, pkgpath, controllerName string) {
 	isArray := false
 	paraType := ""
@@ -921,32 +902,36 @@ func getparams(str string) []string {
 }
 
 func getModel(str string) (definitionName string, m swagger.Schema, realTypes []string) {
+	if strings.Contains(str, "[") {
+		str = genericDefinition(str)
+	}
 	strs := strings.Split(str, ".")
 	// strs = [packageName].[objectName]
 	packageName := strs[0]
 	objectname := strs[len(strs)-1]
+	scope := &modelScope{packageName: packageName}
 
 	// Default all swagger schemas to object, if no other type is found
 	m.Type = astTypeObject
 
-L:
-	for _, pkg := range astPkgs {
-		if strs[0] == pkg.Name {
-			for _, fl := range pkg.Files {
-				for k, d := range fl.Scope.Objects {
-					if d.Kind == ast.Typ {
-						if k != objectname {
-							// Still searching for the right object
-							continue
-						}
-						parseObject(d, k, &m, &realTypes, astPkgs, packageName)
-
-						// When we've found the correct object, we can stop searching
-						break L
-					}
-				}
+	if cm, realTypes, ok := cachedSchema(str); ok {
+		return str, cm, realTypes
+	}
+	trackModelDeps()
+
+	var d *ast.Object
+	if gm, ok := genericModels[str]; ok {
+		objectname = gm.name
+		if d = findModel(gm.packageName, gm.name); d != nil {
+			if ts, ok := d.Decl.(*ast.TypeSpec); ok {
+				scope = genericScope(ts, gm)
 			}
 		}
+	} else {
+		d = findModel(packageName, objectname)
+	}
+	if d != nil {
+		parseObject(d, objectname, str, &m, &realTypes, scope)
 	}
 
 	if m.Title == "" {
@@ -961,40 +946,82 @@ L:
 		rootapi.Definitions = make(map[string]swagger.Schema)
 	}
 	rootapi.Definitions[str] = m
+	cacheSchema(str, m, realTypes)
 	return str, m, realTypes
 }
 
-func parseObject(d *ast.Object, k string, m *swagger.Schema, realTypes *[]string, astPkgs []*ast.Package, packageName string) {
+// parseObject parses the declaration of a model into m. defName is the
+// name of the definition receiving its property keywords.
+func parseObject(d *ast.Object, k, defName string, m *swagger.Schema, realTypes *[]string, scope *modelScope) {
+	// the objects of the models are types, the title is left empty for
+	// getModel to report the others as not found
 	ts, ok := d.Decl.(*ast.TypeSpec)
 	if !ok {
-		asanaLogger.Log.Fatalf("Unknown type without TypeSec: %v", d)
+		return
 	}
-	// TODO support other types, such as `MapType`, `InterfaceType` etc...
 	switch t := ts.Type.(type) {
 	case *ast.ArrayType:
 		m.Title = k
-		m.Type = astTypeArray
-		if isBasicType(fmt.Sprint(t.Elt)) {
-			typeFormat := strings.Split(basicTypes[fmt.Sprint(t.Elt)], ":")
-			m.Format = typeFormat[0]
-		} else {
-			objectName := packageName + "." + fmt.Sprint(t.Elt)
-			if _, ok := rootapi.Definitions[objectName]; !ok {
-				objectName, _, _ = getModel(objectName)
-			}
-			m.Items = &swagger.Schema{
-				Ref: "#/definitions/" + objectName,
-			}
-		}
+		p := scope.propertySchema(t, realTypes)
+		m.Type = p.Type
+		m.Format = p.Format
+		m.Items = propertySchema(p.Items)
+	case *ast.MapType:
+		m.Title = k
+		m.Type = astTypeObject
+		// converted with the definitions by applyPropertyExtras
+		setPropertyExtra(defName, "", "additionalProperties", scope.propertySchema(t.Value, realTypes))
 	case *ast.Ident:
-		parseIdent(t, k, m, astPkgs)
+		if _, ok := basicTypes[t.Name]; ok {
+			parseIdent(t, k, defName, m, scope)
+			return
+		}
+		parseAlias(t, k, defName, m, realTypes, scope)
+	case *ast.SelectorExpr:
+		if _, ok := basicTypes[scope.typeName(t)]; ok {
+			parseIdent(&ast.Ident{Name: scope.typeName(t)}, k, defName, m, scope)
+			return
+		}
+		parseAlias(t, k, defName, m, realTypes, scope)
+	case *ast.StarExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		parseAlias(t, k, defName, m, realTypes, scope)
 	case *ast.StructType:
-		parseStruct(t, k, m, realTypes, astPkgs, packageName)
+		parseStruct(t, k, defName, m, realTypes, scope)
+	case *ast.InterfaceType:
+		m.Title = k
+	}
+}
+
+// parseAlias parses a model declared from another one, e.g. type A = B,
+// type A other.B or type A = Page[B], as the model it refers to
+func parseAlias(expr ast.Expr, k, defName string, m *swagger.Schema, realTypes *[]string, scope *modelScope) {
+	obj, aliasScope := resolveModel(expr, scope)
+	if obj == nil {
+		// not a model of the parsed packages, refer to its definition
+		m.Title = k
+		m.Type = ""
+		m.Ref = scope.propertySchema(expr, realTypes).Ref
+		return
+	}
+	parseObject(obj, obj.Name, defName, m, realTypes, aliasScope)
+	m.Title = k
+}
+
+// propertySchema converts the schema of a property
+func propertySchema(p *swagger.Propertie) *swagger.Schema {
+	if p == nil {
+		return nil
+	}
+	return &swagger.Schema{
+		Ref:    p.Ref,
+		Type:   p.Type,
+		Format: p.Format,
+		Items:  propertySchema(p.Items),
 	}
 }
 
 // parse as enum, in the package, find out all consts with the same type
-func parseIdent(st *ast.Ident, k string, m *swagger.Schema, astPkgs []*ast.Package) {
+func parseIdent(st *ast.Ident, k, defName string, m *swagger.Schema, scope *modelScope) {
 	m.Title = k
 	basicType := fmt.Sprint(st)
 	if object, isStdLibObject := stdlibObject[basicType]; isStdLibObject {
@@ -1005,264 +1032,97 @@ func parseIdent(st *ast.Ident, k string, m *swagger.Schema, astPkgs []*ast.Packa
 		m.Type = typeFormat[0]
 		m.Format = typeFormat[1]
 	}
-	enums := make(map[int]string)
-	enumValues := make(map[int]interface{})
-	for _, pkg := range astPkgs {
-		for _, fl := range pkg.Files {
-			for _, obj := range fl.Scope.Objects {
-				if obj.Kind == ast.Con {
-					vs, ok := obj.Decl.(*ast.ValueSpec)
-					if !ok {
-						asanaLogger.Log.Fatalf("Unknown type without ValueSpec: %v", vs)
-					}
-
-					ti, ok := vs.Type.(*ast.Ident)
-					if !ok {
-						// TODO type inference, iota not support yet
-						continue
-					}
-					// Only add the enums that are defined by the current identifier
-					if ti.Name != k {
-						continue
-					}
-
-					// For all names and values, aggregate them by it's position so that we can sort them later.
-					for i, val := range vs.Values {
-						v, ok := val.(*ast.BasicLit)
-						if !ok {
-							asanaLogger.Log.Warnf("Unknown type without BasicLit: %v", v)
-							continue
-						}
-						enums[int(val.Pos())] = fmt.Sprintf("%s = %s", vs.Names[i].Name, v.Value)
-						switch v.Kind {
-						case token.INT:
-							vv, err := strconv.Atoi(v.Value)
-							if err != nil {
-								asanaLogger.Log.Warnf("Unknown type with BasicLit to int: %v", v.Value)
-								continue
-							}
-							enumValues[int(val.Pos())] = vv
-						case token.FLOAT:
-							vv, err := strconv.ParseFloat(v.Value, 64)
-							if err != nil {
-								asanaLogger.Log.Warnf("Unknown type with BasicLit to int: %v", v.Value)
-								continue
-							}
-							enumValues[int(val.Pos())] = vv
-						default:
-							enumValues[int(val.Pos())] = strings.Trim(v.Value, `"`)
-						}
-
-					}
-				}
-			}
-		}
-	}
-	// Sort the enums by position
-	if len(enums) > 0 {
-		var keys []int
-		for k := range enums {
-			keys = append(keys, k)
-		}
-		sort.Ints(keys)
-		for _, k := range keys {
-			m.Enum = append(m.Enum, enums[k])
-		}
+	names, values := enumValues(scope.packageName, k)
+	if len(values) > 0 {
+		m.Enum = values
+		setPropertyExtra(defName, "", "x-enum-varnames", names)
 		// Automatically use the first enum value as the example.
-		m.Example = enumValues[keys[0]]
+		m.Example = values[0]
 	}
-
 }
 
-func parseStruct(st *ast.StructType, k string, m *swagger.Schema, realTypes *[]string, astPkgs []*ast.Package, packageName string) {
+// parseStruct parses the fields of a struct into the properties of m. The
+// fields are required when their required tag is true or their validate tag
+// has the required rule; json omitempty alone leaves them optional.
+func parseStruct(st *ast.StructType, k, defName string, m *swagger.Schema, realTypes *[]string, scope *modelScope) {
 	m.Title = k
-	if st.Fields.List != nil {
+	if st.Fields.List == nil {
+		return
+	}
+	if m.Properties == nil {
 		m.Properties = make(map[string]swagger.Propertie)
-		for _, field := range st.Fields.List {
-			isSlice, realType, sType := typeAnalyser(field)
-			if (isSlice && isBasicType(realType)) || sType == astTypeObject {
-				if len(strings.Split(realType, " ")) > 1 {
-					realType = strings.Replace(realType, " ", ".", -1)
-					realType = strings.Replace(realType, "&", "", -1)
-					realType = strings.Replace(realType, "{", "", -1)
-					realType = strings.Replace(realType, "}", "", -1)
-				} else {
-					realType = packageName + "." + realType
-				}
-			}
-			*realTypes = append(*realTypes, realType)
-			mp := swagger.Propertie{}
-			isObject := false
-			if isSlice {
-				mp.Type = astTypeArray
-				if t, ok := basicTypes[(strings.Replace(realType, "[]", "", -1))]; ok {
-					typeFormat := strings.Split(t, ":")
-					mp.Items = &swagger.Propertie{
-						Type:   typeFormat[0],
-						Format: typeFormat[1],
-					}
-				} else {
-					mp.Items = &swagger.Propertie{
-						Ref: "#/definitions/" + realType,
-					}
-				}
-			} else {
-				if sType == astTypeObject {
-					isObject = true
-					mp.Ref = "#/definitions/" + realType
-				} else if isBasicType(realType) {
-					typeFormat := strings.Split(sType, ":")
-					mp.Type = typeFormat[0]
-					mp.Format = typeFormat[1]
-				} else if realType == astTypeMap {
-					typeFormat := strings.Split(sType, ":")
-					mp.AdditionalProperties = &swagger.Propertie{
-						Type:   typeFormat[0],
-						Format: typeFormat[1],
-					}
-				}
-			}
-			if field.Names != nil {
-
-				// set property name as field name
-				var name = field.Names[0].Name
-
-				// if no tag skip tag processing
-				if field.Tag == nil {
-					m.Properties[name] = mp
-					continue
-				}
-
-				var tagValues []string
-
-				stag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
-
-				defaultValue := stag.Get("doc")
-				if defaultValue != "" {
-					r, _ := regexp.Compile(`default\((.*)\)`)
-					if r.MatchString(defaultValue) {
-						res := r.FindStringSubmatch(defaultValue)
-						mp.Default = str2RealType(res[1], realType)
+	}
+	for _, field := range st.Fields.List {
+		var stag reflect.StructTag
+		if field.Tag != nil {
+			stag = reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		}
+		tagValues := strings.Split(stag.Get("json"), ",")
 
-					} else {
-						asanaLogger.Log.Warnf("Invalid default value: %s", defaultValue)
-					}
-				}
+		// dont add property if json tag first value is "-"
+		if tagValues[0] == "-" || stag.Get("ignore") != "" {
+			continue
+		}
+		if field.Names == nil && tagValues[0] == "" {
+			// if no json tag, expand all fields of the embedded type here
+			parseEmbedded(field.Type, defName, m, realTypes, scope)
+			continue
+		}
 
-				tag := stag.Get("json")
-				if tag != "" {
-					tagValues = strings.Split(tag, ",")
-				}
+		var name string
+		if field.Names != nil {
+			// set property name as field name
+			name = field.Names[0].Name
+			if !ast.IsExported(name) {
+				continue
+			}
+		}
+		// set property name to the left most json tag value
+		if tagValues[0] != "" {
+			name = tagValues[0]
+		}
+		if thrifttag := stag.Get("thrift"); thrifttag != "" {
+			ts := strings.Split(thrifttag, ",")
+			if ts[0] != "" {
+				name = ts[0]
+			}
+		}
 
-				// dont add property if json tag first value is "-"
-				if len(tagValues) == 0 || tagValues[0] != "-" {
+		mp := scope.propertySchema(field.Type, realTypes)
+		realType := scope.typeName(field.Type)
+		isObject := mp.Ref != "" || mp.Type == astTypeObject || mp.Type == astTypeArray
 
-					// set property name to the left most json tag value only if is not omitempty
-					if len(tagValues) > 0 && tagValues[0] != "omitempty" {
-						name = tagValues[0]
-					}
+		defaultValue := stag.Get("doc")
+		if defaultValue != "" {
+			r, _ := regexp.Compile(`default\((.*)\)`)
+			if r.MatchString(defaultValue) {
+				res := r.FindStringSubmatch(defaultValue)
+				mp.Default = str2RealType(res[1], realType)
 
-					if thrifttag := stag.Get("thrift"); thrifttag != "" {
-						ts := strings.Split(thrifttag, ",")
-						if ts[0] != "" {
-							name = ts[0]
-						}
-					}
-					if required := stag.Get("required"); required != "" {
-						m.Required = append(m.Required, name)
-					}
-					if desc := stag.Get("description"); desc != "" {
-						mp.Description = desc
-					}
-
-					if example := stag.Get("example"); example != "" && !isObject && !isSlice {
-						mp.Example = str2RealType(example, realType)
-					}
-
-					m.Properties[name] = mp
-				}
-				if ignore := stag.Get("ignore"); ignore != "" {
-					continue
-				}
 			} else {
-				// only parse case of when embedded field is TypeName
-				// cases of *TypeName and Interface are not handled, maybe useless for swagger spec
-				tag := ""
-				if field.Tag != nil {
-					stag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
-					tag = stag.Get("json")
-				}
-
-				if tag != "" {
-					tagValues := strings.Split(tag, ",")
-					if tagValues[0] == "-" {
-						//if json tag is "-", omit
-						continue
-					} else {
-						//if json tag is "something", output: something #definition/pkgname.Type
-						m.Properties[tagValues[0]] = mp
-						continue
-					}
-				} else {
-					//if no json tag, expand all fields of the type here
-					nm := &swagger.Schema{}
-					for _, pkg := range astPkgs {
-						for _, fl := range pkg.Files {
-							for nameOfObj, obj := range fl.Scope.Objects {
-								if obj.Name == fmt.Sprint(field.Type) {
-									parseObject(obj, nameOfObj, nm, realTypes, astPkgs, pkg.Name)
-								}
-							}
-						}
-					}
-					for name, p := range nm.Properties {
-						m.Properties[name] = p
-					}
-					continue
-				}
+				asanaLogger.Log.Warnf("Invalid default value: %s", defaultValue)
 			}
 		}
-	}
-}
-
-func typeAnalyser(f *ast.Field) (isSlice bool, realType, swaggerType string) {
-	if arr, ok := f.Type.(*ast.ArrayType); ok {
-		if isBasicType(fmt.Sprint(arr.Elt)) {
-			return true, fmt.Sprintf("[]%v", arr.Elt), basicTypes[fmt.Sprint(arr.Elt)]
-		}
-		if mp, ok := arr.Elt.(*ast.MapType); ok {
-			return false, fmt.Sprintf("map[%v][%v]", mp.Key, mp.Value), astTypeObject
+		if desc := stag.Get("description"); desc != "" {
+			mp.Description = desc
 		}
-		if star, ok := arr.Elt.(*ast.StarExpr); ok {
-			return true, fmt.Sprint(star.X), astTypeObject
+		if example := stag.Get("example"); example != "" && !isObject {
+			mp.Example = str2RealType(example, realType)
 		}
-		return true, fmt.Sprint(arr.Elt), astTypeObject
-	}
-	switch t := f.Type.(type) {
-	case *ast.StarExpr:
-		basicType := fmt.Sprint(t.X)
-		if object, isStdLibObject := stdlibObject[basicType]; isStdLibObject {
-			basicType = object
+
+		required, _ := strconv.ParseBool(stag.Get("required"))
+		if validate := stag.Get("validate"); validate != "" {
+			required = parseValidateTag(validate, defName, name, realType, &mp) || required
 		}
-		if k, ok := basicTypes[basicType]; ok {
-			return false, basicType, k
+		if required {
+			addRequired(m, name)
 		}
-		return false, basicType, astTypeObject
-	case *ast.MapType:
-		val := fmt.Sprintf("%v", t.Value)
-		if isBasicType(val) {
-			return false, astTypeMap, basicTypes[val]
+
+		m.Properties[name] = mp
+		if _, nullable := field.Type.(*ast.StarExpr); nullable {
+			setPropertyExtra(defName, name, "nullable", true)
 		}
-		return false, val, astTypeObject
 	}
-	basicType := fmt.Sprint(f.Type)
-	if object, isStdLibObject := stdlibObject[basicType]; isStdLibObject {
-		basicType = object
-	}
-	if k, ok := basicTypes[basicType]; ok {
-		return false, basicType, k
-	}
-	return false, basicType, astTypeObject
 }
 
 func isBasicType(Type string) bool {
@@ -1287,17 +1147,17 @@ func appendModels(pkgpath, controllerName string, realTypes []string) {
 	}
 }
 
-func getSecurity(t string) (security map[string][]string) {
-	security = make(map[string][]string)
+func getSecurity(t string) (map[string][]string, error) {
+	security := make(map[string][]string)
 	p := getparams(strings.TrimSpace(t[len("@Security"):]))
 	if len(p) == 0 {
-		asanaLogger.Log.Fatalf("No params for security specified\n")
+		return nil, errors.New("no params for security specified")
 	}
 	security[p[0]] = make([]string, 0)
 	for i := 1; i < len(p); i++ {
 		security[p[0]] = append(security[p[0]], p[i])
 	}
-	return
+	return security, nil
 }
 
 func urlReplace(src string) string {
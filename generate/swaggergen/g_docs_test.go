@@ -0,0 +1,152 @@
+package swaggergen
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParserCommentsArrayResponse(t *testing.T) {
+	resetState()
+	defer resetState()
+
+	src := `package controllers
+
+// @Title List
+// @Success 200 {array} string the names
+// @router /names [get]
+func (c *NameController) List() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "names.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := f.Decls[0].(*ast.FuncDecl)
+	if err := parserComments(fn, "NameController", "controllers"); err != nil {
+		t.Fatal(err)
+	}
+
+	op := controllerOperations["controllersNameController"]["List"]
+	if op == nil {
+		t.Fatal("the operation of List is missing")
+	}
+	schema := op.Responses["200"].Schema
+	if schema == nil || schema.Type != astTypeArray || schema.Items == nil {
+		t.Fatalf("the response is not an array: %+v", schema)
+	}
+	if schema.Items == schema || schema.Items.Type != "string" {
+		t.Fatalf("the items of the array are not strings: %+v", schema.Items)
+	}
+
+	b, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Responses map[string]struct {
+			Schema struct {
+				Type  string `json:"type"`
+				Items struct {
+					Type string `json:"type"`
+				} `json:"items"`
+			} `json:"schema"`
+		} `json:"responses"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.Responses["200"].Schema; s.Type != "array" || s.Items.Type != "string" {
+		t.Fatalf("unexpected response schema: %s", b)
+	}
+}
+
+func TestParserCommentsErrors(t *testing.T) {
+	tests := []struct {
+		annotation string
+		want       string
+	}{
+		{"@Success 200 {object}", "[NameController.List] schema must follow {object} or {array}"},
+		{"@Param name query string", "NameController_List's comments @Param should have at least 4 params"},
+		{"@Security", "[NameController.List] no params for security specified"},
+	}
+	for _, tt := range tests {
+		resetState()
+		src := "package controllers\n\n// " + tt.annotation + "\n// @router /names [get]\nfunc (c *NameController) List() {}\n"
+		f, err := parser.ParseFile(token.NewFileSet(), "names.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = parserComments(f.Decls[0].(*ast.FuncDecl), "NameController", "controllers")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got error %v, want %q", tt.annotation, err, tt.want)
+		}
+	}
+	resetState()
+}
+
+func TestAnalyseAPICommentsErrors(t *testing.T) {
+	tests := []struct {
+		annotation string
+		want       string
+	}{
+		{"@SecurityDefinition key", "not enough params for security: 1"},
+		{"@SecurityDefinition key oauth2 https://example.com/auth implicit", "not enough params for oauth2: 4"},
+		{"@SecurityDefinition key oauth2 https://example.com/auth device read \"Read\"", "unknown flow type: device. Possible values are `implicit`, `password`, `application` or `accessCode`"},
+		{"@SecurityDefinition key apiKey X-Key", "not enough params for apiKey: 3"},
+		{"@SecurityDefinition key apiKey X-Key cookie", "unknown in type: cookie. Possible values are `query` or `header`"},
+		{"@SecurityDefinition key digest", "unknown security type: digest. Possible values are `oauth2`, `apiKey` or `basic`"},
+		{"@Security", "no params for security specified"},
+	}
+	for _, tt := range tests {
+		resetState()
+		src := "// " + tt.annotation + "\npackage routers\n"
+		f, err := parser.ParseFile(token.NewFileSet(), "router.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = analyseAPIComments(f)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got error %v, want %q", tt.annotation, err, tt.want)
+		}
+	}
+	resetState()
+
+	f, err := parser.ParseFile(token.NewFileSet(), "router.go", "// @SecurityDefinition key apiKey X-Key header\npackage routers\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := analyseAPIComments(f); err != nil {
+		t.Fatal(err)
+	}
+	if s := rootapi.SecurityDefinitions["key"]; s.Type != "apiKey" || s.Name != "X-Key" || s.In != "header" {
+		t.Errorf("unexpected security definition: %+v", s)
+	}
+	resetState()
+}
+
+func TestParseRouterPackageErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, _, err := parseRouterPackage(token.NewFileSet(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("no error for a missing router")
+	}
+	if _, _, err := parseRouterPackage(token.NewFileSet(), dir); err == nil || !strings.HasPrefix(err.Error(), "no router file found") {
+		t.Errorf("got error %v for a router package without files", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "router.go"), []byte("package routers\n\nfunc init() {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := parseRouterPackage(token.NewFileSet(), dir); err == nil || !strings.HasPrefix(err.Error(), "error while parsing router at") {
+		t.Errorf("got error %v for an invalid router", err)
+	}
+}
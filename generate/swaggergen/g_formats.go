@@ -0,0 +1,644 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goasana/asana/swagger"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The formats the docs can be written in. Besides the Swagger or OpenAPI
+// document, the same rootapi can be rendered as a Markdown reference with a
+// page per namespace, and as a Postman or Insomnia collection.
+const (
+	formatJSON     = "json"
+	formatYAML     = "yaml"
+	formatMarkdown = "markdown"
+	formatPostman  = "postman"
+	formatInsomnia = "insomnia"
+)
+
+var docsFormats = []string{formatJSON, formatYAML, formatMarkdown, formatPostman, formatInsomnia}
+
+// docsOutput is where and in which formats the docs are written
+var docsOutput = struct {
+	dir     string
+	formats map[string]bool
+}{
+	dir:     "swagger",
+	formats: map[string]bool{formatJSON: true, formatYAML: true},
+}
+
+// SetDocsOutput sets the directory the docs are written to, relative to
+// the application, and the formats separated by commas: json, yaml,
+// markdown, postman or insomnia. Empty values keep json and yaml in swagger.
+func SetDocsOutput(dir, formats string) error {
+	if dir != "" {
+		docsOutput.dir = dir
+	}
+	if formats == "" {
+		return nil
+	}
+	docsOutput.formats = make(map[string]bool)
+	for _, f := range strings.Split(formats, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "yml" || f == "md" {
+			f = map[string]string{"yml": formatYAML, "md": formatMarkdown}[f]
+		}
+		known := false
+		for _, df := range docsFormats {
+			known = known || f == df
+		}
+		if !known {
+			return fmt.Errorf("unknown docs format '%s', must be one of %s", f, strings.Join(docsFormats, ", "))
+		}
+		docsOutput.formats[f] = true
+	}
+	return nil
+}
+
+// writeDocs writes the document, named e.g. swagger or openapi, and the
+// renderings of rootapi in the formats of docsOutput
+func writeDocs(curpath, name string, doc interface{}) error {
+	dir := docsOutput.dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(curpath, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create '%s': %s", dir, err)
+	}
+
+	files := make(map[string][]byte)
+	if docsOutput.formats[formatJSON] {
+		dt, err := json.MarshalIndent(doc, "", "    ")
+		if err != nil {
+			return fmt.Errorf("could not marshal the docs: %s", err)
+		}
+		files[name+".json"] = dt
+	}
+	if docsOutput.formats[formatYAML] {
+		dt, err := yaml.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("could not marshal the docs: %s", err)
+		}
+		files[name+".yml"] = dt
+	}
+	if docsOutput.formats[formatMarkdown] {
+		for fname, content := range markdownPages() {
+			files[filepath.Join("markdown", fname)] = content
+		}
+	}
+	if docsOutput.formats[formatPostman] {
+		dt, err := json.MarshalIndent(postmanCollection(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal the collection: %s", err)
+		}
+		files["postman_collection.json"] = dt
+	}
+	if docsOutput.formats[formatInsomnia] {
+		dt, err := json.MarshalIndent(insomniaExport(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal the collection: %s", err)
+		}
+		files["insomnia.json"] = dt
+	}
+
+	for fname, content := range files {
+		fpath := filepath.Join(dir, fname)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return fmt.Errorf("could not create '%s': %s", filepath.Dir(fpath), err)
+		}
+		if err := writeFile(fpath, content); err != nil {
+			return fmt.Errorf("could not write '%s': %s", fpath, err)
+		}
+	}
+	return nil
+}
+
+// docsOperation is an operation of rootapi with its path
+type docsOperation struct {
+	path   string
+	method string
+	op     *swagger.Operation
+}
+
+// namespaces returns the operations of rootapi grouped by tag, the tags
+// sorted by name
+func namespaces() ([]string, map[string][]docsOperation) {
+	var paths []string
+	for p := range rootapi.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	groups := make(map[string][]docsOperation)
+	var tags []string
+	for _, p := range paths {
+		for _, mo := range itemOperations(rootapi.Paths[p]) {
+			tag := "default"
+			if len(mo.op.Tags) > 0 && mo.op.Tags[0] != "" {
+				tag = mo.op.Tags[0]
+			}
+			if _, ok := groups[tag]; !ok {
+				tags = append(tags, tag)
+			}
+			groups[tag] = append(groups[tag], docsOperation{path: p, method: mo.method, op: mo.op})
+		}
+	}
+	sort.Strings(tags)
+	return tags, groups
+}
+
+func tagDescription(tag string) string {
+	for _, t := range rootapi.Tags {
+		if t.Name == tag {
+			return t.Description
+		}
+	}
+	return ""
+}
+
+// operationName returns the name of an operation in a collection
+func operationName(o docsOperation) string {
+	if o.op.Summary != "" {
+		return o.op.Summary
+	}
+	return o.method + " " + o.path
+}
+
+// baseURL returns the URL the API is served at, from the host, the schemes
+// and the base path of the annotations
+func baseURL() string {
+	host := rootapi.Host
+	if host == "" {
+		host = "localhost:8080"
+	}
+	scheme := "http"
+	if len(rootapi.Schemes) > 0 {
+		scheme = rootapi.Schemes[0]
+	}
+	return scheme + "://" + host + strings.TrimRight(rootapi.BasePath, "/")
+}
+
+func exampleJSON(schema *swagger.Schema) string {
+	dt, _ := json.MarshalIndent(exampleFromSchema(schema, 0), "", "  ")
+	return string(dt)
+}
+
+// pageName returns the file name of the Markdown page of a namespace
+func pageName(tag string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, strings.Trim(tag, "/"))
+	if name == "" {
+		name = "default"
+	}
+	return name + ".md"
+}
+
+// mdAnchor returns the anchor of a heading as rendered by GitHub
+func mdAnchor(heading string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			return unicode.ToLower(r)
+		case r == ' ':
+			return '-'
+		}
+		return -1
+	}, heading)
+}
+
+// mdCell escapes a text for a cell of a Markdown table
+func mdCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func mdModelLink(ref string) string {
+	name := strings.TrimPrefix(ref, "#/definitions/")
+	return "[" + name + "](models.md#" + mdAnchor(name) + ")"
+}
+
+func mdSchemaType(s *swagger.Schema) string {
+	if s == nil {
+		return ""
+	}
+	if s.Ref != "" {
+		return mdModelLink(s.Ref)
+	}
+	if s.Type == astTypeArray {
+		return mdSchemaType(s.Items) + "[]"
+	}
+	return mdType(s.Type, s.Format)
+}
+
+func mdPropertieType(p *swagger.Propertie) string {
+	if p == nil {
+		return ""
+	}
+	if p.Ref != "" {
+		return mdModelLink(p.Ref)
+	}
+	switch {
+	case p.Type == astTypeArray:
+		return mdPropertieType(p.Items) + "[]"
+	case p.AdditionalProperties != nil:
+		return "map[string]" + mdPropertieType(p.AdditionalProperties)
+	}
+	return mdType(p.Type, p.Format)
+}
+
+func mdType(typ, format string) string {
+	if typ == "" {
+		typ = astTypeObject
+	}
+	if format != "" {
+		return typ + " (" + format + ")"
+	}
+	return typ
+}
+
+// markdownPages renders rootapi as Markdown: an index, a page per namespace
+// and a page of the models
+func markdownPages() map[string][]byte {
+	pages := make(map[string][]byte)
+	tags, groups := namespaces()
+
+	var index bytes.Buffer
+	title := rootapi.Infos.Title
+	if title == "" {
+		title = "API reference"
+	}
+	fmt.Fprintf(&index, "# %s\n\n", title)
+	if rootapi.Infos.Version != "" {
+		fmt.Fprintf(&index, "Version: %s\n\n", rootapi.Infos.Version)
+	}
+	if rootapi.Infos.Description != "" {
+		fmt.Fprintf(&index, "%s\n\n", rootapi.Infos.Description)
+	}
+	fmt.Fprintf(&index, "Base URL: `%s`\n\n## Namespaces\n\n", baseURL())
+	for _, tag := range tags {
+		fmt.Fprintf(&index, "- [%s](%s)", tag, pageName(tag))
+		if desc := tagDescription(tag); desc != "" {
+			fmt.Fprintf(&index, ": %s", mdCell(desc))
+		}
+		index.WriteString("\n")
+	}
+	if len(rootapi.Definitions) > 0 {
+		index.WriteString("\n[Models](models.md)\n")
+	}
+	pages["README.md"] = index.Bytes()
+
+	for _, tag := range tags {
+		var page bytes.Buffer
+		fmt.Fprintf(&page, "# %s\n\n", tag)
+		if desc := tagDescription(tag); desc != "" {
+			fmt.Fprintf(&page, "%s\n\n", desc)
+		}
+		for _, o := range groups[tag] {
+			markdownOperation(&page, o)
+		}
+		pages[pageName(tag)] = page.Bytes()
+	}
+
+	if len(rootapi.Definitions) > 0 {
+		pages["models.md"] = markdownModels()
+	}
+	return pages
+}
+
+func markdownOperation(page *bytes.Buffer, o docsOperation) {
+	fmt.Fprintf(page, "## %s %s\n\n", o.method, strings.TrimRight(rootapi.BasePath, "/")+o.path)
+	if o.op.Deprecated {
+		page.WriteString("**Deprecated**\n\n")
+	}
+	if o.op.Summary != "" {
+		fmt.Fprintf(page, "%s\n\n", o.op.Summary)
+	}
+	if o.op.Description != "" && o.op.Description != o.op.Summary {
+		fmt.Fprintf(page, "%s\n\n", o.op.Description)
+	}
+
+	var body *swagger.Parameter
+	var params []swagger.Parameter
+	for i, para := range o.op.Parameters {
+		if para.In == "body" {
+			body = &o.op.Parameters[i]
+		} else {
+			params = append(params, para)
+		}
+	}
+	if len(params) > 0 {
+		page.WriteString("### Parameters\n\n| Name | In | Type | Required | Description |\n| --- | --- | --- | --- | --- |\n")
+		for _, para := range params {
+			typ := mdType(para.Type, para.Format)
+			if para.Type == astTypeArray && para.Items != nil {
+				typ = mdType(para.Items.Type, para.Items.Format) + "[]"
+			}
+			if para.Schema != nil {
+				typ = mdSchemaType(para.Schema)
+			}
+			fmt.Fprintf(page, "| %s | %s | %s | %s | %s |\n", para.Name, para.In, typ, yesNo(para.Required || para.In == "path"), mdCell(para.Description))
+		}
+		page.WriteString("\n")
+	}
+	if body != nil {
+		fmt.Fprintf(page, "### Request body\n\n%s", mdSchemaType(body.Schema))
+		if body.Description != "" {
+			fmt.Fprintf(page, ": %s", mdCell(body.Description))
+		}
+		fmt.Fprintf(page, "\n\n```json\n%s\n```\n\n", exampleJSON(body.Schema))
+	}
+
+	if len(o.op.Responses) > 0 {
+		var codes []string
+		for code := range o.op.Responses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		page.WriteString("### Responses\n\n| Status | Description | Type |\n| --- | --- | --- |\n")
+		for _, code := range codes {
+			rs := o.op.Responses[code]
+			fmt.Fprintf(page, "| %s | %s | %s |\n", code, mdCell(rs.Description), mdSchemaType(rs.Schema))
+		}
+		page.WriteString("\n")
+		if rs, ok := o.op.Responses[strconv.Itoa(successStatus(o.op))]; ok && rs.Schema != nil {
+			fmt.Fprintf(page, "```json\n%s\n```\n\n", exampleJSON(rs.Schema))
+		}
+	}
+}
+
+func markdownModels() []byte {
+	var names []string
+	for name := range rootapi.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var page bytes.Buffer
+	page.WriteString("# Models\n")
+	for _, name := range names {
+		def := rootapi.Definitions[name]
+		fmt.Fprintf(&page, "\n## %s\n\n", name)
+		if def.Description != "" {
+			fmt.Fprintf(&page, "%s\n\n", def.Description)
+		}
+		if len(def.Enum) > 0 {
+			var values []string
+			for _, v := range def.Enum {
+				values = append(values, fmt.Sprintf("`%v`", v))
+			}
+			fmt.Fprintf(&page, "%s, one of %s\n", mdSchemaType(&def), strings.Join(values, ", "))
+			continue
+		}
+		if len(def.Properties) == 0 {
+			fmt.Fprintf(&page, "%s\n", mdSchemaType(&def))
+			continue
+		}
+		required := make(map[string]bool)
+		for _, r := range def.Required {
+			required[r] = true
+		}
+		var fields []string
+		for field := range def.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		page.WriteString("| Field | Type | Required | Description |\n| --- | --- | --- | --- |\n")
+		for _, field := range fields {
+			p := def.Properties[field]
+			fmt.Fprintf(&page, "| %s | %s | %s | %s |\n", field, mdPropertieType(&p), yesNo(required[field]), mdCell(p.Description))
+		}
+	}
+	return page.Bytes()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// collectionRequest is a request of a collection, with example values
+type collectionRequest struct {
+	name        string
+	method      string
+	path        string // with the example values of the path params
+	pathParams  [][2]string
+	query       [][2]string
+	headers     [][2]string
+	form        [][2]string
+	body        string
+	description string
+}
+
+func newCollectionRequest(o docsOperation) collectionRequest {
+	r := collectionRequest{
+		name:        operationName(o),
+		method:      o.method,
+		path:        o.path,
+		description: o.op.Description,
+	}
+	for _, para := range o.op.Parameters {
+		v := paramExample(para)
+		switch para.In {
+		case "path":
+			r.pathParams = append(r.pathParams, [2]string{para.Name, v})
+			r.path = strings.Replace(r.path, "{"+para.Name+"}", v, -1)
+		case "query":
+			r.query = append(r.query, [2]string{para.Name, v})
+		case "header":
+			r.headers = append(r.headers, [2]string{para.Name, v})
+		case "formData":
+			r.form = append(r.form, [2]string{para.Name, v})
+		case "body":
+			r.body = exampleJSON(para.Schema)
+		}
+	}
+	return r
+}
+
+// postmanCollection renders rootapi as a Postman collection v2.1, with a
+// folder per namespace and a baseUrl variable
+func postmanCollection() map[string]interface{} {
+	tags, groups := namespaces()
+	var folders []interface{}
+	for _, tag := range tags {
+		var items []interface{}
+		for _, o := range groups[tag] {
+			r := newCollectionRequest(o)
+			path := o.path
+			for _, p := range r.pathParams {
+				path = strings.Replace(path, "{"+p[0]+"}", ":"+p[0], -1)
+			}
+			url := map[string]interface{}{
+				"raw":  "{{baseUrl}}" + path,
+				"host": []string{"{{baseUrl}}"},
+				"path": strings.Split(strings.Trim(path, "/"), "/"),
+			}
+			if len(r.query) > 0 {
+				url["query"] = keyValues(r.query, "key")
+				url["raw"] = url["raw"].(string) + "?" + rawQuery(r.query)
+			}
+			if len(r.pathParams) > 0 {
+				url["variable"] = keyValues(r.pathParams, "key")
+			}
+			request := map[string]interface{}{
+				"method": r.method,
+				"header": keyValues(r.headers, "key"),
+				"url":    url,
+			}
+			if r.description != "" {
+				request["description"] = r.description
+			}
+			switch {
+			case r.body != "":
+				request["header"] = append(request["header"].([]map[string]string), map[string]string{"key": "Content-Type", "value": "application/json"})
+				request["body"] = map[string]interface{}{
+					"mode":    "raw",
+					"raw":     r.body,
+					"options": map[string]interface{}{"raw": map[string]string{"language": "json"}},
+				}
+			case len(r.form) > 0:
+				request["body"] = map[string]interface{}{
+					"mode":       "urlencoded",
+					"urlencoded": keyValues(r.form, "key"),
+				}
+			}
+			items = append(items, map[string]interface{}{"name": r.name, "request": request})
+		}
+		folder := map[string]interface{}{"name": tag, "item": items}
+		if desc := tagDescription(tag); desc != "" {
+			folder["description"] = desc
+		}
+		folders = append(folders, folder)
+	}
+
+	info := map[string]interface{}{
+		"name":   collectionName(),
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json",
+	}
+	if rootapi.Infos.Description != "" {
+		info["description"] = rootapi.Infos.Description
+	}
+	return map[string]interface{}{
+		"info":     info,
+		"item":     folders,
+		"variable": []map[string]string{{"key": "baseUrl", "value": baseURL()}},
+	}
+}
+
+// insomniaExport renders rootapi as an Insomnia export, with a folder per
+// namespace and a base_url environment variable
+func insomniaExport() map[string]interface{} {
+	const workspaceID = "wrk_asanacli"
+	resources := []interface{}{
+		map[string]interface{}{
+			"_id":         workspaceID,
+			"_type":       "workspace",
+			"name":        collectionName(),
+			"description": rootapi.Infos.Description,
+		},
+		map[string]interface{}{
+			"_id":      "env_asanacli",
+			"_type":    "environment",
+			"parentId": workspaceID,
+			"name":     "Base Environment",
+			"data":     map[string]string{"base_url": baseURL()},
+		},
+	}
+
+	tags, groups := namespaces()
+	n := 0
+	for i, tag := range tags {
+		folderID := fmt.Sprintf("fld_%d", i+1)
+		resources = append(resources, map[string]interface{}{
+			"_id":         folderID,
+			"_type":       "request_group",
+			"parentId":    workspaceID,
+			"name":        tag,
+			"description": tagDescription(tag),
+		})
+		for _, o := range groups[tag] {
+			n++
+			r := newCollectionRequest(o)
+			request := map[string]interface{}{
+				"_id":         fmt.Sprintf("req_%d", n),
+				"_type":       "request",
+				"parentId":    folderID,
+				"name":        r.name,
+				"description": r.description,
+				"method":      r.method,
+				"url":         "{{ _.base_url }}" + r.path,
+				"parameters":  keyValues(r.query, "name"),
+				"headers":     keyValues(r.headers, "name"),
+				"body":        map[string]interface{}{},
+			}
+			switch {
+			case r.body != "":
+				request["body"] = map[string]interface{}{"mimeType": "application/json", "text": r.body}
+				request["headers"] = append(request["headers"].([]map[string]string), map[string]string{"name": "Content-Type", "value": "application/json"})
+			case len(r.form) > 0:
+				request["body"] = map[string]interface{}{"mimeType": "application/x-www-form-urlencoded", "params": keyValues(r.form, "name")}
+			}
+			resources = append(resources, request)
+		}
+	}
+	return map[string]interface{}{
+		"_type":           "export",
+		"__export_format": 4,
+		"__export_source": "asanacli",
+		"resources":       resources,
+	}
+}
+
+func collectionName() string {
+	if rootapi.Infos.Title != "" {
+		return rootapi.Infos.Title
+	}
+	return "API"
+}
+
+// keyValues returns the pairs as objects with a value and a key named key
+func keyValues(pairs [][2]string, key string) []map[string]string {
+	list := []map[string]string{}
+	for _, p := range pairs {
+		list = append(list, map[string]string{key: p[0], "value": p[1]})
+	}
+	return list
+}
+
+func rawQuery(pairs [][2]string) string {
+	var parts []string
+	for _, p := range pairs {
+		parts = append(parts, p[0]+"="+p[1])
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,445 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goasana/asana/swagger"
+)
+
+// LintIssue is a problem found in the documentation annotations
+type LintIssue struct {
+	Pos     token.Position
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Pos, i.Message)
+}
+
+// methodAnnotations are the annotations of the controller methods
+var methodAnnotations = map[string]bool{
+	"@router":      true,
+	"@Title":       true,
+	"@Description": true,
+	"@Summary":     true,
+	"@Success":     true,
+	"@Failure":     true,
+	"@Param":       true,
+	"@Deprecated":  true,
+	"@Accept":      true,
+	"@Security":    true,
+}
+
+var paramLocations = map[string]bool{
+	"query":    true,
+	"header":   true,
+	"path":     true,
+	"formData": true,
+	"cookie":   true,
+	"body":     true,
+}
+
+var acceptValues = map[string]bool{
+	"json":  true,
+	"xml":   true,
+	"plain": true,
+	"html":  true,
+	"form":  true,
+}
+
+var httpMethods = map[string]bool{
+	"GET":     true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"HEAD":    true,
+	"OPTIONS": true,
+}
+
+// unroutedMethod is an annotated method without @router, which is only
+// fine when a route of the router package maps it
+type unroutedMethod struct {
+	pos      token.Pos
+	key      string // package path and controller name, as in controllerOperations
+	funcName string
+}
+
+type linter struct {
+	fset         *token.FileSet
+	issues       []LintIssue
+	fatals       int // issues which make the docs generation fail
+	operationIDs map[string]token.Position
+	unrouted     []unroutedMethod
+}
+
+// LintDocs checks the annotations of the controllers imported by the router
+// package, then the routes registered by it. The routes can only be resolved
+// from valid annotations, so they are checked once the annotations have no
+// issue. The issues are sorted by position.
+func LintDocs(curpath string) []LintIssue {
+	l := lintAnnotations(curpath)
+	if len(l.issues) == 0 {
+		if err := analyseRoutes(routerEntry(curpath), curpath); err != nil {
+			l.issues = append(l.issues, LintIssue{Message: err.Error()})
+		} else {
+			l.issues = append(l.issues, unresolvedRoutes...)
+			l.lintUnrouted()
+		}
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i].Pos, l.issues[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	return l.issues
+}
+
+// lintAnnotations checks the annotations of the controllers imported by
+// the router package
+func lintAnnotations(curpath string) *linter {
+	l := &linter{
+		fset:         newFileSet(),
+		operationIDs: make(map[string]token.Position),
+	}
+	// the models are looked up in the packages of the application, which
+	// are only parsed beforehand by generate docs inside the GOPATH
+	if len(astPkgs) == 0 {
+		ParsePackagesFromDir(curpath)
+	}
+	files, _, err := parseRouterPackage(l.fset, routerEntry(curpath))
+	if err != nil {
+		l.fatal(token.NoPos, "%s", err)
+		return l
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		for _, im := range f.Imports {
+			pkgpath := strings.Trim(im.Path.Value, "\"")
+			if seen[pkgpath] || isSystemPackage(pkgpath) || pkgpath == "github.com/goasana/asana" {
+				continue
+			}
+			seen[pkgpath] = true
+			dir := packageDir(path.Join(curpath, "vendor"), pkgpath)
+			if dir == "" {
+				l.fatal(im.Pos(), "package %s does not exist in the GOPATH or vendor path", pkgpath)
+				continue
+			}
+			l.lintPackage(pkgpath, dir)
+		}
+	}
+	return l
+}
+
+func (l *linter) report(pos token.Pos, format string, a ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Pos: l.fset.Position(pos), Message: fmt.Sprintf(format, a...)})
+}
+
+// fatal reports an issue on which the docs generation stops
+func (l *linter) fatal(pos token.Pos, format string, a ...interface{}) {
+	l.fatals++
+	l.report(pos, format, a...)
+}
+
+// lintPackage checks the annotated methods of the controllers of a package
+func (l *linter) lintPackage(pkgpath, dir string) {
+	pkgs, err := parseDir(l.fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !info.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
+	})
+	if err != nil {
+		l.fatals++
+		l.issues = append(l.issues, LintIssue{Pos: token.Position{Filename: dir}, Message: err.Error()})
+		return
+	}
+	for _, pkg := range pkgs {
+		for _, fl := range pkg.Files {
+			for _, d := range fl.Decls {
+				fd, ok := d.(*ast.FuncDecl)
+				if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 {
+					continue
+				}
+				if t, ok := fd.Recv.List[0].Type.(*ast.StarExpr); ok {
+					l.lintMethod(fd, fmt.Sprint(t.X), pkgpath)
+				}
+			}
+		}
+	}
+}
+
+// lintMethod checks the annotations of a controller method, as parsed by
+// parserComments
+func (l *linter) lintMethod(f *ast.FuncDecl, controllerName, pkgpath string) {
+	if f.Doc == nil {
+		return
+	}
+	funcParams := buildParamMap(f.Type.Params)
+	var route string
+	var routePos token.Pos
+	annotated := false
+	documented := make(map[string]bool)
+	pathParams := make(map[string]token.Pos)
+	for _, c := range f.Doc.List {
+		t := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(t, "@") {
+			continue
+		}
+		tag, pos := peekNextSplitString(t)
+		value := strings.TrimSpace(t[pos:])
+		if !methodAnnotations[tag] {
+			l.report(c.Pos(), "unknown annotation %s", tag)
+			continue
+		}
+		annotated = true
+		switch tag {
+		case "@router":
+			route, routePos = l.lintRouter(c.Pos(), value), c.Pos()
+		case "@Title":
+			if value == "" {
+				l.report(c.Pos(), "@Title has no value")
+				break
+			}
+			id := controllerName + "." + value
+			if first, ok := l.operationIDs[id]; ok {
+				l.report(c.Pos(), "duplicate operation ID %s, first declared at %s", id, first)
+			} else {
+				l.operationIDs[id] = l.fset.Position(c.Pos())
+			}
+		case "@Success":
+			l.lintSuccess(c.Pos(), value)
+		case "@Failure":
+			if code, _ := peekNextSplitString(value); !isResponseCode(code) {
+				l.report(c.Pos(), "@Failure has an invalid status code %q", code)
+			}
+		case "@Param":
+			name, in := l.lintParam(c.Pos(), value)
+			if name == "" {
+				break
+			}
+			if documented[name] {
+				l.report(c.Pos(), "param %s is documented more than once", name)
+			}
+			documented[name] = true
+			if in == "path" {
+				pathParams[name] = c.Pos()
+			}
+		case "@Deprecated":
+			if _, err := strconv.ParseBool(value); value != "" && err != nil {
+				l.report(c.Pos(), "@Deprecated should be true or false, got %q", value)
+			}
+		case "@Accept":
+			for _, a := range strings.Split(value, ",") {
+				if !acceptValues[strings.TrimSpace(a)] {
+					l.report(c.Pos(), "unknown @Accept value %q. Possible values are json, xml, plain, html or form", a)
+				}
+			}
+		case "@Security":
+			if value == "" {
+				l.report(c.Pos(), "@Security has no security definition name")
+			}
+		}
+	}
+	if !annotated {
+		return
+	}
+	if route == "" {
+		l.unrouted = append(l.unrouted, unroutedMethod{pos: f.Pos(), key: pkgpath + controllerName, funcName: f.Name.Name})
+		return
+	}
+	for name, pos := range pathParams {
+		if !paramInPath(name, route) {
+			l.report(pos, "path param %s is not in the route %s", name, route)
+		}
+	}
+	for _, name := range routeParams(route) {
+		if _, isFuncParam := funcParams[name]; !documented[name] && !isFuncParam {
+			l.report(routePos, "route param :%s has no @Param", name)
+		}
+	}
+}
+
+// lintRouter checks a @router annotation and returns its path
+func (l *linter) lintRouter(pos token.Pos, value string) string {
+	e := strings.SplitN(value, " ", 2)
+	if e[0] == "" {
+		l.report(pos, "@router has no path")
+		return ""
+	}
+	if !strings.HasPrefix(e[0], "/") {
+		l.report(pos, "@router path %s should start with /", e[0])
+	}
+	if len(e) == 2 && strings.TrimSpace(e[1]) != "" {
+		methods, _ := peekNextSplitString(strings.TrimSpace(e[1]))
+		if !strings.HasPrefix(methods, "[") || !strings.HasSuffix(methods, "]") {
+			l.report(pos, "@router methods should be written as [get,post], got %s", methods)
+		}
+		for _, m := range strings.Split(strings.Trim(methods, "[]"), ",") {
+			if !httpMethods[strings.ToUpper(strings.TrimSpace(m))] {
+				l.report(pos, "unknown HTTP method %q in @router", m)
+			}
+		}
+	}
+	return e[0]
+}
+
+// lintSuccess checks a @Success annotation
+func (l *linter) lintSuccess(pos token.Pos, value string) {
+	code, p := peekNextSplitString(value)
+	if !isResponseCode(code) {
+		l.report(pos, "@Success has an invalid status code %q", code)
+	}
+	value = strings.TrimSpace(value[p:])
+	respType, p := peekNextSplitString(value)
+	if respType != "{object}" && respType != "{array}" {
+		if strings.HasPrefix(respType, "{") {
+			l.report(pos, "unknown @Success type %s. Possible values are {object} or {array}", respType)
+		}
+		return
+	}
+	schemaName, _ := peekNextSplitString(strings.TrimSpace(value[p:]))
+	if schemaName == "" {
+		l.fatal(pos, "schema must follow %s", respType)
+		return
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(schemaName, "[]"), "|") {
+		if _, ok := basicTypes[name]; !ok && !modelExists(name) {
+			l.report(pos, "cannot find the model %s", name)
+		}
+	}
+}
+
+// lintParam checks a @Param annotation and returns the name and the
+// location of the param, or "" if it is malformed
+func (l *linter) lintParam(pos token.Pos, value string) (name, in string) {
+	p := getparams(value)
+	if len(p) < 4 {
+		l.fatal(pos, "@Param should have at least 4 params: name, location, type and description")
+		return "", ""
+	}
+	if len(p) > 6 {
+		l.report(pos, "@Param should have at most 6 params")
+	}
+	name = strings.SplitN(p[0], "=>", 2)[0]
+	in = p[1]
+	if !paramLocations[in] {
+		l.report(pos, "unknown param location %s. Possible values are query, header, path, formData, cookie or body", in)
+	}
+	typ := strings.TrimPrefix(p[2], "[]")
+	switch {
+	case typ == "string" || typ == "number" || typ == "integer" || typ == "boolean" ||
+		typ == astTypeArray || typ == "file" || typ == "auto":
+	case basicTypes[typ] != "":
+	case strings.Contains(typ, "."):
+		if !modelExists(typ) {
+			l.report(pos, "cannot find the model %s", typ)
+		}
+	default:
+		l.report(pos, "unknown param type %s", p[2])
+	}
+	var required string
+	switch len(p) {
+	case 5:
+		required = p[3]
+	case 6:
+		required = p[4]
+	}
+	if required != "" {
+		if _, err := strconv.ParseBool(required); err != nil {
+			l.report(pos, "@Param required should be true or false, got %q", required)
+		}
+	}
+	return name, in
+}
+
+// lintUnrouted reports the annotated methods without @router which are not
+// mapped by a route either
+func (l *linter) lintUnrouted() {
+	routed := make(map[*swagger.Operation]bool)
+	for _, item := range rootapi.Paths {
+		for _, mo := range itemOperations(item) {
+			routed[mo.op] = true
+		}
+	}
+	for _, m := range l.unrouted {
+		if op, ok := controllerOperations[m.key][m.funcName]; !ok || !routed[op] {
+			l.report(m.pos, "method %s is annotated but has no @router and no route maps it", m.funcName)
+		}
+	}
+}
+
+// modelExists reports whether a model, e.g. models.User, is declared in the
+// parsed packages
+func modelExists(name string) bool {
+	if i := strings.Index(name, "["); i >= 0 {
+		// generic model, e.g. models.Page[models.User]
+		name = name[:i]
+	}
+	strs := strings.Split(name, ".")
+	objectName := strs[len(strs)-1]
+	for _, pkg := range astPkgs {
+		if pkg.Name != strs[0] {
+			continue
+		}
+		for _, fl := range pkg.Files {
+			if d, ok := fl.Scope.Objects[objectName]; ok && d.Kind == ast.Typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeParams returns the names of the params of a route, e.g. id for
+// /:id:int or /:id([0-9]+)
+func routeParams(route string) []string {
+	var names []string
+	for _, seg := range strings.Split(route, "/") {
+		if !strings.HasPrefix(seg, ":") {
+			continue
+		}
+		name := seg[1:]
+		if i := strings.IndexFunc(name, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		}); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isResponseCode(code string) bool {
+	if code == "default" {
+		return true
+	}
+	n, err := strconv.Atoi(code)
+	return err == nil && n >= 100 && n < 600
+}
@@ -0,0 +1,222 @@
+package swaggergen
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const lintModels = `package models
+
+type User struct {
+	Name string
+}
+`
+
+func TestLintMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string // methods of UserController
+		issues []string
+		fatals int
+	}{
+		{
+			name: "valid annotations",
+			src: `
+// @Title Get
+// @Description get a user
+// @Param id path int true "The id"
+// @Param fields query string false "The fields"
+// @Success 200 {object} models.User
+// @Failure 404 not found
+// @Accept json,xml
+// @Deprecated false
+// @Security api_key
+// @router /:id [get]
+func (c *UserController) Get() {}
+
+// @Title List
+// @Success 200 {array} models.User
+// @router / [get,head]
+func (c *UserController) List() {}
+
+// @router /:name/:id [put]
+func (c *UserController) Put(name string, id int) {}
+
+// Helper is not annotated
+func (c *UserController) Helper() {}
+`,
+		},
+		{
+			name: "annotations",
+			src: `
+// @Titel Get
+// @Title
+// @Deprecated yes
+// @Accept json,yaml
+// @Security
+// @router /
+func (c *UserController) Get() {}
+`,
+			issues: []string{
+				"unknown annotation @Titel",
+				"@Title has no value",
+				`@Deprecated should be true or false, got "yes"`,
+				`unknown @Accept value "yaml". Possible values are json, xml, plain, html or form`,
+				"@Security has no security definition name",
+			},
+		},
+		{
+			name: "duplicate operation IDs",
+			src: `
+// @Title Get
+// @router /a [get]
+func (c *UserController) GetA() {}
+
+// @Title Get
+// @router /b [get]
+func (c *UserController) GetB() {}
+`,
+			issues: []string{"duplicate operation ID UserController.Get, first declared at controllers.go:3:1"},
+		},
+		{
+			name: "responses",
+			src: `
+// @Success 2000 {object} models.User
+// @Success 200 {map} models.User
+// @Success 200 {object} models.Group
+// @Success 200 {array} models.User|models.Role
+// @Failure oops not found
+// @router / [get]
+func (c *UserController) Get() {}
+
+// @Success 200 {object}
+// @router /all [get]
+func (c *UserController) All() {}
+`,
+			issues: []string{
+				`@Success has an invalid status code "2000"`,
+				"unknown @Success type {map}. Possible values are {object} or {array}",
+				"cannot find the model models.Group",
+				"cannot find the model models.Role",
+				`@Failure has an invalid status code "oops"`,
+				"schema must follow {object}",
+			},
+			fatals: 1,
+		},
+		{
+			name: "params",
+			src: `
+// @Param id path
+// @Param name cookies string true "The name"
+// @Param body body models.Group true "The group"
+// @Param age query age true "The age"
+// @Param limit query int maybe "The limit"
+// @Param page query int false "The page" 1 "more"
+// @Param page query int false "The page"
+// @router / [get]
+func (c *UserController) Get() {}
+`,
+			issues: []string{
+				"@Param should have at least 4 params: name, location, type and description",
+				"unknown param location cookies. Possible values are query, header, path, formData, cookie or body",
+				"cannot find the model models.Group",
+				"unknown param type age",
+				`@Param required should be true or false, got "maybe"`,
+				"@Param should have at most 6 params",
+				"param page is documented more than once",
+			},
+			fatals: 1,
+		},
+		{
+			name: "routes",
+			src: `
+// @Param id path int true "The id"
+// @Param name path string true "The name"
+// @router /:id/:group [get]
+func (c *UserController) Get() {}
+
+// @router users [fetch]
+func (c *UserController) Fetch() {}
+
+// @router /users get
+func (c *UserController) List() {}
+
+// @router
+func (c *UserController) Empty() {}
+`,
+			issues: []string{
+				"path param name is not in the route /:id/:group",
+				"route param :group has no @Param",
+				"@router path users should start with /",
+				`unknown HTTP method "fetch" in @router`,
+				"@router methods should be written as [get,post], got get",
+				"@router has no path",
+				"method Empty is annotated but has no @router and no route maps it",
+			},
+		},
+		{
+			name: "unrouted",
+			src: `
+// @Title Unrouted
+func (c *UserController) Unrouted() {}
+`,
+			issues: []string{"method Unrouted is annotated but has no @router and no route maps it"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState()
+			defer resetState()
+
+			fset := token.NewFileSet()
+			models, err := parser.ParseFile(fset, "user.go", lintModels, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			astPkgs = []*ast.Package{{Name: "models", Files: map[string]*ast.File{"user.go": models}}}
+			f, err := parser.ParseFile(fset, "controllers.go", "package controllers\n"+tt.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l := &linter{fset: fset, operationIDs: make(map[string]token.Position)}
+			for _, d := range f.Decls {
+				l.lintMethod(d.(*ast.FuncDecl), "UserController", "controllers")
+			}
+			l.lintUnrouted()
+
+			var issues []string
+			for _, issue := range l.issues {
+				issues = append(issues, issue.Message)
+			}
+			if !reflect.DeepEqual(issues, tt.issues) {
+				t.Errorf("issues:\n%q\nwant:\n%q", issues, tt.issues)
+			}
+			if l.fatals != tt.fatals {
+				t.Errorf("%d fatal issue(s), want %d", l.fatals, tt.fatals)
+			}
+		})
+	}
+}
+
+func TestRouteParams(t *testing.T) {
+	tests := []struct {
+		route string
+		want  []string
+	}{
+		{"/", nil},
+		{"/:id", []string{"id"}},
+		{"/:id:int/:name", []string{"id", "name"}},
+		{"/:id([0-9]+)/posts/:post_id", []string{"id", "post_id"}},
+		{"/users/*", nil},
+	}
+	for _, tt := range tests {
+		if got := routeParams(tt.route); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("routeParams(%q) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asana/swagger"
+)
+
+// modelScope is the context in which the field types of a model are
+// resolved: the package declaring the model and, for an instantiated
+// generic model, the arguments of its type parameters
+type modelScope struct {
+	packageName string
+	typeArgs    map[string]typeArg
+}
+
+// typeArg is a type argument, resolved in the scope it is written in
+type typeArg struct {
+	expr  ast.Expr
+	scope *modelScope
+}
+
+// genericModel is an instantiated generic model, e.g. models.Page[models.User]
+type genericModel struct {
+	packageName string
+	name        string
+	args        []typeArg
+}
+
+var genericModels map[string]genericModel //definition name:instantiated generic model
+
+var defNameReplacer = strings.NewReplacer("[]", "array_", "*", "", "map[string]", "map_", "[", "_", "]", "", ",", "_", " ", "")
+
+// findModel returns the declaration of a type of the parsed packages
+func findModel(packageName, name string) *ast.Object {
+	dependOn(packageName)
+	for _, pkg := range astPkgs {
+		if pkg.Name != packageName {
+			continue
+		}
+		for _, fl := range pkg.Files {
+			if d, ok := fl.Scope.Objects[name]; ok && d.Kind == ast.Typ {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// typeName returns the Go type of an expression, qualified by its package
+// and with the type parameters replaced by their arguments
+func (s *modelScope) typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := s.typeArgs[t.Name]; ok {
+			return arg.scope.typeName(arg.expr)
+		}
+		if isBasicType(t.Name) || t.Name == "any" || t.Name == "error" {
+			return t.Name
+		}
+		return s.packageName + "." + t.Name
+	case *ast.SelectorExpr:
+		return fmt.Sprintf("%v.%s", t.X, t.Sel.Name)
+	case *ast.StarExpr:
+		return s.typeName(t.X)
+	case *ast.ArrayType:
+		return "[]" + s.typeName(t.Elt)
+	case *ast.MapType:
+		return "map[" + s.typeName(t.Key) + "]" + s.typeName(t.Value)
+	case *ast.IndexExpr:
+		return s.typeName(t.X) + "[" + s.typeName(t.Index) + "]"
+	case *ast.IndexListExpr:
+		var args []string
+		for _, a := range t.Indices {
+			args = append(args, s.typeName(a))
+		}
+		return s.typeName(t.X) + "[" + strings.Join(args, ",") + "]"
+	case *ast.InterfaceType:
+		return "any"
+	}
+	return fmt.Sprint(expr)
+}
+
+// propertySchema returns the schema of a field type. The models it refers
+// to are added to realTypes, so that their definitions get generated.
+func (s *modelScope) propertySchema(expr ast.Expr, realTypes *[]string) swagger.Propertie {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := s.typeArgs[t.Name]; ok {
+			return arg.scope.propertySchema(arg.expr, realTypes)
+		}
+		if sType, ok := basicTypes[t.Name]; ok {
+			typeFormat := strings.Split(sType, ":")
+			return swagger.Propertie{Type: typeFormat[0], Format: typeFormat[1]}
+		}
+		switch t.Name {
+		case "any":
+			return swagger.Propertie{}
+		case "error":
+			return swagger.Propertie{Type: "string"}
+		}
+		return s.modelRef(s.packageName+"."+t.Name, realTypes)
+	case *ast.SelectorExpr:
+		name := s.typeName(t)
+		if sType, ok := basicTypes[name]; ok {
+			typeFormat := strings.Split(sType, ":")
+			return swagger.Propertie{Type: typeFormat[0], Format: typeFormat[1]}
+		}
+		return s.modelRef(name, realTypes)
+	case *ast.StarExpr:
+		return s.propertySchema(t.X, realTypes)
+	case *ast.ArrayType:
+		if elt, ok := t.Elt.(*ast.Ident); ok && elt.Name == "byte" {
+			// encoding/json writes []byte as a base64 string
+			return swagger.Propertie{Type: "string", Format: "byte"}
+		}
+		items := s.propertySchema(t.Elt, realTypes)
+		return swagger.Propertie{Type: astTypeArray, Items: &items}
+	case *ast.MapType:
+		values := s.propertySchema(t.Value, realTypes)
+		return swagger.Propertie{Type: astTypeObject, AdditionalProperties: &values}
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return s.modelRef(s.instantiate(t), realTypes)
+	case *ast.StructType:
+		m := swagger.Schema{}
+		parseStruct(t, "", "", &m, realTypes, s)
+		return swagger.Propertie{Type: astTypeObject, Properties: m.Properties, Required: m.Required}
+	}
+	return swagger.Propertie{Type: astTypeObject}
+}
+
+func (s *modelScope) modelRef(name string, realTypes *[]string) swagger.Propertie {
+	*realTypes = append(*realTypes, name)
+	return swagger.Propertie{Ref: "#/definitions/" + name}
+}
+
+// instantiate registers the instantiation of a generic model and returns
+// the name of its definition, e.g. models.Page_models.User
+func (s *modelScope) instantiate(expr ast.Expr) string {
+	var base ast.Expr
+	var indices []ast.Expr
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		base, indices = t.X, []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		base, indices = t.X, t.Indices
+	}
+	gm := genericModel{packageName: s.packageName}
+	switch b := base.(type) {
+	case *ast.Ident:
+		gm.name = b.Name
+	case *ast.SelectorExpr:
+		gm.packageName, gm.name = fmt.Sprint(b.X), b.Sel.Name
+	}
+	var args []string
+	for _, index := range indices {
+		gm.args = append(gm.args, typeArg{expr: index, scope: s})
+		args = append(args, s.typeName(index))
+	}
+	defName := gm.packageName + "." + gm.name + "_" + defNameReplacer.Replace(strings.Join(args, ","))
+	genericModels[defName] = gm
+	return defName
+}
+
+// genericDefinition returns the definition name of a generic model written
+// in an annotation, e.g. models.Page[models.User]
+func genericDefinition(str string) string {
+	expr, err := parser.ParseExpr(str)
+	if err != nil {
+		return str
+	}
+	switch expr.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// the arguments are written with their package, only the
+		// unqualified basic types are left to resolve
+		return (&modelScope{}).instantiate(expr)
+	}
+	return str
+}
+
+// genericScope returns the scope of an instantiated generic model, binding
+// its type parameters to the type arguments
+func genericScope(ts *ast.TypeSpec, gm genericModel) *modelScope {
+	scope := &modelScope{packageName: gm.packageName, typeArgs: make(map[string]typeArg)}
+	if ts.TypeParams == nil {
+		return scope
+	}
+	i := 0
+	for _, field := range ts.TypeParams.List {
+		for _, name := range field.Names {
+			if i < len(gm.args) {
+				scope.typeArgs[name.Name] = gm.args[i]
+			}
+			i++
+		}
+	}
+	return scope
+}
+
+// resolveModel returns the declaration of the model a type refers to,
+// which may be declared in another package or be a generic instantiation,
+// and the scope in which its fields are resolved
+func resolveModel(expr ast.Expr, scope *modelScope) (*ast.Object, *modelScope) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := scope.typeArgs[t.Name]; ok {
+			return resolveModel(arg.expr, arg.scope)
+		}
+		return findModel(scope.packageName, t.Name), &modelScope{packageName: scope.packageName}
+	case *ast.SelectorExpr:
+		packageName := fmt.Sprint(t.X)
+		return findModel(packageName, t.Sel.Name), &modelScope{packageName: packageName}
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		gm := genericModels[scope.instantiate(t)]
+		obj := findModel(gm.packageName, gm.name)
+		if obj == nil {
+			return nil, nil
+		}
+		if ts, ok := obj.Decl.(*ast.TypeSpec); ok {
+			return obj, genericScope(ts, gm)
+		}
+	}
+	return nil, nil
+}
+
+// parseEmbedded adds to m the fields promoted by an embedded struct
+func parseEmbedded(expr ast.Expr, defName string, m *swagger.Schema, realTypes *[]string, scope *modelScope) {
+	obj, embeddedScope := resolveModel(expr, scope)
+	if obj == nil {
+		// e.g. the controllers of the framework, which are not parsed
+		return
+	}
+	nm := &swagger.Schema{}
+	parseObject(obj, obj.Name, defName, nm, realTypes, embeddedScope)
+	for name, p := range nm.Properties {
+		if _, ok := m.Properties[name]; !ok {
+			m.Properties[name] = p
+		}
+	}
+	for _, name := range nm.Required {
+		addRequired(m, name)
+	}
+}
+
+func addRequired(m *swagger.Schema, name string) {
+	for _, r := range m.Required {
+		if r == name {
+			return
+		}
+	}
+	m.Required = append(m.Required, name)
+}
+
+// enumValues returns the names and the values of the constants declared
+// with a named type in its package, in the order of declaration. The
+// implicit repetition of the const blocks and iota are supported.
+func enumValues(packageName, typeName string) (names []string, values []interface{}) {
+	type enumValue struct {
+		pos   token.Pos
+		name  string
+		value interface{}
+	}
+	var enums []enumValue
+	dependOn(packageName)
+	for _, pkg := range astPkgs {
+		if pkg.Name != packageName {
+			continue
+		}
+		for _, fl := range pkg.Files {
+			for _, d := range fl.Decls {
+				gd, ok := d.(*ast.GenDecl)
+				if !ok || gd.Tok != token.CONST {
+					continue
+				}
+				var typ ast.Expr
+				var exprs []ast.Expr
+				for iota, spec := range gd.Specs {
+					vs, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					if vs.Type != nil || len(vs.Values) > 0 {
+						typ, exprs = vs.Type, vs.Values
+					}
+					if ti, ok := typ.(*ast.Ident); !ok || ti.Name != typeName {
+						continue
+					}
+					for i, name := range vs.Names {
+						if i >= len(exprs) || name.Name == "_" {
+							continue
+						}
+						if v, ok := constValue(exprs[i], iota); ok {
+							enums = append(enums, enumValue{name.Pos(), name.Name, v})
+						}
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(enums, func(i, j int) bool { return enums[i].pos < enums[j].pos })
+	for _, e := range enums {
+		names = append(names, e.name)
+		values = append(values, e.value)
+	}
+	return
+}
+
+// constValue evaluates the value of a constant: a literal, iota or a
+// simple integer expression of them, such as iota + 1 or 1 << iota
+func constValue(expr ast.Expr, iota int) (interface{}, bool) {
+	switch v := expr.(type) {
+	case *ast.BasicLit:
+		switch v.Kind {
+		case token.INT:
+			i, err := strconv.ParseInt(v.Value, 0, 64)
+			return int(i), err == nil
+		case token.FLOAT:
+			f, err := strconv.ParseFloat(v.Value, 64)
+			return f, err == nil
+		case token.STRING:
+			s, err := strconv.Unquote(v.Value)
+			return s, err == nil
+		}
+	case *ast.Ident:
+		if v.Name == "iota" {
+			return iota, true
+		}
+	case *ast.ParenExpr:
+		return constValue(v.X, iota)
+	case *ast.BinaryExpr:
+		x, okX := constValue(v.X, iota)
+		y, okY := constValue(v.Y, iota)
+		a, isIntX := x.(int)
+		b, isIntY := y.(int)
+		if !okX || !okY || !isIntX || !isIntY {
+			return nil, false
+		}
+		switch v.Op {
+		case token.ADD:
+			return a + b, true
+		case token.SUB:
+			return a - b, true
+		case token.MUL:
+			return a * b, true
+		case token.SHL:
+			return a << uint(b), true
+		}
+	}
+	return nil, false
+}
+
+// validateFormats maps the validate rules to a string format
+var validateFormats = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"hostname": "hostname",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"datetime": "date-time",
+}
+
+// parseValidateTag applies the rules of a validate tag to a property: the
+// bounds become minLength/maxLength, minItems/maxItems or minimum/maximum
+// depending on its type, oneof becomes an enum. Returns whether the field
+// is required. The rules of the elements, after dive, are ignored.
+func parseValidateTag(tag, defName, name, goType string, mp *swagger.Propertie) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		kv := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		arg := ""
+		if len(kv) == 2 {
+			arg = kv[1]
+		}
+		switch kv[0] {
+		case "dive":
+			return
+		case "required":
+			required = true
+		case "min", "gte":
+			setBound(defName, name, mp, "min", arg, false)
+		case "gt":
+			setBound(defName, name, mp, "min", arg, true)
+		case "max", "lte":
+			setBound(defName, name, mp, "max", arg, false)
+		case "lt":
+			setBound(defName, name, mp, "max", arg, true)
+		case "len":
+			setBound(defName, name, mp, "min", arg, false)
+			setBound(defName, name, mp, "max", arg, false)
+		case "oneof":
+			var enum []interface{}
+			for _, v := range strings.Fields(arg) {
+				enum = append(enum, str2RealType(strings.Trim(v, "'"), goType))
+			}
+			setPropertyExtra(defName, name, "enum", enum)
+		default:
+			if format, ok := validateFormats[kv[0]]; ok && mp.Type == "string" && mp.Format == "" {
+				mp.Format = format
+			}
+		}
+	}
+	return
+}
+
+// setBound records the lower or upper bound of a property, as the keyword
+// matching its type
+func setBound(defName, name string, mp *swagger.Propertie, bound, arg string, exclusive bool) {
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+	keyword := ""
+	switch mp.Type {
+	case "string":
+		keyword = bound + "Length"
+	case astTypeArray:
+		keyword = bound + "Items"
+	case astTypeObject:
+		if mp.AdditionalProperties != nil {
+			keyword = bound + "Properties"
+		}
+	case "integer", "number":
+		keyword = map[string]string{"min": "minimum", "max": "maximum"}[bound]
+		if exclusive {
+			setPropertyExtra(defName, name, "exclusive"+strings.Title(keyword), true)
+		}
+	}
+	if keyword == "" {
+		return
+	}
+	if exclusive && mp.Type != "integer" && mp.Type != "number" {
+		// gt and lt count the elements strictly
+		if bound == "min" {
+			v++
+		} else {
+			v--
+		}
+	}
+	setPropertyExtra(defName, name, keyword, v)
+}
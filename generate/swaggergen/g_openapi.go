@@ -0,0 +1,444 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asana/swagger"
+	asanaLogger "github.com/goasana/asanacli/logger"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	aurlencoded    = "application/x-www-form-urlencoded"
+)
+
+// openAPIServer is a server of the @Server annotations
+type openAPIServer struct {
+	URL         string `json:"url" yaml:"url"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// GenerateOpenAPI generates an OpenAPI 3 documentation for a given path.
+// The annotations are parsed as for GenerateDocs, then the Swagger 2.0
+// document is converted.
+func GenerateOpenAPI(curpath string) {
+	if err := analyseRouter(routerEntry(curpath), curpath); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+
+	doc, err := openAPIDocument()
+	if err != nil {
+		asanaLogger.Log.Fatalf("Could not convert the documentation: %s", err)
+	}
+	if err := writeDocs(curpath, "openapi", doc); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+}
+
+func writeFile(fpath string, content []byte) error {
+	fd, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	_, err = fd.Write(content)
+	return err
+}
+
+// openAPIDocument converts rootapi to an OpenAPI 3 document
+func openAPIDocument() (map[string]interface{}, error) {
+	v, err := toGeneric(rootapi)
+	if err != nil {
+		return nil, err
+	}
+	src, _ := v.(map[string]interface{})
+	doc := map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info":    src["info"],
+		"servers": openAPIServerList(),
+	}
+	for _, k := range []string{"tags", "security", "externalDocs"} {
+		if v, ok := src[k]; ok {
+			doc[k] = v
+		}
+	}
+
+	components := make(map[string]interface{})
+	if defs, ok := src["definitions"].(map[string]interface{}); ok {
+		if err := applyPropertyExtras(defs, true); err != nil {
+			return nil, err
+		}
+		components["schemas"] = defs
+	}
+	if defs, ok := src["securityDefinitions"].(map[string]interface{}); ok {
+		components["securitySchemes"] = securitySchemes(defs)
+	}
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+
+	paths := make(map[string]interface{})
+	for p, item := range rootapi.Paths {
+		ops := make(map[string]interface{})
+		for _, mo := range itemOperations(item) {
+			op, err := openAPIOperation(mo.op)
+			if err != nil {
+				return nil, err
+			}
+			ops[strings.ToLower(mo.method)] = op
+		}
+		paths[p] = ops
+	}
+	doc["paths"] = paths
+
+	rewriteRefs(doc)
+	return doc, nil
+}
+
+// openAPIServerList returns the servers of the @Server annotations, or
+// the server built from @Schemes, @Host and the namespace version
+func openAPIServerList() []openAPIServer {
+	if len(openAPIServers) > 0 {
+		return openAPIServers
+	}
+	if rootapi.Host == "" {
+		basePath := rootapi.BasePath
+		if basePath == "" {
+			basePath = "/"
+		}
+		return []openAPIServer{{URL: basePath}}
+	}
+	schemes := rootapi.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http"}
+	}
+	var list []openAPIServer
+	for _, scheme := range schemes {
+		list = append(list, openAPIServer{URL: strings.TrimSpace(scheme) + "://" + rootapi.Host + rootapi.BasePath})
+	}
+	return list
+}
+
+// openAPIOperation converts a Swagger 2.0 operation: body and formData
+// parameters become the request body, responses get a content per media type
+func openAPIOperation(op *swagger.Operation) (map[string]interface{}, error) {
+	v, err := toGeneric(op)
+	if err != nil {
+		return nil, err
+	}
+	src, _ := v.(map[string]interface{})
+	out := make(map[string]interface{})
+	for _, k := range []string{"tags", "summary", "description", "operationId", "security", "deprecated"} {
+		if v, ok := src[k]; ok {
+			out[k] = v
+		}
+	}
+	consumes := op.Consumes
+	if len(consumes) == 0 {
+		consumes = []string{ajson}
+	}
+	produces := op.Produces
+	if len(produces) == 0 {
+		produces = []string{ajson}
+	}
+
+	var params []interface{}
+	formProps := make(map[string]interface{})
+	var formRequired []string
+	for _, p := range op.Parameters {
+		var schema interface{}
+		if p.In == "body" {
+			schema, err = toGeneric(p.Schema)
+		} else {
+			schema, err = paramSchema(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch p.In {
+		case "body":
+			body := map[string]interface{}{"content": mediaContent(consumes, schema)}
+			if p.Description != "" {
+				body["description"] = p.Description
+			}
+			if p.Required {
+				body["required"] = true
+			}
+			out["requestBody"] = body
+		case "formData":
+			formProps[p.Name] = schema
+			if p.Required {
+				formRequired = append(formRequired, p.Name)
+			}
+		default:
+			param := map[string]interface{}{
+				"name":   p.Name,
+				"in":     p.In,
+				"schema": schema,
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Required || p.In == "path" {
+				param["required"] = true
+			}
+			params = append(params, param)
+		}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	if len(formProps) > 0 {
+		schema := map[string]interface{}{"type": astTypeObject, "properties": formProps}
+		if len(formRequired) > 0 {
+			schema["required"] = formRequired
+		}
+		mime := aurlencoded
+		for _, c := range op.Consumes {
+			if c == aform {
+				mime = aform
+			}
+		}
+		out["requestBody"] = map[string]interface{}{"content": mediaContent([]string{mime}, schema)}
+	}
+
+	responses := make(map[string]interface{})
+	for code, rs := range op.Responses {
+		resp := map[string]interface{}{"description": rs.Description}
+		if rs.Description == "" {
+			status, _ := strconv.Atoi(code)
+			resp["description"] = http.StatusText(status)
+		}
+		var schema interface{}
+		if alternatives := responseOneOf[op][code]; len(alternatives) > 1 {
+			var oneOf []interface{}
+			for _, s := range alternatives {
+				v, err := toGeneric(s)
+				if err != nil {
+					return nil, err
+				}
+				oneOf = append(oneOf, v)
+			}
+			schema = map[string]interface{}{"oneOf": oneOf}
+		} else if rs.Schema != nil {
+			if schema, err = toGeneric(rs.Schema); err != nil {
+				return nil, err
+			}
+		}
+		if schema != nil {
+			resp["content"] = mediaContent(produces, schema)
+		}
+		responses[code] = resp
+	}
+	out["responses"] = responses
+	return out, nil
+}
+
+func mediaContent(mimes []string, schema interface{}) map[string]interface{} {
+	content := make(map[string]interface{})
+	for _, mime := range mimes {
+		content[mime] = map[string]interface{}{"schema": schema}
+	}
+	return content
+}
+
+// paramSchema returns the schema of a non body parameter
+func paramSchema(p swagger.Parameter) (interface{}, error) {
+	if p.Schema != nil {
+		return toGeneric(p.Schema)
+	}
+	if p.Type == "file" {
+		return map[string]interface{}{"type": "string", "format": "binary"}, nil
+	}
+	schema := map[string]interface{}{"type": p.Type}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	if p.Items != nil {
+		items, err := toGeneric(p.Items)
+		if err != nil {
+			return nil, err
+		}
+		schema["items"] = items
+	}
+	if p.Default != nil {
+		schema["default"] = p.Default
+	}
+	return schema, nil
+}
+
+// securitySchemes converts the Swagger 2.0 security definitions
+func securitySchemes(defs map[string]interface{}) map[string]interface{} {
+	schemes := make(map[string]interface{})
+	for name, v := range defs {
+		def, _ := v.(map[string]interface{})
+		scheme := make(map[string]interface{})
+		if desc, ok := def["description"]; ok {
+			scheme["description"] = desc
+		}
+		switch def["type"] {
+		case "basic":
+			scheme["type"] = "http"
+			scheme["scheme"] = "basic"
+		case "apiKey":
+			scheme["type"] = "apiKey"
+			scheme["name"] = def["name"]
+			scheme["in"] = def["in"]
+		case "oauth2":
+			flow := map[string]interface{}{"scopes": def["scopes"]}
+			if flow["scopes"] == nil {
+				flow["scopes"] = map[string]interface{}{}
+			}
+			var flowName string
+			switch def["flow"] {
+			case "implicit":
+				flowName = "implicit"
+				flow["authorizationUrl"] = def["authorizationUrl"]
+			case "password":
+				flowName = "password"
+				flow["tokenUrl"] = tokenURL(def)
+			case "application":
+				flowName = "clientCredentials"
+				flow["tokenUrl"] = tokenURL(def)
+			case "accessCode":
+				flowName = "authorizationCode"
+				flow["authorizationUrl"] = def["authorizationUrl"]
+				flow["tokenUrl"] = tokenURL(def)
+			}
+			scheme["type"] = "oauth2"
+			scheme["flows"] = map[string]interface{}{flowName: flow}
+		}
+		schemes[name] = scheme
+	}
+	return schemes
+}
+
+// tokenURL returns the token URL of an oauth2 definition. The @SecurityDefinition
+// annotation only has one URL, which is used for both when no token URL is set.
+func tokenURL(def map[string]interface{}) interface{} {
+	if u, ok := def["tokenUrl"]; ok && u != "" {
+		return u
+	}
+	return def["authorizationUrl"]
+}
+
+// applyPropertyExtras adds to the schemas the keywords which cannot be set
+// on the swagger types. For OpenAPI 3.1, nullable properties become a type
+// list or a oneOf with null and the exclusive bounds become numbers; for
+// Swagger 2.0, nullable becomes the x-nullable extension.
+func applyPropertyExtras(defs map[string]interface{}, openAPI3 bool) error {
+	for defName, props := range propertyExtras {
+		def, _ := defs[defName].(map[string]interface{})
+		properties, _ := def["properties"].(map[string]interface{})
+		for propName, extras := range props {
+			prop, ok := properties[propName].(map[string]interface{})
+			if propName == "" {
+				// keywords of the definition itself
+				prop, ok = def, def != nil
+			}
+			if !ok {
+				continue
+			}
+			for k, v := range extras {
+				// the schemas of the values of the map models
+				if p, isSchema := v.(swagger.Propertie); isSchema {
+					var err error
+					if v, err = toGeneric(p); err != nil {
+						return fmt.Errorf("could not convert the %s of '%s': %s", k, defName, err)
+					}
+				}
+				if !openAPI3 {
+					if k == "nullable" {
+						k = "x-nullable"
+					}
+					prop[k] = v
+					continue
+				}
+				switch k {
+				case "nullable":
+				case "exclusiveMinimum", "exclusiveMaximum":
+					continue
+				case "minimum", "maximum":
+					if extras["exclusive"+strings.Title(k)] == true {
+						k = "exclusive" + strings.Title(k)
+					}
+					prop[k] = v
+					continue
+				default:
+					prop[k] = v
+					continue
+				}
+				if ref, isRef := prop["$ref"]; isRef {
+					delete(prop, "$ref")
+					prop["oneOf"] = []interface{}{
+						map[string]interface{}{"$ref": ref},
+						map[string]interface{}{"type": "null"},
+					}
+				} else if t, isType := prop["type"].(string); isType {
+					prop["type"] = []interface{}{t, "null"}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// setPropertyExtra records a schema keyword of a model property which
+// cannot be set on swagger.Propertie
+func setPropertyExtra(defName, propName, keyword string, value interface{}) {
+	if propertyExtras[defName] == nil {
+		propertyExtras[defName] = make(map[string]map[string]interface{})
+	}
+	if propertyExtras[defName][propName] == nil {
+		propertyExtras[defName][propName] = make(map[string]interface{})
+	}
+	propertyExtras[defName][propName][keyword] = value
+}
+
+// rewriteRefs points the Swagger 2.0 definition references to the components
+func rewriteRefs(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if ref, ok := val.(string); ok && k == "$ref" {
+				t[k] = strings.Replace(ref, "#/definitions/", "#/components/schemas/", 1)
+				continue
+			}
+			rewriteRefs(val)
+		}
+	case []interface{}:
+		for _, val := range t {
+			rewriteRefs(val)
+		}
+	}
+}
+
+// toGeneric returns the JSON representation of v as maps and slices
+func toGeneric(v interface{}) (interface{}, error) {
+	var out interface{}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
@@ -0,0 +1,546 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goasana/asana/swagger"
+	"github.com/goasana/asanacli/config"
+)
+
+var moduleRegex = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+
+// restMethods are the controller methods registered by asana.Router
+// without mapping methods
+var restMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// routerEntry returns the router package directory or entry file of the
+// Asanafile, by default the routers directory
+func routerEntry(curpath string) string {
+	if config.Conf.Docs.Router == "" {
+		return filepath.Join(curpath, "routers")
+	}
+	if filepath.IsAbs(config.Conf.Docs.Router) {
+		return config.Conf.Docs.Router
+	}
+	return filepath.Join(curpath, config.Conf.Docs.Router)
+}
+
+// modulePath returns the module path of the go.mod of curpath, if any
+func modulePath(curpath string) string {
+	data, err := ioutil.ReadFile(filepath.Join(curpath, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	if m := moduleRegex.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// parseRouterPackage parses all the files of the router package. The entry
+// files are all of them for a directory, or the given file.
+func parseRouterPackage(fset *token.FileSet, routerPath string) (files, entries []*ast.File, err error) {
+	dir := routerPath
+	fi, err := os.Stat(routerPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while parsing router: %s", err)
+	}
+	if !fi.IsDir() {
+		dir = filepath.Dir(routerPath)
+	}
+	pkgs, err := parseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !info.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go") &&
+			!strings.HasSuffix(name, "_test.go")
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while parsing router at '%s': %s", dir, err)
+	}
+	for _, pkg := range pkgs {
+		for fname, f := range pkg.Files {
+			files = append(files, f)
+			if fi.IsDir() || sameFile(fname, routerPath) {
+				entries = append(entries, f)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("no router file found at '%s'", routerPath)
+	}
+	return files, entries, nil
+}
+
+func sameFile(a, b string) bool {
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(fa, fb)
+}
+
+// routerAnalyser resolves the routes registered by the router package:
+// namespaces, asana.Router, asana.Include and the helper functions of the
+// package building them.
+type routerAnalyser struct {
+	fset  *token.FileSet
+	funcs map[string]*ast.FuncDecl // package level functions
+	done  map[string]bool          // analysed calls, per URL prefix
+}
+
+func newRouterAnalyser(fset *token.FileSet, files []*ast.File) *routerAnalyser {
+	ra := &routerAnalyser{
+		fset:  fset,
+		funcs: make(map[string]*ast.FuncDecl),
+		done:  make(map[string]bool),
+	}
+	for _, f := range files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name != "init" {
+				ra.funcs[fd.Name.Name] = fd
+			}
+		}
+	}
+	return ra
+}
+
+// analyse resolves the routes of the init functions of the entry files,
+// and of their functions which are not called by the package itself
+func (ra *routerAnalyser) analyse(entries []*ast.File) {
+	called := make(map[string]bool)
+	for _, fd := range ra.funcs {
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			if ce, ok := n.(*ast.CallExpr); ok {
+				if ident, ok := ce.Fun.(*ast.Ident); ok {
+					called[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	for _, f := range entries {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || fd.Body == nil {
+				continue
+			}
+			if fd.Name.Name == "init" || !called[fd.Name.Name] {
+				ra.analyseBody(fd.Body, "")
+			}
+		}
+	}
+}
+
+func (ra *routerAnalyser) unresolved(n ast.Node, format string, a ...interface{}) {
+	unresolvedRoutes = append(unresolvedRoutes, LintIssue{Pos: ra.fset.Position(n.Pos()), Message: fmt.Sprintf(format, a...)})
+}
+
+// once reports whether a call has not been analysed yet for a prefix, so
+// that a namespace assigned to a variable is not analysed twice
+func (ra *routerAnalyser) once(ce *ast.CallExpr, prefix string) bool {
+	key := fmt.Sprintf("%d:%s", ce.Pos(), prefix)
+	if ra.done[key] {
+		return false
+	}
+	ra.done[key] = true
+	return true
+}
+
+func (ra *routerAnalyser) analyseBody(body *ast.BlockStmt, prefix string) {
+	if body == nil {
+		return
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		ce, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		return !ra.analyseCall(ce, prefix)
+	})
+}
+
+// analyseCall analyses a call of a function body. It returns true if the
+// call registers routes and has been handled.
+func (ra *routerAnalyser) analyseCall(ce *ast.CallExpr, prefix string) bool {
+	switch fun := ce.Fun.(type) {
+	case *ast.Ident:
+		if fd, ok := ra.funcs[fun.Name]; ok && ra.once(ce, prefix) {
+			ra.analyseBody(fd.Body, prefix)
+		}
+	case *ast.SelectorExpr:
+		switch fun.Sel.Name {
+		case "NewNamespace":
+			ra.analyseNamespace(ce, prefix)
+			return true
+		case "AddNamespace":
+			for _, arg := range ce.Args {
+				ra.analyseNamespaceExpr(arg, prefix)
+			}
+			return true
+		case "Router":
+			ra.analyseRouterCall(ce, prefix)
+			return true
+		case "Include":
+			ra.analyseInclude(ce, prefix)
+			return true
+		}
+	}
+	return false
+}
+
+// analyseNamespaceExpr analyses an expression which evaluates to a namespace
+func (ra *routerAnalyser) analyseNamespaceExpr(e ast.Expr, prefix string) {
+	switch t := e.(type) {
+	case *ast.CallExpr:
+		switch fun := t.Fun.(type) {
+		case *ast.SelectorExpr:
+			if fun.Sel.Name == "NewNamespace" {
+				ra.analyseNamespace(t, prefix)
+				return
+			}
+		case *ast.Ident:
+			if fd, ok := ra.funcs[fun.Name]; ok {
+				ra.analyseReturns(fd, func(r ast.Expr) { ra.analyseNamespaceExpr(r, prefix) })
+				return
+			}
+		}
+	case *ast.Ident:
+		if isFuncParam(t) {
+			// resolved where the function is called
+			return
+		}
+		if v := identValue(t); v != nil {
+			ra.analyseNamespaceExpr(v, prefix)
+			return
+		}
+	}
+	ra.unresolved(e, "cannot resolve the namespace")
+}
+
+// analyseNamespace analyses a NewNamespace call. The prefix of the first
+// namespace is the base path of the API, the others prefix their routes.
+func (ra *routerAnalyser) analyseNamespace(ce *ast.CallExpr, prefix string) {
+	if !ra.once(ce, prefix) || len(ce.Args) == 0 {
+		return
+	}
+	nsPrefix, ok := stringValue(ce.Args[0])
+	if !ok {
+		ra.unresolved(ce.Args[0], "cannot resolve the namespace prefix")
+		return
+	}
+	if prefix == "" && (rootapi.BasePath == "" || rootapi.BasePath == nsPrefix) {
+		rootapi.BasePath = nsPrefix
+	} else {
+		prefix += nsPrefix
+	}
+	for _, p := range ce.Args[1:] {
+		ra.analyseNSParam(p, prefix)
+	}
+}
+
+// analyseNSParam analyses a parameter of a namespace
+func (ra *routerAnalyser) analyseNSParam(p ast.Expr, prefix string) {
+	switch t := p.(type) {
+	case *ast.CallExpr:
+		switch fun := t.Fun.(type) {
+		case *ast.SelectorExpr:
+			switch fun.Sel.Name {
+			case "NSNamespace":
+				if len(t.Args) == 0 {
+					return
+				}
+				s, ok := stringValue(t.Args[0])
+				if !ok {
+					ra.unresolved(t.Args[0], "cannot resolve the namespace prefix")
+					return
+				}
+				for _, sp := range t.Args[1:] {
+					ra.analyseNSParam(sp, prefix+s)
+				}
+			case "NSInclude":
+				ra.analyseInclude(t, prefix)
+			case "NSRouter":
+				ra.analyseRouterCall(t, prefix)
+			}
+			// the other parameters, such as NSCond or NSGet, have no documented routes
+			return
+		case *ast.Ident:
+			if fd, ok := ra.funcs[fun.Name]; ok {
+				ra.analyseReturns(fd, func(r ast.Expr) { ra.analyseNSParam(r, prefix) })
+				return
+			}
+		}
+	case *ast.Ident:
+		if isFuncParam(t) {
+			return
+		}
+		if v := identValue(t); v != nil {
+			ra.analyseNSParam(v, prefix)
+			return
+		}
+	}
+	ra.unresolved(p, "cannot resolve the namespace parameter")
+}
+
+// analyseReturns calls fn for the returned expressions of a helper function
+func (ra *routerAnalyser) analyseReturns(fd *ast.FuncDecl, fn func(ast.Expr)) {
+	if fd.Body == nil {
+		return
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			for _, r := range t.Results {
+				fn(r)
+			}
+		}
+		return true
+	})
+}
+
+// analyseInclude analyses an Include or NSInclude call, whose routes come
+// from the @router annotations of the controllers
+func (ra *routerAnalyser) analyseInclude(ce *ast.CallExpr, prefix string) {
+	if !ra.once(ce, prefix) {
+		return
+	}
+	for _, arg := range ce.Args {
+		cname, typeName, ok := ra.controller(arg)
+		if !ok {
+			continue
+		}
+		if _, ok := controllerList[cname]; !ok {
+			ra.unresolved(arg, "controller %s has no @router annotation", typeName)
+			continue
+		}
+		includeController(prefix, cname, typeName)
+		if v, ok := controllerComments[cname]; ok {
+			name := strings.Trim(prefix, "/")
+			if name == "" {
+				// if the include has no prefix, we use the controllername as the tag
+				name = cname
+			}
+			rootapi.Tags = append(rootapi.Tags, swagger.Tag{
+				Name:        name,
+				Description: v,
+			})
+		}
+	}
+}
+
+// analyseRouterCall analyses a Router or NSRouter call: the path, the
+// controller and the optional mapping methods, e.g. "get:GetAll;post:Post"
+func (ra *routerAnalyser) analyseRouterCall(ce *ast.CallExpr, prefix string) {
+	if !ra.once(ce, prefix) {
+		return
+	}
+	if len(ce.Args) < 2 {
+		ra.unresolved(ce, "missing path or controller")
+		return
+	}
+	rt, ok := stringValue(ce.Args[0])
+	if !ok {
+		ra.unresolved(ce.Args[0], "cannot resolve the route path")
+		return
+	}
+	cname, typeName, ok := ra.controller(ce.Args[1])
+	if !ok {
+		return
+	}
+
+	mappings := make(map[string]string) // HTTP method:controller method
+	if len(ce.Args) > 2 {
+		mapping, ok := stringValue(ce.Args[2])
+		if !ok {
+			ra.unresolved(ce.Args[2], "cannot resolve the mapping methods")
+			return
+		}
+		for _, m := range strings.Split(mapping, ";") {
+			kv := strings.SplitN(m, ":", 2)
+			if len(kv) != 2 {
+				ra.unresolved(ce.Args[2], "invalid mapping method '%s'", m)
+				continue
+			}
+			for _, method := range strings.Split(kv[0], ",") {
+				method = strings.ToUpper(strings.TrimSpace(method))
+				if method == "*" {
+					for _, rm := range restMethods {
+						mappings[rm] = kv[1]
+					}
+				} else {
+					mappings[method] = kv[1]
+				}
+			}
+		}
+	} else {
+		for _, method := range restMethods {
+			funcName := strings.Title(strings.ToLower(method))
+			if _, ok := controllerOperations[cname][funcName]; ok {
+				mappings[method] = funcName
+			}
+		}
+	}
+	if len(mappings) == 0 {
+		ra.unresolved(ce, "no documented method of controller %s", typeName)
+		return
+	}
+
+	rt = urlReplace(prefix + rt)
+	if len(rootapi.Paths) == 0 {
+		rootapi.Paths = make(map[string]*swagger.Item)
+	}
+	item, ok := rootapi.Paths[rt]
+	if !ok {
+		item = &swagger.Item{}
+		rootapi.Paths[rt] = item
+	}
+	tag := strings.Trim(prefix, "/")
+	if tag == "" {
+		tag = cname
+	}
+	for method, funcName := range mappings {
+		op, ok := controllerOperations[cname][funcName]
+		if !ok {
+			// the method has no annotation, document the route anyway
+			op = &swagger.Operation{
+				OperationID: typeName + "." + funcName,
+				Responses:   map[string]swagger.Response{"200": {Description: "OK"}},
+			}
+			operationFuncs[op] = funcName
+		}
+		op.Tags = []string{tag}
+		setOperation(item, method, op)
+	}
+	pathControllers[rt] = typeName
+}
+
+// controller resolves a controller expression, e.g. &controllers.UserController{},
+// to the controller name used by controllerList and its type name
+func (ra *routerAnalyser) controller(e ast.Expr) (cname, typeName string, ok bool) {
+	var typ ast.Expr
+	switch t := e.(type) {
+	case *ast.UnaryExpr:
+		if cl, isLit := t.X.(*ast.CompositeLit); isLit {
+			typ = cl.Type
+		}
+	case *ast.CallExpr:
+		if ident, isIdent := t.Fun.(*ast.Ident); isIdent && ident.Name == "new" && len(t.Args) == 1 {
+			typ = t.Args[0]
+		}
+	case *ast.Ident:
+		if v := identValue(t); v != nil {
+			return ra.controller(v)
+		}
+	}
+	x, isSel := typ.(*ast.SelectorExpr)
+	if !isSel {
+		ra.unresolved(e, "cannot determine the controller type")
+		return "", "", false
+	}
+	pkgpath, found := importlist[fmt.Sprint(x.X)]
+	if !found {
+		ra.unresolved(e, "unknown controller package %s", x.X)
+		return "", "", false
+	}
+	return pkgpath + x.Sel.Name, x.Sel.Name, true
+}
+
+// includeController adds the annotated routes of a controller to rootapi
+func includeController(baseurl, cname, typeName string) {
+	for rt, item := range controllerList[cname] {
+		tag := cname
+		if baseurl != "" {
+			rt = baseurl + rt
+			tag = strings.Trim(baseurl, "/")
+		}
+		for _, mo := range itemOperations(item) {
+			mo.op.Tags = []string{tag}
+		}
+		if len(rootapi.Paths) == 0 {
+			rootapi.Paths = make(map[string]*swagger.Item)
+		}
+		rt = urlReplace(rt)
+		rootapi.Paths[rt] = item
+		pathControllers[rt] = typeName
+	}
+}
+
+// identValue returns the expression assigned to an identifier by its
+// declaration, if any
+func identValue(ident *ast.Ident) ast.Expr {
+	if ident.Obj == nil {
+		return nil
+	}
+	switch decl := ident.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range decl.Lhs {
+			if l, ok := lhs.(*ast.Ident); ok && l.Name == ident.Name && len(decl.Rhs) == len(decl.Lhs) {
+				return decl.Rhs[i]
+			}
+		}
+	case *ast.ValueSpec:
+		for i, name := range decl.Names {
+			if name.Name == ident.Name && i < len(decl.Values) {
+				return decl.Values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// isFuncParam reports whether an identifier is a function parameter
+func isFuncParam(ident *ast.Ident) bool {
+	if ident.Obj == nil || ident.Obj.Kind != ast.Var {
+		return false
+	}
+	_, ok := ident.Obj.Decl.(*ast.Field)
+	return ok
+}
+
+// stringValue evaluates a string expression made of literals, constants
+// and concatenations
+func stringValue(e ast.Expr) (string, bool) {
+	switch t := e.(type) {
+	case *ast.BasicLit:
+		if t.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(t.Value)
+		return s, err == nil
+	case *ast.Ident:
+		if v := identValue(t); v != nil {
+			return stringValue(v)
+		}
+	case *ast.ParenExpr:
+		return stringValue(t.X)
+	case *ast.BinaryExpr:
+		if t.Op != token.ADD {
+			return "", false
+		}
+		x, okX := stringValue(t.X)
+		y, okY := stringValue(t.Y)
+		return x + y, okX && okY
+	}
+	return "", false
+}
@@ -0,0 +1,409 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/goasana/asana/swagger"
+	asanaLogger "github.com/goasana/asanacli/logger"
+	"github.com/goasana/asanacli/logger/colors"
+	bu "github.com/goasana/asanacli/utils"
+)
+
+// maxExampleDepth limits how deep nested models are expanded in example payloads
+const maxExampleDepth = 5
+
+// testCase describes a single generated endpoint test
+type testCase struct {
+	Name        string
+	Controller  string
+	Func        string
+	Method      string
+	Path        string
+	URL         string
+	Headers     map[string]string
+	Body        string
+	ContentType string
+	Status      int
+}
+
+// GenerateTests generates httptest based test files for every controller
+// included by the router file. The routes and methods come from the @router
+// annotations and the request payloads are derived from the @Param models.
+func GenerateTests(curpath, routerFile string) {
+	if routerFile == "" {
+		routerFile = routerEntry(curpath)
+	} else if !filepath.IsAbs(routerFile) {
+		routerFile = filepath.Join(curpath, routerFile)
+	}
+	if err := analyseRouter(routerFile, curpath); err != nil {
+		asanaLogger.Log.Fatalf("%s", err)
+	}
+
+	routerDir := routerFile
+	if fi, err := os.Stat(routerFile); err != nil || !fi.IsDir() {
+		routerDir = filepath.Dir(routerFile)
+	}
+	routerDir, err := filepath.Rel(curpath, routerDir)
+	if err != nil {
+		asanaLogger.Log.Fatalf("Router file must be inside the application: %s", err)
+	}
+	routerPkg := appPackagePath(curpath)
+	if routerDir != "." {
+		routerPkg += "/" + filepath.ToSlash(routerDir)
+	}
+
+	cases := make(map[string][]testCase)
+	for _, tc := range buildTestCases() {
+		cases[tc.Controller] = append(cases[tc.Controller], tc)
+	}
+	if len(cases) == 0 {
+		asanaLogger.Log.Warn("No annotated routes found, no tests generated")
+		return
+	}
+
+	testPath := filepath.Join(curpath, "tests")
+	_ = os.MkdirAll(testPath, 0755)
+
+	controllers := make([]string, 0, len(cases))
+	for c := range cases {
+		controllers = append(controllers, c)
+	}
+	sort.Strings(controllers)
+
+	w := colors.NewColorWriter(os.Stdout)
+	t := template.Must(template.New("tests").Parse(testFileTpl))
+	for _, c := range controllers {
+		fpath := filepath.Join(testPath, bu.SnakeString(c)+"_test.go")
+		if bu.IsExist(fpath) {
+			asanaLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
+			if !bu.AskForConfirmation() {
+				asanaLogger.Log.Warnf("Skipped create file '%s'", fpath)
+				continue
+			}
+		}
+		f, err := os.Create(fpath)
+		if err != nil {
+			asanaLogger.Log.Warnf("%s", err)
+			continue
+		}
+		err = t.Execute(f, struct {
+			RouterPkg string
+			Cases     []testCase
+		}{routerPkg, cases[c]})
+		bu.CloseFile(f)
+		if err != nil {
+			asanaLogger.Log.Fatalf("Could not write test file to '%s': %s", fpath, err)
+		}
+		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+		bu.FormatSourceCode(fpath)
+	}
+}
+
+// buildTestCases returns one test case per documented route and HTTP method
+func buildTestCases() []testCase {
+	var paths []string
+	for p := range rootapi.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var cases []testCase
+	names := make(map[string]int)
+	for _, p := range paths {
+		controller, ok := pathControllers[p]
+		if !ok {
+			continue
+		}
+		for _, mo := range itemOperations(rootapi.Paths[p]) {
+			tc := newTestCase(controller, p, mo.method, mo.op)
+			tc.Name = tc.Controller + tc.Func + strings.Title(strings.ToLower(tc.Method))
+			if n := names[tc.Name]; n > 0 {
+				names[tc.Name]++
+				tc.Name += strconv.Itoa(n + 1)
+			} else {
+				names[tc.Name] = 1
+			}
+			cases = append(cases, tc)
+		}
+	}
+	return cases
+}
+
+type methodOperation struct {
+	method string
+	op     *swagger.Operation
+}
+
+// itemOperations lists the operations of a path item in a stable order
+func itemOperations(item *swagger.Item) []methodOperation {
+	var ops []methodOperation
+	for _, mo := range []methodOperation{
+		{"GET", item.Get},
+		{"POST", item.Post},
+		{"PUT", item.Put},
+		{"PATCH", item.Patch},
+		{"DELETE", item.Delete},
+		{"HEAD", item.Head},
+		{"OPTIONS", item.Options},
+	} {
+		if mo.op != nil {
+			ops = append(ops, mo)
+		}
+	}
+	return ops
+}
+
+func newTestCase(controller, swaggerPath, method string, op *swagger.Operation) testCase {
+	tc := testCase{
+		Controller: controller,
+		Func:       operationFuncs[op],
+		Method:     method,
+		Path:       swaggerPath,
+		Headers:    make(map[string]string),
+		Status:     successStatus(op),
+	}
+	reqPath := rootapi.BasePath + swaggerPath
+	query := url.Values{}
+	form := url.Values{}
+	for _, para := range op.Parameters {
+		switch para.In {
+		case "path":
+			reqPath = strings.Replace(reqPath, "{"+para.Name+"}", url.PathEscape(paramExample(para)), -1)
+		case "query":
+			if para.Required || para.Default != nil {
+				query.Set(para.Name, paramExample(para))
+			}
+		case "header":
+			tc.Headers[para.Name] = paramExample(para)
+		case "formData":
+			form.Set(para.Name, paramExample(para))
+		case "body":
+			var example interface{}
+			if para.Schema != nil {
+				example = exampleFromSchema(para.Schema, 0)
+			}
+			data, err := json.Marshal(example)
+			if err != nil {
+				asanaLogger.Log.Warnf("Could not build example body for %s %s: %s", method, swaggerPath, err)
+				continue
+			}
+			tc.Body = string(data)
+			tc.ContentType = ajson
+		}
+	}
+	if len(form) > 0 && tc.Body == "" {
+		tc.Body = form.Encode()
+		tc.ContentType = "application/x-www-form-urlencoded"
+	}
+	tc.URL = reqPath
+	if len(query) > 0 {
+		tc.URL += "?" + query.Encode()
+	}
+	if tc.Func == "" {
+		tc.Func = strings.Title(strings.ToLower(method))
+	}
+	return tc
+}
+
+// successStatus returns the lowest 2xx status code documented by @Success
+func successStatus(op *swagger.Operation) int {
+	status := 0
+	for code := range op.Responses {
+		c, err := strconv.Atoi(code)
+		if err != nil || c < 200 || c > 299 {
+			continue
+		}
+		if status == 0 || c < status {
+			status = c
+		}
+	}
+	if status == 0 {
+		return 200
+	}
+	return status
+}
+
+// paramExample returns an example value for a non body parameter
+func paramExample(para swagger.Parameter) string {
+	if para.Default != nil {
+		return fmt.Sprint(para.Default)
+	}
+	typ := para.Type
+	if typ == astTypeArray && para.Items != nil {
+		typ = para.Items.Type
+	}
+	switch typ {
+	case "integer", "number":
+		return "1"
+	case "boolean":
+		return "true"
+	}
+	if para.In == "path" {
+		// path parameters are mostly identifiers
+		return "1"
+	}
+	return "test"
+}
+
+// exampleFromSchema builds an example value for the given schema,
+// resolving model references from the generated definitions.
+func exampleFromSchema(schema *swagger.Schema, depth int) interface{} {
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	if schema.Example != nil {
+		return schema.Example
+	}
+	if schema.Ref != "" {
+		def, ok := rootapi.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+		if !ok {
+			return map[string]interface{}{}
+		}
+		return exampleFromSchema(&def, depth+1)
+	}
+	switch schema.Type {
+	case astTypeArray:
+		return []interface{}{exampleFromSchema(schema.Items, depth+1)}
+	case astTypeObject, "":
+		obj := make(map[string]interface{})
+		for name, p := range schema.Properties {
+			obj[name] = exampleFromPropertie(p, depth+1)
+		}
+		return obj
+	}
+	return exampleFromType(schema.Type, schema.Format)
+}
+
+func exampleFromPropertie(p swagger.Propertie, depth int) interface{} {
+	if depth > maxExampleDepth {
+		return nil
+	}
+	if p.Example != nil {
+		return p.Example
+	}
+	if p.Default != nil {
+		return p.Default
+	}
+	if p.Ref != "" {
+		return exampleFromSchema(&swagger.Schema{Ref: p.Ref}, depth)
+	}
+	switch p.Type {
+	case astTypeArray:
+		if p.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{exampleFromPropertie(*p.Items, depth+1)}
+	case astTypeObject, "":
+		obj := make(map[string]interface{})
+		for name, sp := range p.Properties {
+			obj[name] = exampleFromPropertie(sp, depth+1)
+		}
+		if p.AdditionalProperties != nil {
+			obj["key"] = exampleFromPropertie(*p.AdditionalProperties, depth+1)
+		}
+		return obj
+	}
+	return exampleFromType(p.Type, p.Format)
+}
+
+func exampleFromType(typ, format string) interface{} {
+	switch typ {
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		switch format {
+		case "datetime", "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "byte":
+			return ""
+		}
+		return "string"
+	}
+	return nil
+}
+
+// appPackagePath returns the import path of the application at curpath,
+// read from its go.mod or derived from the GOPATH.
+func appPackagePath(curpath string) string {
+	if mod := modulePath(curpath); mod != "" {
+		return mod
+	}
+	for _, gopath := range bu.GetGOPATHs() {
+		src, _ := filepath.EvalSymlinks(filepath.Join(gopath, "src"))
+		if rel, err := filepath.Rel(src, curpath); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+			return filepath.ToSlash(rel)
+		}
+	}
+	asanaLogger.Log.Fatalf("Cannot determine the package path of '%s': no go.mod found and not inside GOPATH", curpath)
+	return ""
+}
+
+var testFileTpl = `package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	_ "{{.RouterPkg}}"
+
+	"github.com/goasana/asana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	_, file, _, _ := runtime.Caller(0)
+	apppath, _ := filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
+	asana.TestAsanaInit(apppath)
+}
+{{range .Cases}}
+// Test{{.Name}} tests {{.Method}} {{.Path}} served by {{.Controller}}.{{.Func}}
+func Test{{.Name}}(t *testing.T) {
+	r, _ := http.NewRequest("{{.Method}}", {{printf "%q" .URL}}, strings.NewReader({{printf "%q" .Body}}))
+{{- if .ContentType}}
+	r.Header.Set("Content-Type", "{{.ContentType}}")
+{{- end}}
+{{- range $k, $v := .Headers}}
+	r.Header.Set({{printf "%q" $k}}, {{printf "%q" $v}})
+{{- end}}
+	w := httptest.NewRecorder()
+	asana.AsanaApp.Handlers.ServeHTTP(w, r)
+
+	asana.Trace("testing", "Test{{.Name}}", "Code[%d]\n%s", w.Code, w.Body.String())
+
+	Convey("Subject: {{.Method}} {{.Path}}\n", t, func() {
+		Convey("Status Code Should Be {{.Status}}", func() {
+			So(w.Code, ShouldEqual, {{.Status}})
+		})
+	})
+}
+{{end}}`
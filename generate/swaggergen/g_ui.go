@@ -0,0 +1,166 @@
+// Copyright 2019 asana authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package swaggergen
+
+import (
+	"archive/zip"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	asanaLogger "github.com/goasana/asanacli/logger"
+	bu "github.com/goasana/asanacli/utils"
+)
+
+// The UI which renders the docs, Swagger UI pinned to the release recorded
+// in ui/NOTICE, is embedded into the binary, so that it is available
+// offline. It loads swagger.json, or openapi.json, next to it.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// UIFileSystem returns the embedded UI
+func UIFileSystem() http.FileSystem {
+	sub, _ := fs.Sub(uiFiles, "ui")
+	return http.FS(sub)
+}
+
+// WriteUI writes the embedded UI into dir, e.g. swagger, without
+// overwriting the files which exist
+func WriteUI(dir string) error {
+	sub, _ := fs.Sub(uiFiles, "ui")
+	return fs.WalkDir(sub, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(fpath, 0755)
+		}
+		if bu.IsExist(fpath) {
+			asanaLogger.Log.Infof("'%s' already exists", fpath)
+			return nil
+		}
+		data, err := fs.ReadFile(sub, name)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(fpath, data, 0644)
+	})
+}
+
+// ExtractUI extracts the archive of a UI, e.g. a release of Swagger UI or
+// Redoc, into dir. When all the files are in a single directory of the
+// archive, e.g. swagger-ui-4.15.5/, it is stripped. The entries which would
+// be written outside of dir are rejected.
+func ExtractUI(src, dir string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	prefix := commonDir(r.File)
+	for _, f := range r.File {
+		name := strings.TrimPrefix(path.Clean("/"+f.Name), "/")
+		if name+"/" == prefix {
+			continue
+		}
+		name = strings.TrimPrefix(name, prefix)
+		if name == "" {
+			continue
+		}
+		fpath, err := safeJoin(dir, name)
+		if err != nil {
+			return fmt.Errorf("invalid entry '%s' in '%s': %s", f.Name, src, err)
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(fpath, 0755)
+		case mode.IsRegular():
+			err = extractFile(f, fpath)
+		default:
+			asanaLogger.Log.Warnf("Skipping '%s' which is not a regular file", f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonDir returns the directory all the entries of an archive are in,
+// with a trailing slash, or ""
+func commonDir(files []*zip.File) string {
+	prefix := ""
+	for _, f := range files {
+		name := strings.TrimPrefix(path.Clean("/"+f.Name), "/")
+		i := strings.Index(name, "/")
+		if i < 0 {
+			if !f.Mode().IsDir() {
+				return ""
+			}
+			i = len(name)
+		}
+		if prefix != "" && prefix != name[:i]+"/" {
+			return ""
+		}
+		prefix = name[:i] + "/"
+	}
+	return prefix
+}
+
+// safeJoin joins a relative slash separated name to dir, failing if the
+// result is not inside dir
+func safeJoin(dir, name string) (string, error) {
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("absolute path")
+	}
+	fpath := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, fpath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside of '%s'", dir)
+	}
+	return fpath, nil
+}
+
+func extractFile(f *zip.File, fpath string) error {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
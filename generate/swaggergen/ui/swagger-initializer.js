@@ -0,0 +1,39 @@
+window.onload = function() {
+  // The docs are the ?url= parameter, or else the swagger.json or the
+  // openapi.json written by asanacli generate docs next to the UI
+  var url = new URLSearchParams(window.location.search).get("url");
+  var candidates = url ? [url] : ["swagger.json", "openapi.json"];
+
+  function render(url) {
+    window.ui = SwaggerUIBundle({
+      url: url,
+      dom_id: "#swagger-ui",
+      deepLinking: true,
+      presets: [
+        SwaggerUIBundle.presets.apis,
+        SwaggerUIStandalonePreset
+      ],
+      plugins: [
+        SwaggerUIBundle.plugins.DownloadUrl
+      ],
+      layout: "StandaloneLayout"
+    });
+  }
+
+  function load(i) {
+    if (i === candidates.length - 1) {
+      render(candidates[i]);
+      return;
+    }
+    fetch(candidates[i], { method: "HEAD" }).then(function(resp) {
+      if (resp.ok) {
+        render(candidates[i]);
+      } else {
+        load(i + 1);
+      }
+    }, function() {
+      load(i + 1);
+    });
+  }
+  load(0);
+};